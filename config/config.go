@@ -11,6 +11,7 @@ type Config struct {
 	Server   ServerConfig
 	Logging  LoggingConfig
 	History  HistoryConfig
+	Cache    CacheConfig
 	Security SecurityConfig
 }
 
@@ -35,11 +36,17 @@ type HistoryConfig struct {
 	TTL     time.Duration
 }
 
+// CacheConfig holds compiled-expression cache configuration
+type CacheConfig struct {
+	Capacity int
+}
+
 // SecurityConfig holds security-related configuration
 type SecurityConfig struct {
 	RateLimit      int
 	MaxRequestSize int64
 	AllowedOrigins []string
+	AdminToken     string // Bearer token required by the admin endpoints; they are disabled when empty
 }
 
 // New creates a new Config with values from environment variables
@@ -60,10 +67,14 @@ func New() *Config {
 			MaxSize: getEnvAsInt("HISTORY_MAX_SIZE", 1000),
 			TTL:     getEnvAsDuration("HISTORY_TTL", 24*time.Hour),
 		},
+		Cache: CacheConfig{
+			Capacity: getEnvAsInt("EXPRESSION_CACHE_SIZE", 1000),
+		},
 		Security: SecurityConfig{
 			RateLimit:      getEnvAsInt("RATE_LIMIT", 100),
 			MaxRequestSize: getEnvAsInt64("MAX_REQUEST_SIZE", 1024*1024), // 1MB
 			AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{"*"}),
+			AdminToken:     getEnv("ADMIN_TOKEN", ""),
 		},
 	}
 }
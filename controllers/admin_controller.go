@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"expression-eval-service/errors"
+	"expression-eval-service/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminController handles operational endpoints for inspecting and managing the service
+type AdminController struct {
+	evaluationService *services.EvaluationService
+	logger            *zap.Logger
+}
+
+// NewAdminController creates a new admin controller
+func NewAdminController(evaluationService *services.EvaluationService, logger *zap.Logger) *AdminController {
+	return &AdminController{
+		evaluationService: evaluationService,
+		logger:            logger,
+	}
+}
+
+// GetCache handles GET requests returning the compiled-expression cache counters and entries
+func (c *AdminController) GetCache(ctx *gin.Context) {
+	stats := c.evaluationService.CacheStats(ctx)
+	errors.SendSuccess(ctx, "Cache retrieved successfully", stats)
+}
+
+// FlushCache handles DELETE requests that empty the compiled-expression cache
+func (c *AdminController) FlushCache(ctx *gin.Context) {
+	removed := c.evaluationService.FlushCache(ctx)
+
+	c.logger.Info("Cache flushed via admin endpoint",
+		zap.Int("removed", removed),
+	)
+
+	errors.SendSuccess(ctx, "Cache flushed successfully", gin.H{
+		"removed": removed,
+	})
+}
@@ -1,29 +1,93 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"expression-eval-service/errors"
+	"expression-eval-service/evaluator"
 	"expression-eval-service/models"
 	"expression-eval-service/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.uber.org/zap"
 )
 
 // EvaluateRequest represents the request body for expression evaluation
 type EvaluateRequest struct {
-	Expression string `json:"expression" binding:"required"` // The mathematical expression to evaluate
+	Expression string                 `json:"expression" binding:"required"` // The mathematical expression to evaluate
+	Variables  map[string]interface{} `json:"variables,omitempty"`           // Values for the variables referenced by the expression
+	Now        *time.Time             `json:"now,omitempty"`                 // Pins the time now() returns, for reproducible results
+
+	evaluator.NumericOptions // numericMode, precision and rounding
 }
 
 // EvaluateResponse represents the response for expression evaluation
 type EvaluateResponse struct {
-	ID         string  `json:"id"`               // Unique identifier for the evaluation
-	Expression string  `json:"expression"`       // The evaluated expression
-	Result     float64 `json:"result,omitempty"` // The computed result (if successful)
-	Error      string  `json:"error,omitempty"`  // Error message (if evaluation failed)
-	Timestamp  string  `json:"timestamp"`        // When the evaluation was performed
+	ID         string          `json:"id"`                   // Unique identifier for the evaluation
+	Expression string          `json:"expression"`           // The evaluated expression
+	Result     evaluator.Value `json:"result,omitempty"`     // The computed result (if successful)
+	ResultType string          `json:"resultType,omitempty"` // The kind of the result, e.g. "number", "boolean" or "rational"
+	Error      string          `json:"error,omitempty"`      // Error message (if evaluation failed)
+	Timestamp  string          `json:"timestamp"`            // When the evaluation was performed
+}
+
+// DerivativeRequest represents the request body for symbolic differentiation
+type DerivativeRequest struct {
+	Expression string                 `json:"expression" binding:"required"` // The expression to differentiate
+	Variable   string                 `json:"variable" binding:"required"`   // The variable to differentiate with respect to
+	At         *float64               `json:"at,omitempty"`                  // Evaluates the derivative at this value of the variable
+	Variables  map[string]interface{} `json:"variables,omitempty"`           // Values for the other variables, used with at
+}
+
+// SimplifyRequest represents the request body for expression simplification
+type SimplifyRequest struct {
+	Expression string `json:"expression" binding:"required"` // The expression to simplify
+}
+
+// SolveRequest represents the request body for solving an equation in one variable
+type SolveRequest struct {
+	Expression    string                 `json:"expression" binding:"required"` // The equation, e.g. "x^2 = 2", or an expression to find a zero of
+	Variable      string                 `json:"variable" binding:"required"`   // The variable to solve for
+	Method        string                 `json:"method,omitempty"`              // "bisection", "brent" or "newton"
+	Bracket       []float64              `json:"bracket,omitempty"`             // [lower, upper], an interval containing the root
+	Initial       *float64               `json:"initial,omitempty"`             // The starting point of Newton's method
+	Tolerance     float64                `json:"tolerance,omitempty"`           // The accepted error in the root
+	MaxIterations int                    `json:"maxIterations,omitempty"`       // The iteration limit
+	Variables     map[string]interface{} `json:"variables,omitempty"`           // Values for the other variables
+}
+
+// PlotRequest represents the request body for plotting an expression in one variable
+type PlotRequest struct {
+	Expression string                 `json:"expression" binding:"required"` // The expression to plot
+	Variable   string                 `json:"variable,omitempty"`            // The variable along the x axis, "x" by default
+	Range      []float64              `json:"range" binding:"required"`      // [from, to], the range of the variable
+	Samples    int                    `json:"samples,omitempty"`             // The number of evenly spaced samples
+	Adaptive   bool                   `json:"adaptive,omitempty"`            // Adds samples where the curve bends sharply
+	Variables  map[string]interface{} `json:"variables,omitempty"`           // Values for the other variables
+}
+
+// mimeSVG is the content type of rendered charts
+const mimeSVG = "image/svg+xml"
+
+// bindJSON decodes and validates a JSON request body like ShouldBindJSON, except that numbers in untyped fields,
+// such as the variables, are decoded as json.Number so the exact numeric modes receive all of their digits
+func bindJSON(ctx *gin.Context, req interface{}) error {
+	if ctx.Request == nil || ctx.Request.Body == nil {
+		return fmt.Errorf("missing request body")
+	}
+
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(req); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(req)
 }
 
 // EvaluateController handles HTTP requests for expression evaluation
@@ -45,7 +109,7 @@ func NewEvaluateController(evaluationService *services.EvaluationService, logger
 // It validates the request, evaluates the expression, and returns the result
 func (c *EvaluateController) Evaluate(ctx *gin.Context) {
 	var req EvaluateRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(ctx, &req); err != nil {
 		c.logger.Error("Invalid request body",
 			zap.Error(err),
 		)
@@ -57,12 +121,16 @@ func (c *EvaluateController) Evaluate(ctx *gin.Context) {
 		zap.String("expression", req.Expression),
 	)
 
-	eval, err := c.evaluationService.Evaluate(ctx, req.Expression)
+	eval, err := c.evaluationService.Evaluate(withNow(ctx, req.Now), req.Expression, req.Variables, req.NumericOptions)
 	if err != nil {
 		c.logger.Error("Evaluation failed",
 			zap.String("expression", req.Expression),
 			zap.Error(err),
 		)
+		if eval.ParseError != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "parseError": eval.ParseError})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -71,6 +139,7 @@ func (c *EvaluateController) Evaluate(ctx *gin.Context) {
 		ID:         eval.ID,
 		Expression: eval.Expression,
 		Result:     eval.Result,
+		ResultType: eval.ResultType,
 		Timestamp:  eval.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
 	}
 
@@ -80,7 +149,7 @@ func (c *EvaluateController) Evaluate(ctx *gin.Context) {
 
 	c.logger.Info("Evaluation successful",
 		zap.String("id", eval.ID),
-		zap.Float64("result", eval.Result),
+		zap.Stringer("result", eval.Result),
 	)
 
 	ctx.JSON(http.StatusOK, response)
@@ -89,15 +158,161 @@ func (c *EvaluateController) Evaluate(ctx *gin.Context) {
 // EvaluateBatch handles batch evaluation requests
 func (c *EvaluateController) EvaluateBatch(ctx *gin.Context) {
 	var req models.BatchEvaluationRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(ctx, &req); err != nil {
 		errors.SendError(ctx, err)
 		return
 	}
 
-	results := c.evaluationService.EvaluateBatch(ctx, req.Expressions)
+	results := c.evaluationService.EvaluateBatch(withNow(ctx, req.Now), req.Expressions, req.Variables, req.NumericOptions)
 	errors.SendSuccess(ctx, "Batch evaluation completed", results)
 }
 
+// Derivative handles POST requests to differentiate an expression symbolically
+// It returns the simplified derivative as an expression and as a tree, and its value when at is given
+func (c *EvaluateController) Derivative(ctx *gin.Context) {
+	var req DerivativeRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		c.logger.Error("Invalid request body",
+			zap.Error(err),
+		)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	derivative, err := c.evaluationService.Differentiate(ctx, req.Expression, req.Variable, req.At, req.Variables)
+	if err != nil {
+		c.logger.Error("Differentiation failed",
+			zap.String("expression", req.Expression),
+			zap.Error(err),
+		)
+		if derivative.ParseError != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "parseError": derivative.ParseError})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, derivative)
+}
+
+// Simplify handles POST requests to simplify an expression
+// It returns the simplified expression as text and as a tree
+func (c *EvaluateController) Simplify(ctx *gin.Context) {
+	var req SimplifyRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		c.logger.Error("Invalid request body",
+			zap.Error(err),
+		)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	simplification, err := c.evaluationService.Simplify(ctx, req.Expression)
+	if err != nil {
+		c.logger.Error("Simplification failed",
+			zap.String("expression", req.Expression),
+			zap.Error(err),
+		)
+		if simplification.ParseError != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "parseError": simplification.ParseError})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, simplification)
+}
+
+// Solve handles POST requests to find a root of an equation in one variable
+// It returns the root with its convergence diagnostics, or why the method failed
+func (c *EvaluateController) Solve(ctx *gin.Context) {
+	var req SolveRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		c.logger.Error("Invalid request body",
+			zap.Error(err),
+		)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	options := evaluator.SolveOptions{
+		Method:        req.Method,
+		Bracket:       req.Bracket,
+		Initial:       req.Initial,
+		Tolerance:     req.Tolerance,
+		MaxIterations: req.MaxIterations,
+	}
+	solution, err := c.evaluationService.Solve(ctx, req.Expression, req.Variable, req.Variables, options)
+	if err != nil {
+		c.logger.Error("Solving failed",
+			zap.String("expression", req.Expression),
+			zap.Error(err),
+		)
+		switch {
+		case solution.ParseError != nil:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "parseError": solution.ParseError})
+		case solution.SolveError != nil:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "solveError": solution.SolveError})
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, solution)
+}
+
+// Plot handles POST requests to sample an expression over a range
+// It returns the points as JSON, or an SVG line chart when the client accepts image/svg+xml
+func (c *EvaluateController) Plot(ctx *gin.Context) {
+	var req PlotRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		c.logger.Error("Invalid request body",
+			zap.Error(err),
+		)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Range) != 2 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "range must have exactly two values [from, to]"})
+		return
+	}
+	if req.Variable == "" {
+		req.Variable = "x"
+	}
+
+	options := evaluator.PlotOptions{From: req.Range[0], To: req.Range[1], Samples: req.Samples, Adaptive: req.Adaptive}
+	plot, err := c.evaluationService.Plot(ctx, req.Expression, req.Variable, req.Variables, options)
+	if err != nil {
+		c.logger.Error("Plotting failed",
+			zap.String("expression", req.Expression),
+			zap.Error(err),
+		)
+		if plot.ParseError != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "parseError": plot.ParseError})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ctx.NegotiateFormat(gin.MIMEJSON, mimeSVG) == mimeSVG {
+		ctx.Data(http.StatusOK, mimeSVG, services.RenderPlotSVG(plot))
+		return
+	}
+	ctx.JSON(http.StatusOK, plot)
+}
+
+// withNow pins the time now() returns when the request supplies one
+func withNow(ctx *gin.Context, now *time.Time) context.Context {
+	if now == nil {
+		return ctx
+	}
+	return services.WithNow(ctx, *now)
+}
+
 // GetHistory handles GET requests to retrieve evaluation history
 // It supports pagination and returns the history in reverse chronological order
 func (c *EvaluateController) GetHistory(ctx *gin.Context) {
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"expression-eval-service/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TestEvaluateVariableDigits checks that variables reach the exact numeric modes with all of their digits
+func TestEvaluateVariableDigits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller := NewEvaluateController(services.NewEvaluationService(zap.NewNop()), zap.NewNop())
+	router := gin.New()
+	router.POST("/evaluate", controller.Evaluate)
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"expression": "n + 1", "variables": {"n": 12345678901234567890123}, "numericMode": "bigint"}`, `"12345678901234567890124"`},
+		{`{"expression": "n + 1", "variables": {"n": "12345678901234567890123"}, "numericMode": "bigint"}`, `"12345678901234567890124"`},
+		{`{"expression": "a + b", "variables": {"a": 0.1, "b": 0.2}, "numericMode": "decimal"}`, `"0.3"`},
+		{`{"expression": "a + b", "variables": {"a": 0.1, "b": 0.2}}`, `0.30000000000000004`},
+		{`{"expression": "n * 2", "variables": {"n": 21}}`, `42`},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(tt.body))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("POST %s returned %d: %s", tt.body, recorder.Code, recorder.Body)
+			continue
+		}
+		var response struct {
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Errorf("POST %s returned invalid JSON: %v", tt.body, err)
+			continue
+		}
+		if string(response.Result) != tt.want {
+			t.Errorf("POST %s result = %s, want %s", tt.body, response.Result, tt.want)
+		}
+	}
+}
+
+func TestEvaluateRejectsInvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller := NewEvaluateController(services.NewEvaluationService(zap.NewNop()), zap.NewNop())
+	router := gin.New()
+	router.POST("/evaluate", controller.Evaluate)
+
+	for _, body := range []string{`{"variables": {"n": 1}}`, `{"expression": "1 +`, ``} {
+		request := httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("POST %q returned %d, want %d", body, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestSimplifyResponses checks that coefficients that would overflow are left unfolded,
+// and that errors carry a parseError only for syntax errors
+func TestSimplifyResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller := NewEvaluateController(services.NewEvaluationService(zap.NewNop()), zap.NewNop())
+	router := gin.New()
+	router.POST("/simplify", controller.Simplify)
+
+	tests := []struct {
+		body       string
+		code       int
+		parseError bool
+	}{
+		{`{"expression": "1e200 * x * 1e200"}`, http.StatusOK, false},
+		{`{"expression": "x * 0.75"}`, http.StatusOK, false},
+		{`{"expression": "x +"}`, http.StatusBadRequest, true},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/simplify", strings.NewReader(tt.body))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != tt.code {
+			t.Errorf("POST %s returned %d, want %d: %s", tt.body, recorder.Code, tt.code, recorder.Body)
+			continue
+		}
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Errorf("POST %s returned invalid JSON: %v", tt.body, err)
+			continue
+		}
+		if _, ok := response["parseError"]; ok != tt.parseError {
+			t.Errorf("POST %s has parseError %v, want %v: %s", tt.body, ok, tt.parseError, recorder.Body)
+		}
+		if simplified := string(response["simplified"]); tt.code == http.StatusOK && (simplified == "" || strings.Contains(simplified, "Inf")) {
+			t.Errorf("POST %s returned simplified expression %s", tt.body, simplified)
+		}
+	}
+}
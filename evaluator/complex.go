@@ -0,0 +1,269 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Complex is a complex number, produced in complex mode
+type Complex complex128
+
+// Kind implements the Value interface for Complex
+func (c Complex) Kind() Kind {
+	return KindComplex
+}
+
+// String implements the Value interface for Complex, e.g. 3+4i, -2i or 5
+func (c Complex) String() string {
+	re, im := real(c), imag(c)
+	switch {
+	case im == 0:
+		return Number(re).String()
+	case re == 0:
+		return Number(im).String() + "i"
+	case im < 0 || math.IsNaN(im):
+		return Number(re).String() + Number(im).String() + "i"
+	default:
+		return Number(re).String() + "+" + Number(im).String() + "i"
+	}
+}
+
+// MarshalJSON encodes the complex number as {"re": 3, "im": 4}
+func (c Complex) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Re Number `json:"re"`
+		Im Number `json:"im"`
+	}{
+		Re: Number(real(c)),
+		Im: Number(imag(c)),
+	})
+}
+
+// toComplex converts a Complex or Number to a complex128
+func toComplex(v Value) (complex128, bool) {
+	switch n := v.(type) {
+	case Complex:
+		return complex128(n), true
+	case Number:
+		return complex(float64(n), 0), true
+	default:
+		return 0, false
+	}
+}
+
+// complexPair converts the operands of a comparison to complex128 when at least one is a Complex
+func complexPair(left, right Value) (complex128, complex128, bool) {
+	_, lok := left.(Complex)
+	_, rok := right.(Complex)
+	if !lok && !rok {
+		return 0, 0, false
+	}
+
+	l, lok := toComplex(left)
+	r, rok := toComplex(right)
+	return l, r, lok && rok
+}
+
+// finiteComplex wraps c as a Value, rejecting infinite and undefined results
+func finiteComplex(c complex128) (Value, error) {
+	if cmplx.IsInf(c) || cmplx.IsNaN(c) {
+		return nil, fmt.Errorf("result out of range")
+	}
+	return Complex(c), nil
+}
+
+// complexNumberSystem makes every number complex and binds the imaginary unit i
+func complexNumberSystem() *numberSystem {
+	return &numberSystem{
+		literal: func(text string) (Value, error) {
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number: %s", text)
+			}
+			return Complex(complex(f, 0)), nil
+		},
+		imaginary: func(text string) (Value, error) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(text, "i"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number: %s", text)
+			}
+			return Complex(complex(0, f)), nil
+		},
+		convert: func(value Value) (Value, error) {
+			if n, ok := value.(Number); ok {
+				return Complex(complex(float64(n), 0)), nil
+			}
+			return value, nil
+		},
+		approximate: func(f float64) (Value, error) {
+			return Complex(complex(f, 0)), nil
+		},
+		constants: map[string]Value{
+			"i": Complex(1i),
+		},
+	}
+}
+
+// complexOperators returns the arithmetic operators of complex mode
+// Floor division and modulo are only defined for real operands
+// Equality uses the built-in operators; ordering is only defined for real values
+func complexOperators() []*Operator {
+	floats := make(map[string]*Operator)
+	for _, op := range builtinOperators() {
+		floats[op.Symbol] = op
+	}
+
+	pow := func(a, b complex128) (Value, error) {
+		if a == 0 && (real(b) < 0 || imag(b) != 0) {
+			return nil, fmt.Errorf("zero raised to a negative or complex power")
+		}
+		return finiteComplex(complexPower(a, b))
+	}
+
+	return []*Operator{
+		complexOperator(floats["+"], func(a, b complex128) (Value, error) {
+			return finiteComplex(a + b)
+		}),
+		complexOperator(floats["-"], func(a, b complex128) (Value, error) {
+			return finiteComplex(a - b)
+		}),
+		complexOperator(floats["*"], func(a, b complex128) (Value, error) {
+			return finiteComplex(a * b)
+		}),
+		complexOperator(floats["/"], func(a, b complex128) (Value, error) {
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return finiteComplex(a / b)
+		}),
+		complexOperator(floats["//"], realOnly(floats["//"])),
+		complexOperator(floats["%"], realOnly(floats["%"])),
+		complexOperator(floats["^"], pow),
+		complexOperator(floats["**"], pow),
+	}
+}
+
+// complexPower raises a to b, multiplying out small integer powers so that i^2 is exactly -1
+func complexPower(a, b complex128) complex128 {
+	n := real(b)
+	if imag(b) != 0 || n != math.Trunc(n) || math.Abs(n) > 1024 {
+		return cmplx.Pow(a, b)
+	}
+
+	result, square := complex128(1), a
+	for m := int(math.Abs(n)); m > 0; m >>= 1 {
+		if m&1 == 1 {
+			result *= square
+		}
+		square *= square
+	}
+	if n < 0 {
+		return 1 / result
+	}
+	return result
+}
+
+// complexOperator creates an operator over complex numbers with the syntax of the float operator
+func complexOperator(float *Operator, fn func(a, b complex128) (Value, error)) *Operator {
+	symbol := float.Symbol
+	return &Operator{
+		Symbol:        symbol,
+		Precedence:    float.Precedence,
+		Associativity: float.Associativity,
+		ValueFn: func(left, right Value) (Value, error) {
+			l, lok := toComplex(left)
+			r, rok := toComplex(right)
+			if !lok || !rok {
+				return nil, operandTypeError(symbol, left, right)
+			}
+			return fn(l, r)
+		},
+	}
+}
+
+// realOnly adapts a float operator to complex operands with no imaginary part
+func realOnly(float *Operator) func(a, b complex128) (Value, error) {
+	return func(a, b complex128) (Value, error) {
+		if imag(a) != 0 || imag(b) != 0 {
+			return nil, fmt.Errorf("operator %s is only defined for real numbers", float.Symbol)
+		}
+
+		result, err := float.apply(Number(real(a)), Number(real(b)))
+		if err != nil {
+			return nil, err
+		}
+		return Complex(complex(float64(result.(Number)), 0)), nil
+	}
+}
+
+// complexFunctions returns the complex-aware functions of complex mode
+// Functions without a complex version accept complex arguments with no imaginary part
+func complexFunctions() map[string]builtinFunction {
+	return map[string]builtinFunction{
+		"re": complexUnary(func(z complex128) (complex128, error) {
+			return complex(real(z), 0), nil
+		}),
+		"im": complexUnary(func(z complex128) (complex128, error) {
+			return complex(imag(z), 0), nil
+		}),
+		"conj": complexUnary(func(z complex128) (complex128, error) {
+			return cmplx.Conj(z), nil
+		}),
+		"arg": complexUnary(func(z complex128) (complex128, error) {
+			return complex(cmplx.Phase(z), 0), nil
+		}),
+		"abs": complexUnary(func(z complex128) (complex128, error) {
+			return complex(cmplx.Abs(z), 0), nil
+		}),
+		"sqrt":  complexUnary(complexTotal(cmplx.Sqrt)),
+		"exp":   complexUnary(complexTotal(cmplx.Exp)),
+		"ln":    complexUnary(complexLogarithm(cmplx.Log)),
+		"log10": complexUnary(complexLogarithm(cmplx.Log10)),
+		"sin":   complexUnary(complexTotal(cmplx.Sin)),
+		"cos":   complexUnary(complexTotal(cmplx.Cos)),
+		"tan":   complexUnary(complexTotal(cmplx.Tan)),
+		"asin":  complexUnary(complexTotal(cmplx.Asin)),
+		"acos":  complexUnary(complexTotal(cmplx.Acos)),
+		"atan":  complexUnary(complexTotal(cmplx.Atan)),
+		"sinh":  complexUnary(complexTotal(cmplx.Sinh)),
+		"cosh":  complexUnary(complexTotal(cmplx.Cosh)),
+		"tanh":  complexUnary(complexTotal(cmplx.Tanh)),
+	}
+}
+
+// complexUnary adapts a single-argument complex function
+func complexUnary(fn func(z complex128) (complex128, error)) builtinFunction {
+	return builtinFunction{minArgs: 1, maxArgs: 1, valueFn: func(args []Value) (Value, error) {
+		z, ok := toComplex(args[0])
+		if !ok {
+			return nil, newTypeError("argument 1 expects a number, got %s", args[0].Kind())
+		}
+
+		result, err := fn(z)
+		if err != nil {
+			return nil, err
+		}
+		return finiteComplex(result)
+	}}
+}
+
+// complexTotal adapts a function defined for every complex argument
+func complexTotal(fn func(z complex128) complex128) func(z complex128) (complex128, error) {
+	return func(z complex128) (complex128, error) {
+		return fn(z), nil
+	}
+}
+
+// complexLogarithm adapts a logarithm, which is undefined at zero
+func complexLogarithm(fn func(z complex128) complex128) func(z complex128) (complex128, error) {
+	return func(z complex128) (complex128, error) {
+		if z == 0 {
+			return 0, fmt.Errorf("argument must be nonzero")
+		}
+		return fn(z), nil
+	}
+}
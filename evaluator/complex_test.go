@@ -0,0 +1,46 @@
+package evaluator
+
+import "testing"
+
+func TestComplexArithmetic(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeComplex})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "i^2", want: "-1"},
+		{expression: "(1+2i)*(3-4i)", want: "11+2i"},
+		{expression: "(1 + 2i) / (3 - 4i)", want: "-0.2+0.4i"},
+		{expression: "abs(3 + 4i)", want: "5"},
+		{expression: "conj(1 + 2i)", want: "1-2i"},
+		{expression: "re(1 + 2i) + im(1 + 2i)", want: "3"},
+		{expression: "sqrt(-1) == i", want: "true"},
+		{expression: "1 / (0 + 0i)", err: "division by zero"},
+		{expression: "0^(-1)", err: "zero raised to a negative or complex power"},
+		{expression: "1i < 2i", err: "operator < cannot be applied to complex and complex"},
+		{expression: "floor(1 + 2i)", err: "argument 1 expects a real number, got 1+2i"},
+	})
+}
+
+// TestComplexBranchCuts checks that multi-valued functions give their principal values, with the branch cuts
+// of math/cmplx: the negative real axis for sqrt, ln and powers, and beyond [-1, 1] for asin and acos
+func TestComplexBranchCuts(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeComplex})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "sqrt(-4)", want: "2i"},
+		{expression: "sqrt(-4 - 0i)", want: "2i"},
+		{expression: "ln(-1)", want: "3.141592653589793i"},
+		{expression: "arg(-1)", want: "3.141592653589793"},
+		{expression: "arg(-1i)", want: "-1.5707963267948966"},
+		{expression: "log10(-100)", want: "2+1.3643763538418412i"},
+		{expression: "(-8)^(1/3)", want: "1+1.732050807568877i"},
+		{expression: "asin(2)", want: "1.5707963267948966+1.3169578969248164i"},
+		{expression: "acos(2)", want: "-1.3169578969248164i"},
+		{expression: "atan(2i)", want: "-1.5707963267948968+0.5493061443340549i"},
+	})
+}
@@ -0,0 +1,519 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	// The zone database is embedded so that time zones work in containers without one
+	_ "time/tzdata"
+)
+
+// day is the length of a day in duration literals and ISO-8601 durations
+const day = 24 * time.Hour
+
+// Date is an instant in time together with the time zone it is shown in
+type Date struct {
+	t time.Time
+}
+
+// Duration is a length of time, such as 30d or 4h
+type Duration time.Duration
+
+// Kind implements the Value interface for Date
+func (d Date) Kind() Kind {
+	return KindDate
+}
+
+// String implements the Value interface for Date, e.g. 2026-10-16T09:30:00+02:00
+func (d Date) String() string {
+	return d.t.Format(time.RFC3339Nano)
+}
+
+// MarshalJSON encodes the date as an ISO-8601 string
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Kind implements the Value interface for Duration
+func (d Duration) Kind() Kind {
+	return KindDuration
+}
+
+// String implements the Value interface for Duration as an ISO-8601 duration, e.g. P30D or PT4H15M
+// Days are always 24 hours long, so durations are never written with weeks, months or years
+func (d Duration) String() string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var builder strings.Builder
+	remaining := time.Duration(d)
+	if remaining < 0 {
+		builder.WriteByte('-')
+		remaining = -remaining
+	}
+	builder.WriteByte('P')
+
+	if days := remaining / day; days > 0 {
+		builder.WriteString(strconv.FormatInt(int64(days), 10) + "D")
+		remaining -= days * day
+	}
+	if remaining > 0 {
+		builder.WriteByte('T')
+	}
+	if hours := remaining / time.Hour; hours > 0 {
+		builder.WriteString(strconv.FormatInt(int64(hours), 10) + "H")
+		remaining -= hours * time.Hour
+	}
+	if minutes := remaining / time.Minute; minutes > 0 {
+		builder.WriteString(strconv.FormatInt(int64(minutes), 10) + "M")
+		remaining -= minutes * time.Minute
+	}
+	if remaining > 0 {
+		builder.WriteString(strconv.FormatFloat(remaining.Seconds(), 'f', -1, 64) + "S")
+	}
+	return builder.String()
+}
+
+// MarshalJSON encodes the duration as an ISO-8601 string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// durationUnits maps the suffixes of duration literals to their length
+// Longer suffixes are listed first so that 5ms is not read as 5 minutes followed by s
+var durationUnits = []struct {
+	suffix string
+	length time.Duration
+}{
+	{"min", time.Minute},
+	{"ms", time.Millisecond},
+	{"w", 7 * day},
+	{"d", day},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// scanDuration returns the length of the duration literal at the start of text, or 0 if there is none
+// A duration literal is one or more numbers each followed by a unit, as in 30d, 4h or 1h30m
+func scanDuration(text string) int {
+	end := 0
+	for {
+		digits := strings.IndexFunc(text[end:], func(c rune) bool { return !isDigit(c) && c != '.' })
+		if digits <= 0 {
+			break
+		}
+
+		matched := false
+		for _, unit := range durationUnits {
+			if strings.HasPrefix(text[end+digits:], unit.suffix) {
+				end += digits + len(unit.suffix)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+	}
+	return end
+}
+
+// ambiguousMinutes reports whether a duration literal is a number of minutes written with m alone, as in 15m,
+// which reads just as well as a length in metres; within a longer literal such as 1h30m the m is unambiguous
+func ambiguousMinutes(text string) bool {
+	digits := strings.IndexFunc(text, func(c rune) bool { return !isDigit(c) && c != '.' })
+	return digits > 0 && text[digits:] == "m"
+}
+
+// parseDuration converts a duration literal such as 30d or 1h30m into a Duration
+func parseDuration(text string) (Value, error) {
+	var total float64
+	for rest := text; rest != ""; {
+		digits := strings.IndexFunc(rest, func(c rune) bool { return !isDigit(c) && c != '.' })
+		if digits <= 0 {
+			return nil, fmt.Errorf("invalid duration: %s", text)
+		}
+		n, err := strconv.ParseFloat(rest[:digits], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration: %s", text)
+		}
+
+		matched := false
+		for _, unit := range durationUnits {
+			if strings.HasPrefix(rest[digits:], unit.suffix) {
+				total += n * float64(unit.length)
+				rest = rest[digits+len(unit.suffix):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("invalid duration: %s", text)
+		}
+	}
+	return durationOf(total)
+}
+
+// durationOf converts a number of nanoseconds into a Duration, rejecting durations
+// longer than about 292 years, which time.Duration cannot represent
+func durationOf(nanoseconds float64) (Value, error) {
+	if math.IsNaN(nanoseconds) || math.Abs(nanoseconds) >= math.MaxInt64 {
+		return nil, fmt.Errorf("duration out of range")
+	}
+	return Duration(math.Round(nanoseconds)), nil
+}
+
+// dateLayouts lists the ISO-8601 forms accepted by date(), from most to least specific
+var dateLayouts = []struct {
+	layout string
+	zoned  bool // the layout carries its own UTC offset
+}{
+	{time.RFC3339Nano, true},
+	{"2006-01-02T15:04Z07:00", true},
+	{"2006-01-02T15:04:05.999999999", false},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02", false},
+}
+
+// parseDate parses an ISO-8601 date or date and time
+// Times without a UTC offset are in location; times with one are shown in location
+func parseDate(text string, location *time.Location) (Date, error) {
+	text = strings.TrimSpace(text)
+	for _, format := range dateLayouts {
+		t, err := time.ParseInLocation(format.layout, text, location)
+		if err != nil {
+			continue
+		}
+		if format.zoned {
+			t = t.In(location)
+		} else if wall, err := time.Parse(format.layout, text); err == nil {
+			t = skipGap(t, wall)
+		}
+		return Date{t: t}, nil
+	}
+	return Date{}, fmt.Errorf("invalid date: %q is not an ISO-8601 date such as 2026-10-16 or 2026-10-16T09:30:00Z", text)
+}
+
+// loadZone returns the time zone named by an IANA name such as Europe/Paris or UTC
+func loadZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone: %q", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %q", name)
+	}
+	return location, nil
+}
+
+// asDate returns v as a Date or a TypeError naming what needed a date
+// Strings are accepted and parsed as ISO-8601 dates in UTC, so dates can be passed as variables
+func asDate(v Value, context string) (Date, error) {
+	switch d := v.(type) {
+	case Date:
+		return d, nil
+	case String:
+		return parseDate(string(d), time.UTC)
+	}
+	return Date{}, newTypeError("%s expects a date, got %s", context, v.Kind())
+}
+
+// asDuration returns v as a Duration or a TypeError naming what needed a duration
+func asDuration(v Value, context string) (Duration, error) {
+	if d, ok := v.(Duration); ok {
+		return d, nil
+	}
+	return 0, newTypeError("%s expects a duration, got %s", context, v.Kind())
+}
+
+// isTemporal reports whether v is a Date or a Duration
+func isTemporal(v Value) bool {
+	switch v.(type) {
+	case Date, Duration:
+		return true
+	default:
+		return false
+	}
+}
+
+// addDuration moves t by d; whole days are calendar days in t's time zone,
+// so adding 1d across a daylight saving change keeps the time of day
+func addDuration(t time.Time, d Duration) Date {
+	days := time.Duration(d) / day
+	year, month, dayOfMonth := t.Date()
+	wall := time.Date(year, month, dayOfMonth+int(days), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return Date{t: skipGap(t.AddDate(0, 0, int(days)), wall).Add(time.Duration(d) - days*day)}
+}
+
+// skipGap moves t, which was meant to show the wall-clock time of wall in its own time zone, past a
+// daylight saving change that skipped that time
+// Go resolves 02:30 on a night the clocks jump from 02:00 to 03:00 to 01:30; like most calendars,
+// the evaluator moves it forward by the length of the gap instead, to 03:30
+func skipGap(t, wall time.Time) time.Time {
+	shown := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if gap := wall.Sub(shown); gap > 0 {
+		return t.Add(gap)
+	}
+	return t
+}
+
+// temporalOperators holds the arithmetic operators that accept dates and durations, by symbol
+// They are used by the built-in operators whenever an operand is a Date or a Duration
+var temporalOperators = map[string]ValueOperatorFunc{
+	"+": func(left, right Value) (Value, error) {
+		switch l := left.(type) {
+		case Date:
+			if r, ok := right.(Duration); ok {
+				return addDuration(l.t, r), nil
+			}
+		case Duration:
+			switch r := right.(type) {
+			case Date:
+				return addDuration(r.t, l), nil
+			case Duration:
+				return durationOf(float64(l) + float64(r))
+			}
+		}
+		return nil, operandTypeError("+", left, right)
+	},
+	"-": func(left, right Value) (Value, error) {
+		switch l := left.(type) {
+		case Date:
+			switch r := right.(type) {
+			case Duration:
+				return addDuration(l.t, -r), nil
+			case Date:
+				return durationOf(float64(l.t.Sub(r.t)))
+			}
+		case Duration:
+			if r, ok := right.(Duration); ok {
+				return durationOf(float64(l) - float64(r))
+			}
+		}
+		return nil, operandTypeError("-", left, right)
+	},
+	"*": func(left, right Value) (Value, error) {
+		if _, ok := right.(Duration); ok {
+			left, right = right, left
+		}
+		d, ok := left.(Duration)
+		if !ok {
+			return nil, operandTypeError("*", left, right)
+		}
+		factor, err := asNumber(right, "operator *")
+		if err != nil {
+			return nil, err
+		}
+		return durationOf(float64(d) * factor)
+	},
+	"/": func(left, right Value) (Value, error) {
+		d, ok := left.(Duration)
+		if !ok {
+			return nil, operandTypeError("/", left, right)
+		}
+		if r, ok := right.(Duration); ok {
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return Number(float64(d) / float64(r)), nil
+		}
+		divisor, err := asNumber(right, "operator /")
+		if err != nil {
+			return nil, err
+		}
+		if divisor == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return durationOf(float64(d) / divisor)
+	},
+}
+
+// withTemporal extends a numeric mode's arithmetic operator to dates and durations
+func withTemporal(op *Operator) *Operator {
+	temporal, ok := temporalOperators[op.Symbol]
+	if !ok {
+		return op
+	}
+
+	extended := *op
+	extended.ValueFn = func(left, right Value) (Value, error) {
+		if isTemporal(left) || isTemporal(right) {
+			return temporal(left, right)
+		}
+		return op.apply(left, right)
+	}
+	extended.Fn = nil
+	return &extended
+}
+
+// dateBuiltins holds the date and duration functions available to every expression
+// The current time is read with now(), which the parser handles like if()
+var dateBuiltins = map[string]builtinFunction{
+	"date": {minArgs: 1, maxArgs: 2, valueFn: func(args []Value) (Value, error) {
+		text, err := asString(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		location, err := zoneArgument(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return parseDate(text, location)
+	}},
+	"duration": {minArgs: 1, maxArgs: 1, valueFn: func(args []Value) (Value, error) {
+		text, err := asString(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		return parseISODuration(text)
+	}},
+	"inZone": {minArgs: 2, maxArgs: 2, valueFn: func(args []Value) (Value, error) {
+		d, err := asDate(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		location, err := zoneArgument(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return Date{t: d.t.In(location)}, nil
+	}},
+	"daysBetween": {minArgs: 2, maxArgs: 2, valueFn: func(args []Value) (Value, error) {
+		start, err := asDate(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		end, err := asDate(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		return Number(calendarDay(end.t.In(start.t.Location())) - calendarDay(start.t)), nil
+	}},
+	"weekday": dateField(func(t time.Time) Value { return String(t.Weekday().String()) }),
+	"year":    dateField(func(t time.Time) Value { return Number(t.Year()) }),
+	"month":   dateField(func(t time.Time) Value { return Number(t.Month()) }),
+	"day":     dateField(func(t time.Time) Value { return Number(t.Day()) }),
+	"hour":    dateField(func(t time.Time) Value { return Number(t.Hour()) }),
+	"minute":  dateField(func(t time.Time) Value { return Number(t.Minute()) }),
+	"second":  dateField(func(t time.Time) Value { return Number(t.Second()) }),
+	"days":    durationIn(day),
+	"hours":   durationIn(time.Hour),
+	"minutes": durationIn(time.Minute),
+	"seconds": durationIn(time.Second),
+}
+
+// dateField creates a function returning a field of a date in the date's time zone,
+// or in the zone named by an optional second argument
+func dateField(fn func(t time.Time) Value) builtinFunction {
+	return builtinFunction{
+		minArgs: 1,
+		maxArgs: 2,
+		valueFn: func(args []Value) (Value, error) {
+			d, err := asDate(args[0], "argument 1")
+			if err != nil {
+				return nil, err
+			}
+			location := d.t.Location()
+			if len(args) > 1 {
+				if location, err = zoneArgument(args, 1); err != nil {
+					return nil, err
+				}
+			}
+			return fn(d.t.In(location)), nil
+		},
+	}
+}
+
+// durationIn creates a function converting a duration to a number of units
+func durationIn(unit time.Duration) builtinFunction {
+	return builtinFunction{
+		minArgs: 1,
+		maxArgs: 1,
+		valueFn: func(args []Value) (Value, error) {
+			d, err := asDuration(args[0], "argument 1")
+			if err != nil {
+				return nil, err
+			}
+			return Number(float64(d) / float64(unit)), nil
+		},
+	}
+}
+
+// zoneArgument returns the time zone named by args[i], or UTC if there is no such argument
+func zoneArgument(args []Value, i int) (*time.Location, error) {
+	if len(args) <= i {
+		return time.UTC, nil
+	}
+	name, err := asString(args[i], fmt.Sprintf("argument %d", i+1))
+	if err != nil {
+		return nil, err
+	}
+	return loadZone(name)
+}
+
+// calendarDay numbers the calendar day of t in its own time zone, counting from the Unix epoch
+func calendarDay(t time.Time) int64 {
+	year, month, dayOfMonth := t.Date()
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+}
+
+// parseISODuration parses an ISO-8601 duration such as P30D, PT4H15M or -P1DT12H
+// Weeks and days are accepted but years and months are not, since their length varies
+func parseISODuration(text string) (Value, error) {
+	invalid := fmt.Errorf("invalid duration: %q is not an ISO-8601 duration such as P30D or PT4H15M", text)
+
+	rest, sign := strings.TrimSpace(text), 1.0
+	if strings.HasPrefix(rest, "-") {
+		rest, sign = rest[1:], -1
+	}
+	if !strings.HasPrefix(rest, "P") || len(rest) < 2 {
+		return nil, invalid
+	}
+	rest = rest[1:]
+
+	var total float64
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' && !inTime {
+			inTime, rest = true, rest[1:]
+			if rest == "" {
+				return nil, invalid
+			}
+			continue
+		}
+
+		digits := strings.IndexFunc(rest, func(c rune) bool { return !isDigit(c) && c != '.' })
+		if digits <= 0 {
+			return nil, invalid
+		}
+		n, err := strconv.ParseFloat(rest[:digits], 64)
+		if err != nil {
+			return nil, invalid
+		}
+
+		var unit time.Duration
+		switch designator := rest[digits]; {
+		case !inTime && designator == 'W':
+			unit = 7 * day
+		case !inTime && designator == 'D':
+			unit = day
+		case inTime && designator == 'H':
+			unit = time.Hour
+		case inTime && designator == 'M':
+			unit = time.Minute
+		case inTime && designator == 'S':
+			unit = time.Second
+		default:
+			return nil, invalid
+		}
+		total += n * float64(unit)
+		rest = rest[digits+1:]
+	}
+	return durationOf(sign * total)
+}
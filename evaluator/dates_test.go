@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateParsing(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: `date("2026-10-16")`, want: "2026-10-16T00:00:00Z"},
+		{expression: `date("2026-10-16T09:30")`, want: "2026-10-16T09:30:00Z"},
+		{expression: `date("2026-10-16T09:30:15.25")`, want: "2026-10-16T09:30:15.25Z"},
+		{expression: `date("2026-10-16T09:30:00+02:00")`, want: "2026-10-16T07:30:00Z"},
+		{expression: `date(" 2026-10-16 ")`, want: "2026-10-16T00:00:00Z"},
+		{expression: `date("2026-02-30")`, err: `invalid date: "2026-02-30" is not an ISO-8601 date`},
+		{expression: `date("16/10/2026")`, err: `invalid date: "16/10/2026" is not an ISO-8601 date`},
+		{expression: "date(20261016)", err: "argument 1 expects a string, got number"},
+		{expression: `duration("P1DT12H")`, want: "P1DT12H"},
+		{expression: `duration("-PT4H15M")`, want: "-PT4H15M"},
+		{expression: `duration("P2W")`, want: "P14D"},
+		{expression: `duration("P1Y")`, err: `invalid duration: "P1Y" is not an ISO-8601 duration`},
+		{expression: `duration("PT")`, err: "invalid duration"},
+		{expression: "30d", want: "P30D"},
+		{expression: "1h30m", want: "PT1H30M"},
+		{expression: "15min", want: "PT15M"},
+		{expression: "15m", err: "ambiguous literal 15m: write 15min for minutes or 15 m for metres"},
+	})
+}
+
+func TestDurationArithmetic(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: `date("2026-10-16") + 30d`, want: "2026-11-15T00:00:00Z"},
+		{expression: `30d + date("2026-10-16")`, want: "2026-11-15T00:00:00Z"},
+		{expression: `date("2026-10-16") - 1d1h`, want: "2026-10-14T23:00:00Z"},
+		{expression: `date("2026-01-31") + 1w`, want: "2026-02-07T00:00:00Z"},
+		{expression: `date("2026-12-25") - date("2026-10-16")`, want: "P70D"},
+		{expression: `daysBetween("2026-10-16", "2026-12-25")`, want: "70"},
+		{expression: `daysBetween("2026-12-25", "2026-10-16")`, want: "-70"},
+		{expression: "1h30m + 15min", want: "PT1H45M"},
+		{expression: "1h - 2h", want: "-PT1H"},
+		{expression: "2 * 4h", want: "PT8H"},
+		{expression: "1d / 4", want: "PT6H"},
+		{expression: "1d / 1h", want: "24"},
+		{expression: "days(36h)", want: "1.5"},
+		{expression: "minutes(1h30m)", want: "90"},
+		{expression: "30d > 4w", want: "true"},
+		{expression: "-2h", want: "-PT2H"},
+		{expression: "1d * 1e9", err: "duration out of range"},
+		{expression: "1d / 0", err: "division by zero"},
+		{expression: "1d / 0d", err: "division by zero"},
+		{expression: `date("2026-10-16") + 1`, err: "operator + cannot be applied to date and number"},
+		{expression: `date("2026-10-16") + date("2026-10-17")`, err: "operator + cannot be applied to date and date"},
+		{expression: "1d * 1d", err: "operator * expects a number, got duration"},
+	})
+}
+
+// TestTimeZones checks that dates keep their time zone and that whole days are calendar days across
+// daylight saving changes: Paris falls back from 03:00 to 02:00 on 2026-10-25, and New York
+// springs forward from 02:00 to 03:00 on 2026-03-08
+func TestTimeZones(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: `date("2026-10-16T09:30", "Europe/Paris")`, want: "2026-10-16T09:30:00+02:00"},
+		{expression: `date("2026-10-16T09:30:00Z", "Asia/Tokyo")`, want: "2026-10-16T18:30:00+09:00"},
+		{expression: `inZone(date("2026-10-16T09:30:00Z"), "America/New_York")`, want: "2026-10-16T05:30:00-04:00"},
+		{expression: `hour(date("2026-10-16T09:30:00Z"), "Asia/Tokyo")`, want: "18"},
+		{expression: `weekday(date("2026-10-16"))`, want: "Friday"},
+		{expression: `weekday(date("2026-10-16T23:30:00Z"), "Asia/Tokyo")`, want: "Saturday"},
+		{expression: `daysBetween(date("2026-10-16T23:30", "America/New_York"), "2026-10-18")`, want: "1"},
+		{expression: `date("2026-10-16", "Mars/Olympus")`, err: `unknown time zone: "Mars/Olympus"`},
+		{expression: `inZone(date("2026-10-16"), "Local")`, err: `unknown time zone: "Local"`},
+
+		{expression: `date("2026-10-24T12:00", "Europe/Paris") + 1d`, want: "2026-10-25T12:00:00+01:00"},
+		{expression: `date("2026-10-24T12:00", "Europe/Paris") + 1d1h`, want: "2026-10-25T13:00:00+01:00"},
+		{expression: `date("2026-10-25T00:00", "Europe/Paris") + 4h`, want: "2026-10-25T03:00:00+01:00"},
+		{expression: `date("2026-10-25T12:00", "Europe/Paris") - date("2026-10-24T12:00", "Europe/Paris")`, want: "P1DT1H"},
+		{expression: `daysBetween(date("2026-10-24T12:00", "Europe/Paris"), date("2026-10-25T12:00", "Europe/Paris"))`, want: "1"},
+		{expression: `date("2026-03-07T09:00", "America/New_York") + 1d`, want: "2026-03-08T09:00:00-04:00"},
+		{expression: `date("2026-03-08T09:00", "America/New_York") - date("2026-03-07T09:00", "America/New_York")`, want: "PT23H"},
+		{expression: `date("2026-03-08T02:30", "America/New_York")`, want: "2026-03-08T03:30:00-04:00"},
+		{expression: `date("2026-03-07T02:30", "America/New_York") + 1d`, want: "2026-03-08T03:30:00-04:00"},
+		{expression: `date("2026-03-08T01:30", "America/New_York") + 1h`, want: "2026-03-08T03:30:00-04:00"},
+	})
+}
+
+// TestNowIsPinned checks that now() returns the pinned time, and that without one every call
+// within an evaluation sees the same time
+func TestNowIsPinned(t *testing.T) {
+	pinned := NewEnvironment(nil).WithNow(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	checkOutcomes(t, nil, pinned, []outcome{
+		{expression: "now()", want: "2026-10-16T09:00:00Z"},
+		{expression: "weekday(now() + 30d)", want: "Sunday"},
+		{expression: `daysBetween(now(), "2026-12-25")`, want: "70"},
+	})
+
+	for _, expression := range []string{
+		"now() == now()",
+		"now() - now() == 0d",
+		"all(map([1, 2, 3], k -> now()), t -> t == now())",
+		"sum(seconds(now() - now()), k, 1, 100) == 0",
+	} {
+		program, err := Compile(expression)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expression, err)
+		}
+		for _, evaluate := range []func(*Environment) (Value, error){program.Evaluate, program.EvaluateTree} {
+			if value, err := evaluate(nil); err != nil || value != Bool(true) {
+				t.Errorf("Evaluate(%q) = %v, %v, want true", expression, value, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,606 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how decimal results are rounded to the working precision
+type RoundingMode string
+
+const (
+	// RoundHalfEven rounds to the nearest value and ties to an even last digit
+	RoundHalfEven RoundingMode = "half-even"
+	// RoundHalfUp rounds to the nearest value and ties away from zero
+	RoundHalfUp RoundingMode = "half-up"
+	// RoundDown truncates towards zero
+	RoundDown RoundingMode = "down"
+)
+
+// valid reports whether m is one of the supported rounding modes
+func (m RoundingMode) valid() bool {
+	return m == RoundHalfEven || m == RoundHalfUp || m == RoundDown
+}
+
+// Limits on the decimal working precision, in significant digits
+const (
+	DefaultDecimalPrecision = 34
+	MaxDecimalPrecision     = 1000
+)
+
+// maxDecimalExponent bounds the magnitude of decimal results, so an expression
+// such as 1e999999 + 1 cannot allocate an enormous coefficient
+const maxDecimalExponent = 10000
+
+// Decimal is an exact base-10 number with the value coefficient × 10^exponent
+// Decimals are immutable; every operation returns a new value
+type Decimal struct {
+	coefficient *big.Int
+	exponent    int
+}
+
+// Kind implements the Value interface for Decimal
+func (d Decimal) Kind() Kind {
+	return KindDecimal
+}
+
+// String implements the Value interface for Decimal
+// Numbers of moderate size are written in plain notation, others in scientific notation
+func (d Decimal) String() string {
+	c := d.coef()
+	digits := new(big.Int).Abs(c).String()
+	sign := ""
+	if c.Sign() < 0 {
+		sign = "-"
+	}
+
+	adjusted := d.exponent + len(digits) - 1
+	switch {
+	case d.exponent <= 0 && adjusted >= -6:
+		point := len(digits) + d.exponent
+		if d.exponent == 0 {
+			return sign + digits
+		}
+		if point > 0 {
+			return sign + digits[:point] + "." + digits[point:]
+		}
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	case d.exponent > 0 && adjusted < 21:
+		return sign + digits + strings.Repeat("0", d.exponent)
+	default:
+		mantissa := digits[:1]
+		if len(digits) > 1 {
+			mantissa += "." + digits[1:]
+		}
+		return fmt.Sprintf("%s%se%+d", sign, mantissa, adjusted)
+	}
+}
+
+// MarshalJSON encodes the decimal as a JSON string so no digits are lost
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// coef returns the coefficient, treating the zero Decimal as 0
+func (d Decimal) coef() *big.Int {
+	if d.coefficient == nil {
+		return new(big.Int)
+	}
+	return d.coefficient
+}
+
+// float returns the nearest float64 to d
+func (d Decimal) float() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// neg returns -d
+func (d Decimal) neg() Decimal {
+	return Decimal{coefficient: new(big.Int).Neg(d.coef()), exponent: d.exponent}
+}
+
+// cmp returns -1, 0 or 1 as d is less than, equal to or greater than other
+func (d Decimal) cmp(other Decimal) int {
+	l, r, _ := alignDecimals(d, other)
+	return l.Cmp(r)
+}
+
+// integer returns d as an int64 when it is a whole number in range
+func (d Decimal) integer() (int64, bool) {
+	c := d.coef()
+	if d.exponent >= 0 {
+		if d.exponent > 18 && c.Sign() != 0 {
+			return 0, false
+		}
+		n := new(big.Int).Mul(c, pow10(d.exponent))
+		return n.Int64(), n.IsInt64()
+	}
+
+	q, r := new(big.Int).QuoRem(c, pow10(-d.exponent), new(big.Int))
+	return q.Int64(), r.Sign() == 0 && q.IsInt64()
+}
+
+// parseDecimal parses decimal text such as 12, -0.1, .5 or 1.5e-3
+func parseDecimal(text string) (Decimal, error) {
+	mantissa, exponent := text, 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		e, err := strconv.Atoi(text[i+1:])
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("invalid number: %s", text)
+		}
+		mantissa, exponent = text[:i], e
+	}
+
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		exponent -= len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+
+	coefficient, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid number: %s", text)
+	}
+	return Decimal{coefficient: coefficient, exponent: exponent}, nil
+}
+
+// decimalFromFloat converts f to the decimal with its shortest round-tripping digits,
+// so the float64 nearest to 0.1 becomes exactly 0.1
+func decimalFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("%v cannot be represented as a decimal", f)
+	}
+	return parseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// toDecimal converts a numeric value to a Decimal
+func toDecimal(v Value, context string) (Decimal, error) {
+	switch n := v.(type) {
+	case Decimal:
+		return n, nil
+	case Number:
+		return decimalFromFloat(float64(n))
+	default:
+		return Decimal{}, newTypeError("%s expects a number, got %s", context, v.Kind())
+	}
+}
+
+// decimalPair converts the operands of a comparison to decimals when at least one is a Decimal
+func decimalPair(left, right Value) (Decimal, Decimal, bool) {
+	_, lok := left.(Decimal)
+	_, rok := right.(Decimal)
+	if !lok && !rok {
+		return Decimal{}, Decimal{}, false
+	}
+
+	l, err := toDecimal(left, "")
+	if err != nil {
+		return Decimal{}, Decimal{}, false
+	}
+	r, err := toDecimal(right, "")
+	if err != nil {
+		return Decimal{}, Decimal{}, false
+	}
+	return l, r, true
+}
+
+// alignDecimals returns the coefficients of a and b scaled to their common, smaller exponent
+func alignDecimals(a, b Decimal) (*big.Int, *big.Int, int) {
+	ac, bc := a.coef(), b.coef()
+	switch {
+	case a.exponent > b.exponent:
+		return new(big.Int).Mul(ac, pow10(a.exponent-b.exponent)), bc, b.exponent
+	case a.exponent < b.exponent:
+		return ac, new(big.Int).Mul(bc, pow10(b.exponent-a.exponent)), a.exponent
+	default:
+		return ac, bc, a.exponent
+	}
+}
+
+// pow10 returns 10^n for n >= 0
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// digitCount returns the number of decimal digits in the magnitude of c
+func digitCount(c *big.Int) int {
+	return len(new(big.Int).Abs(c).String())
+}
+
+// decimalContext holds the precision and rounding applied to every decimal result
+type decimalContext struct {
+	precision int
+	rounding  RoundingMode
+}
+
+// numberSystem parses literals as decimals and converts bound numbers to decimals
+func (ctx decimalContext) numberSystem() *numberSystem {
+	return &numberSystem{
+		literal: func(text string) (Value, error) {
+			d, err := parseDecimal(text)
+			if err != nil {
+				return nil, err
+			}
+			return ctx.round(d.coef(), d.exponent, false)
+		},
+		convert: func(value Value) (Value, error) {
+			if n, ok := value.(Number); ok {
+				d, err := decimalFromFloat(float64(n))
+				if err != nil {
+					return nil, err
+				}
+				return ctx.round(d.coef(), d.exponent, false)
+			}
+			return value, nil
+		},
+		approximate: func(f float64) (Value, error) {
+			return ctx.fromFloat(f)
+		},
+		constants: ctx.constants(),
+	}
+}
+
+// round rounds the number c × 10^exponent to the context precision
+// sticky reports that nonzero digits below c were already discarded, as after an inexact division
+func (ctx decimalContext) round(c *big.Int, exponent int, sticky bool) (Decimal, error) {
+	c = new(big.Int).Set(c)
+	if excess := digitCount(c) - ctx.precision; excess > 0 {
+		c = ctx.shiftRound(c, excess, sticky)
+		exponent += excess
+		if digitCount(c) > ctx.precision {
+			c.Quo(c, big.NewInt(10))
+			exponent++
+		}
+	}
+
+	if c.Sign() != 0 {
+		adjusted := exponent + digitCount(c) - 1
+		if adjusted > maxDecimalExponent || adjusted < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("decimal result out of range")
+		}
+	}
+	return Decimal{coefficient: c, exponent: exponent}, nil
+}
+
+// shiftRound divides c by 10^places, rounding the discarded digits with the context rounding mode
+func (ctx decimalContext) shiftRound(c *big.Int, places int, sticky bool) *big.Int {
+	negative := c.Sign() < 0
+	divisor := pow10(places)
+	q, r := new(big.Int).QuoRem(new(big.Int).Abs(c), divisor, new(big.Int))
+
+	// half compares the discarded part with half a unit of the last kept digit
+	half := new(big.Int).Lsh(r, 1).Cmp(divisor)
+	if half == 0 && sticky {
+		half = 1
+	}
+
+	var up bool
+	switch ctx.rounding {
+	case RoundHalfUp:
+		up = half >= 0
+	case RoundHalfEven:
+		up = half > 0 || (half == 0 && q.Bit(0) == 1)
+	}
+	if up {
+		q.Add(q, big.NewInt(1))
+	}
+
+	if negative {
+		q.Neg(q)
+	}
+	return q
+}
+
+// add returns a + b
+func (ctx decimalContext) add(a, b Decimal) (Decimal, error) {
+	l, r, exponent := alignDecimals(a, b)
+	return ctx.round(new(big.Int).Add(l, r), exponent, false)
+}
+
+// sub returns a - b
+func (ctx decimalContext) sub(a, b Decimal) (Decimal, error) {
+	l, r, exponent := alignDecimals(a, b)
+	return ctx.round(new(big.Int).Sub(l, r), exponent, false)
+}
+
+// mul returns a × b
+func (ctx decimalContext) mul(a, b Decimal) (Decimal, error) {
+	return ctx.round(new(big.Int).Mul(a.coef(), b.coef()), a.exponent+b.exponent, false)
+}
+
+// div returns a / b rounded to the context precision
+// Exact quotients drop trailing zeros, so 1 / 4 is 0.25
+func (ctx decimalContext) div(a, b Decimal) (Decimal, error) {
+	if b.coef().Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+
+	ideal := a.exponent - b.exponent
+	shift := ctx.precision + digitCount(b.coef()) - digitCount(a.coef()) + 1
+	if shift < 0 {
+		shift = 0
+	}
+
+	q, r := new(big.Int).QuoRem(new(big.Int).Mul(a.coef(), pow10(shift)), b.coef(), new(big.Int))
+	exponent := ideal - shift
+	if r.Sign() == 0 {
+		ten, digit := big.NewInt(10), new(big.Int)
+		for exponent < ideal {
+			reduced, _ := new(big.Int).QuoRem(q, ten, digit)
+			if digit.Sign() != 0 {
+				break
+			}
+			q = reduced
+			exponent++
+		}
+	}
+	return ctx.round(q, exponent, r.Sign() != 0)
+}
+
+// floorDiv returns a // b, the quotient rounded towards negative infinity
+func (ctx decimalContext) floorDiv(a, b Decimal) (Decimal, error) {
+	if b.coef().Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+
+	l, r, _ := alignDecimals(a, b)
+	return ctx.round(floorQuo(l, r), 0, false)
+}
+
+// mod returns a % b, which takes the sign of the divisor like float mode
+func (ctx decimalContext) mod(a, b Decimal) (Decimal, error) {
+	if b.coef().Sign() == 0 {
+		return Decimal{}, fmt.Errorf("modulo by zero")
+	}
+
+	l, r, exponent := alignDecimals(a, b)
+	remainder := new(big.Int).Sub(l, new(big.Int).Mul(r, floorQuo(l, r)))
+	return ctx.round(remainder, exponent, false)
+}
+
+// floorQuo returns the quotient of a and b rounded towards negative infinity
+func floorQuo(a, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 && r.Sign() != b.Sign() {
+		q.Sub(q, big.NewInt(1))
+	}
+	return q
+}
+
+// pow returns base raised to exponent
+// Integer exponents are computed exactly up to the precision; others through logarithms at the working precision
+func (ctx decimalContext) pow(base, exponent Decimal) (Decimal, error) {
+	n, ok := exponent.integer()
+	if !ok {
+		return ctx.fractionalPow(base, exponent)
+	}
+
+	if base.coef().Sign() == 0 && n < 0 {
+		return Decimal{}, fmt.Errorf("zero raised to a negative power")
+	}
+
+	// Intermediate products carry guard digits so the final rounding is accurate
+	work := decimalContext{precision: ctx.precision + 10, rounding: RoundHalfEven}
+	result := Decimal{coefficient: big.NewInt(1)}
+	square := base
+	var err error
+	for m := abs64(n); m > 0; m >>= 1 {
+		if m&1 == 1 {
+			if result, err = work.mul(result, square); err != nil {
+				return Decimal{}, err
+			}
+		}
+		if m > 1 {
+			if square, err = work.mul(square, square); err != nil {
+				return Decimal{}, err
+			}
+		}
+	}
+
+	if n < 0 {
+		return ctx.div(Decimal{coefficient: big.NewInt(1)}, result)
+	}
+	return ctx.round(result.coef(), result.exponent, false)
+}
+
+// abs64 returns the magnitude of n
+func abs64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
+
+// sqrt returns the square root of d rounded to the context precision
+func (ctx decimalContext) sqrt(d Decimal) (Decimal, error) {
+	c := d.coef()
+	if c.Sign() < 0 {
+		return Decimal{}, fmt.Errorf("argument must be non-negative")
+	}
+
+	// Scale the coefficient so its root has more digits than the precision
+	// and the exponent is even
+	shift := 2*ctx.precision + 2 - digitCount(c)
+	if shift < 0 {
+		shift = 0
+	}
+	if (d.exponent-shift)%2 != 0 {
+		shift++
+	}
+
+	scaled := new(big.Int).Mul(c, pow10(shift))
+	root := new(big.Int).Sqrt(scaled)
+	exact := new(big.Int).Mul(root, root).Cmp(scaled) == 0
+	exponent := (d.exponent - shift) / 2
+
+	if exact {
+		ideal := floorHalf(d.exponent)
+		ten, digit := big.NewInt(10), new(big.Int)
+		for exponent < ideal {
+			reduced, _ := new(big.Int).QuoRem(root, ten, digit)
+			if digit.Sign() != 0 {
+				break
+			}
+			root = reduced
+			exponent++
+		}
+	}
+	return ctx.round(root, exponent, !exact)
+}
+
+// floorHalf returns n / 2 rounded towards negative infinity
+func floorHalf(n int) int {
+	if n < 0 {
+		return -((-n + 1) / 2)
+	}
+	return n / 2
+}
+
+// quantize rounds d to the given number of digits after the decimal point using the context rounding
+// Negative places round to tens, hundreds and so on
+func (ctx decimalContext) quantize(d Decimal, places int) (Decimal, error) {
+	if d.exponent >= -places {
+		return d, nil
+	}
+	c := ctx.shiftRound(d.coef(), -places-d.exponent, false)
+	return ctx.round(c, -places, false)
+}
+
+// floor returns the largest integer not greater than d
+func (ctx decimalContext) floor(d Decimal) (Decimal, error) {
+	if d.exponent >= 0 {
+		return d, nil
+	}
+	return ctx.round(floorQuo(d.coef(), pow10(-d.exponent)), 0, false)
+}
+
+// ceil returns the smallest integer not less than d
+func (ctx decimalContext) ceil(d Decimal) (Decimal, error) {
+	floor, err := ctx.floor(d.neg())
+	if err != nil {
+		return Decimal{}, err
+	}
+	return floor.neg(), nil
+}
+
+// fromFloat converts a float64 result to a decimal at the context precision
+func (ctx decimalContext) fromFloat(f float64) (Decimal, error) {
+	d, err := decimalFromFloat(f)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return ctx.round(d.coef(), d.exponent, false)
+}
+
+// operators returns the arithmetic operators of decimal mode
+// Comparisons use the built-in operators, which compare decimals exactly
+func (ctx decimalContext) operators() []*Operator {
+	return []*Operator{
+		decimalOperator("+", PrecedenceAdditive, ctx.add),
+		decimalOperator("-", PrecedenceAdditive, ctx.sub),
+		decimalOperator("*", PrecedenceMultiplicative, ctx.mul),
+		decimalOperator("/", PrecedenceMultiplicative, ctx.div),
+		decimalOperator("//", PrecedenceMultiplicative, ctx.floorDiv),
+		decimalOperator("%", PrecedenceMultiplicative, ctx.mod),
+		rightAssociative(decimalOperator("^", PrecedenceExponent, ctx.pow)),
+		rightAssociative(decimalOperator("**", PrecedenceExponent, ctx.pow)),
+	}
+}
+
+// decimalOperator creates a left associative operator over decimals
+// Number operands, such as the results of string functions, are converted first
+func decimalOperator(symbol string, precedence int, fn func(left, right Decimal) (Decimal, error)) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: precedence,
+		ValueFn: func(left, right Value) (Value, error) {
+			l, lerr := toDecimal(left, "")
+			r, rerr := toDecimal(right, "")
+			if lerr != nil || rerr != nil {
+				return nil, operandTypeError(symbol, left, right)
+			}
+
+			result, err := fn(l, r)
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+		},
+	}
+}
+
+// functions returns the numeric functions decimal mode computes at its precision
+func (ctx decimalContext) functions() map[string]builtinFunction {
+	functions := ctx.transcendentalFunctions()
+	for name, function := range map[string]builtinFunction{
+		"abs": decimalUnary(func(d Decimal) (Decimal, error) {
+			if d.coef().Sign() < 0 {
+				return d.neg(), nil
+			}
+			return d, nil
+		}),
+		"floor": decimalUnary(ctx.floor),
+		"ceil":  decimalUnary(ctx.ceil),
+		"sqrt":  decimalUnary(ctx.sqrt),
+		"round": {minArgs: 1, maxArgs: 2, valueFn: func(args []Value) (Value, error) {
+			d, err := toDecimal(args[0], "argument 1")
+			if err != nil {
+				return nil, err
+			}
+
+			places := int64(0)
+			if len(args) == 2 {
+				digits, err := toDecimal(args[1], "argument 2")
+				if err != nil {
+					return nil, err
+				}
+				var ok bool
+				if places, ok = digits.integer(); !ok || places > maxDecimalExponent || places < -maxDecimalExponent {
+					return nil, fmt.Errorf("digits must be an integer")
+				}
+			}
+			return ctx.quantize(d, int(places))
+		}},
+		"min": decimalExtremum(-1),
+		"max": decimalExtremum(1),
+	} {
+		functions[name] = function
+	}
+	return functions
+}
+
+// decimalUnary adapts a single-argument decimal function
+func decimalUnary(fn func(d Decimal) (Decimal, error)) builtinFunction {
+	return builtinFunction{minArgs: 1, maxArgs: 1, valueFn: func(args []Value) (Value, error) {
+		d, err := toDecimal(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := fn(d)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}}
+}
+
+// decimalExtremum creates min (sign -1) or max (sign 1) over decimals
+func decimalExtremum(sign int) builtinFunction {
+	return builtinFunction{minArgs: 1, maxArgs: Variadic, valueFn: func(args []Value) (Value, error) {
+		var result Decimal
+		for i, arg := range args {
+			d, err := toDecimal(arg, fmt.Sprintf("argument %d", i+1))
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 || d.cmp(result) == sign {
+				result = d
+			}
+		}
+		return result, nil
+	}}
+}
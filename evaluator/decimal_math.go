@@ -0,0 +1,532 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Transcendental functions of decimal mode, computed with big.Float at the working precision
+// plus guard digits and then rounded like every other decimal result
+
+// decimalGuardDigits are the digits carried beyond the precision while computing a function
+const decimalGuardDigits = 10
+
+// maxTrigExponent bounds the binary exponent of arguments to the trigonometric functions,
+// since reducing an argument modulo pi needs pi to as many more bits
+const maxTrigExponent = 4096
+
+// maxExpArgument bounds the argument of exp; beyond it the result is far outside the decimal range
+const maxExpArgument = 100000
+
+// bits returns the big.Float precision the context computes functions with
+func (ctx decimalContext) bits() uint {
+	return uint(math.Ceil(float64(ctx.precision+decimalGuardDigits)*math.Log2(10))) + 16
+}
+
+// bigFloat returns d as a big.Float with prec bits
+func (d Decimal) bigFloat(prec uint) *big.Float {
+	f := new(big.Float).SetPrec(prec).SetInt(d.coef())
+	scale := new(big.Float).SetPrec(prec).SetInt(pow10(abs(d.exponent)))
+	if d.exponent < 0 {
+		return f.Quo(f, scale)
+	}
+	return f.Mul(f, scale)
+}
+
+// fromBig rounds a computed result to the context precision
+// Trailing zeros are dropped down to the units digit, so exp(0) is 1 rather than 1.000…
+func (ctx decimalContext) fromBig(f *big.Float) (Decimal, error) {
+	if f.IsInf() {
+		return Decimal{}, fmt.Errorf("result out of range")
+	}
+	d, err := parseDecimal(f.Text('e', ctx.precision+decimalGuardDigits/2))
+	if err != nil {
+		return Decimal{}, err
+	}
+	if d, err = ctx.round(d.coef(), d.exponent, false); err != nil {
+		return Decimal{}, err
+	}
+
+	c, exponent := d.coef(), d.exponent
+	ten, digit := big.NewInt(10), new(big.Int)
+	for exponent < 0 && c.Sign() != 0 {
+		reduced, _ := new(big.Int).QuoRem(c, ten, digit)
+		if digit.Sign() != 0 {
+			break
+		}
+		c = reduced
+		exponent++
+	}
+	if c.Sign() == 0 {
+		exponent = 0
+	}
+	return Decimal{coefficient: c, exponent: exponent}, nil
+}
+
+// decimalFunction adapts a function of one big.Float argument to decimal mode
+func (ctx decimalContext) decimalFunction(fn func(x *big.Float, prec uint) (*big.Float, error)) builtinFunction {
+	return decimalUnary(func(d Decimal) (Decimal, error) {
+		prec := ctx.bits()
+		result, err := fn(d.bigFloat(prec), prec)
+		if err != nil {
+			return Decimal{}, err
+		}
+		return ctx.fromBig(result)
+	})
+}
+
+// decimalFunction2 adapts a function of two big.Float arguments to decimal mode
+func (ctx decimalContext) decimalFunction2(fn func(x, y *big.Float, prec uint) (*big.Float, error)) builtinFunction {
+	return builtinFunction{minArgs: 2, maxArgs: 2, valueFn: func(args []Value) (Value, error) {
+		x, err := toDecimal(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		y, err := toDecimal(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		prec := ctx.bits()
+		result, err := fn(x.bigFloat(prec), y.bigFloat(prec), prec)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.fromBig(result)
+	}}
+}
+
+// transcendentalFunctions returns the functions decimal mode computes at its precision
+// rather than in floating point
+func (ctx decimalContext) transcendentalFunctions() map[string]builtinFunction {
+	return map[string]builtinFunction{
+		"exp": ctx.decimalFunction(bigExp),
+		"ln": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			if x.Sign() <= 0 {
+				return nil, fmt.Errorf("argument must be positive")
+			}
+			return bigLn(x, prec), nil
+		}),
+		"log10": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			if x.Sign() <= 0 {
+				return nil, fmt.Errorf("argument must be positive")
+			}
+			return bigLog(x, big.NewFloat(10), prec), nil
+		}),
+		"log": ctx.decimalFunction2(func(x, base *big.Float, prec uint) (*big.Float, error) {
+			if x.Sign() <= 0 {
+				return nil, fmt.Errorf("argument must be positive")
+			}
+			if base.Sign() <= 0 || base.Cmp(big.NewFloat(1)) == 0 {
+				return nil, fmt.Errorf("base must be positive and not equal to 1")
+			}
+			return bigLog(x, base, prec), nil
+		}),
+		"sin": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			sin, _, err := bigSinCos(x, prec)
+			return sin, err
+		}),
+		"cos": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			_, cos, err := bigSinCos(x, prec)
+			return cos, err
+		}),
+		"tan": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			sin, cos, err := bigSinCos(x, prec)
+			if err != nil {
+				return nil, err
+			}
+			return sin.Quo(sin, cos), nil
+		}),
+		"asin": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			return bigAsin(x, prec)
+		}),
+		"acos": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			asin, err := bigAsin(x, prec)
+			if err != nil {
+				return nil, err
+			}
+			halfPi := bigPi(prec)
+			halfPi.SetMantExp(halfPi, -1)
+			return halfPi.Sub(halfPi, asin), nil
+		}),
+		"atan": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			return bigAtan(x, prec), nil
+		}),
+		"atan2": ctx.decimalFunction2(func(y, x *big.Float, prec uint) (*big.Float, error) {
+			return bigAtan2(y, x, prec), nil
+		}),
+		"sinh": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			return bigSinh(x, prec)
+		}),
+		"cosh": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			return bigCosh(x, prec)
+		}),
+		"tanh": ctx.decimalFunction(func(x *big.Float, prec uint) (*big.Float, error) {
+			return bigTanh(x, prec)
+		}),
+		"hypot": ctx.decimalFunction2(func(x, y *big.Float, prec uint) (*big.Float, error) {
+			sum := new(big.Float).SetPrec(prec).Mul(x, x)
+			sum.Add(sum, new(big.Float).SetPrec(prec).Mul(y, y))
+			return sum.Sqrt(sum), nil
+		}),
+		"factorial": decimalUnary(ctx.factorial),
+	}
+}
+
+// factorial returns n! for a non-negative integer n, exactly up to the precision
+// The product stops growing as soon as it leaves the decimal range, so huge arguments fail quickly
+func (ctx decimalContext) factorial(d Decimal) (Decimal, error) {
+	n, ok := d.integer()
+	if !ok || n < 0 {
+		return Decimal{}, fmt.Errorf("argument must be a non-negative integer")
+	}
+
+	work := decimalContext{precision: ctx.precision + decimalGuardDigits, rounding: RoundHalfEven}
+	result := Decimal{coefficient: big.NewInt(1)}
+	var err error
+	for i := int64(2); i <= n; i++ {
+		if result, err = work.mul(result, Decimal{coefficient: big.NewInt(i)}); err != nil {
+			return Decimal{}, err
+		}
+	}
+	return ctx.round(result.coef(), result.exponent, false)
+}
+
+// fractionalPow returns base raised to a non-integer exponent, as e^(exponent × ln base)
+func (ctx decimalContext) fractionalPow(base, exponent Decimal) (Decimal, error) {
+	switch base.coef().Sign() {
+	case 0:
+		if exponent.coef().Sign() < 0 {
+			return Decimal{}, fmt.Errorf("zero raised to a negative power")
+		}
+		return Decimal{coefficient: new(big.Int)}, nil
+	case -1:
+		return Decimal{}, fmt.Errorf("negative base raised to a non-integer power")
+	}
+
+	prec := ctx.bits() + 32
+	product := bigLn(base.bigFloat(prec), prec)
+	product.Mul(product, exponent.bigFloat(prec))
+	result, err := bigExp(product, prec)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return ctx.fromBig(result)
+}
+
+// constants returns pi and e at the context precision
+func (ctx decimalContext) constants() map[string]Value {
+	prec := ctx.bits()
+	pi, _ := ctx.fromBig(bigPi(prec))
+	exp, _ := bigExp(newFloat(1, prec), prec)
+	e, _ := ctx.fromBig(exp)
+	return map[string]Value{"pi": pi, "e": e}
+}
+
+// newFloat returns a big.Float with prec bits set to x
+func newFloat(x float64, prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec).SetFloat64(x)
+}
+
+// bigExp returns e^x
+// The argument is halved until it is small, the Taylor series summed, and the result squared back
+func bigExp(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() == 0 {
+		return newFloat(1, prec), nil
+	}
+	if f, _ := x.Float64(); math.Abs(f) > maxExpArgument {
+		return nil, fmt.Errorf("result out of range")
+	}
+
+	halvings := x.MantExp(nil) + 8
+	if halvings < 0 {
+		halvings = 0
+	}
+	work := prec + uint(halvings) + 16
+	r := new(big.Float).SetPrec(work).SetMantExp(x, -halvings)
+
+	sum, term := newFloat(1, work), newFloat(1, work)
+	limit := newFloat(1, work).SetMantExp(newFloat(1, work), -int(work))
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(n))
+		sum.Add(sum, term)
+		if new(big.Float).Abs(term).Cmp(limit) < 0 {
+			break
+		}
+	}
+	for i := 0; i < halvings; i++ {
+		sum.Mul(sum, sum)
+	}
+	return sum.SetPrec(prec), nil
+}
+
+// atanhSeries returns atanh(z) = z + z^3/3 + z^5/5 + … for |z| well below 1
+func atanhSeries(z *big.Float, prec uint) *big.Float {
+	sum := new(big.Float).SetPrec(prec).Set(z)
+	power := new(big.Float).SetPrec(prec).Set(z)
+	square := new(big.Float).SetPrec(prec).Mul(z, z)
+	limit := newFloat(1, prec).SetMantExp(newFloat(1, prec), -int(prec))
+	for n := int64(3); ; n += 2 {
+		power.Mul(power, square)
+		term := new(big.Float).SetPrec(prec).Quo(power, new(big.Float).SetInt64(n))
+		sum.Add(sum, term)
+		if term.Abs(term).Cmp(limit) < 0 {
+			return sum
+		}
+	}
+}
+
+// bigLn2 returns ln 2 = 2 atanh(1/3)
+func bigLn2(prec uint) *big.Float {
+	third := new(big.Float).SetPrec(prec).Quo(newFloat(1, prec), newFloat(3, prec))
+	ln2 := atanhSeries(third, prec)
+	return ln2.SetMantExp(ln2, 1)
+}
+
+// bigLn returns the natural logarithm of a positive x
+// With x = m × 2^k and m in [0.5, 1), ln x = k ln 2 + ln m, and ln m = 2^(s+1) atanh((r-1)/(r+1))
+// where r is m after s square roots, which brings it close to 1 so the series converges quickly
+func bigLn(x *big.Float, prec uint) *big.Float {
+	const roots = 6
+	work := prec + 32
+	m := new(big.Float)
+	k := x.MantExp(m)
+	m.SetPrec(work)
+
+	for i := 0; i < roots; i++ {
+		m.Sqrt(m)
+	}
+	one := newFloat(1, work)
+	z := new(big.Float).SetPrec(work).Quo(new(big.Float).SetPrec(work).Sub(m, one), new(big.Float).SetPrec(work).Add(m, one))
+	result := atanhSeries(z, work)
+	result.SetMantExp(result, roots+1)
+
+	if k != 0 {
+		ln2 := bigLn2(work)
+		result.Add(result, ln2.Mul(ln2, new(big.Float).SetInt64(int64(k))))
+	}
+	return result.SetPrec(prec)
+}
+
+// bigLog returns the logarithm of x to base
+func bigLog(x, base *big.Float, prec uint) *big.Float {
+	work := prec + 16
+	result := bigLn(x, work)
+	return result.Quo(result, bigLn(base, work)).SetPrec(prec)
+}
+
+// atanSeries returns atan(x) = x - x^3/3 + x^5/5 - … for |x| well below 1
+func atanSeries(x *big.Float, prec uint) *big.Float {
+	sum := new(big.Float).SetPrec(prec).Set(x)
+	power := new(big.Float).SetPrec(prec).Set(x)
+	square := new(big.Float).SetPrec(prec).Mul(x, x)
+	limit := newFloat(1, prec).SetMantExp(newFloat(1, prec), -int(prec))
+	for n := int64(3); ; n += 2 {
+		power.Mul(power, square)
+		term := new(big.Float).SetPrec(prec).Quo(power, new(big.Float).SetInt64(n))
+		if n%4 == 3 {
+			sum.Sub(sum, term)
+		} else {
+			sum.Add(sum, term)
+		}
+		if term.Abs(term).Cmp(limit) < 0 {
+			return sum
+		}
+	}
+}
+
+// bigPi returns pi by Machin's formula, pi = 16 atan(1/5) - 4 atan(1/239)
+func bigPi(prec uint) *big.Float {
+	work := prec + 16
+	a := atanSeries(new(big.Float).SetPrec(work).Quo(newFloat(1, work), newFloat(5, work)), work)
+	b := atanSeries(new(big.Float).SetPrec(work).Quo(newFloat(1, work), newFloat(239, work)), work)
+	a.SetMantExp(a, 4)
+	b.SetMantExp(b, 2)
+	return a.Sub(a, b).SetPrec(prec)
+}
+
+// bigAtan returns the arctangent of x
+// Arguments above 1 use atan x = pi/2 - atan(1/x), and the rest are reduced with
+// atan x = 2 atan(x / (1 + sqrt(1 + x^2))) before summing the series
+func bigAtan(x *big.Float, prec uint) *big.Float {
+	work := prec + 16
+	if x.Sign() < 0 {
+		result := bigAtan(new(big.Float).SetPrec(work).Neg(x), prec)
+		return result.Neg(result)
+	}
+	one := newFloat(1, work)
+	if x.Cmp(one) > 0 {
+		halfPi := bigPi(work)
+		halfPi.SetMantExp(halfPi, -1)
+		inverse := bigAtan(new(big.Float).SetPrec(work).Quo(one, x), work)
+		return halfPi.Sub(halfPi, inverse).SetPrec(prec)
+	}
+
+	const reductions = 4
+	r := new(big.Float).SetPrec(work).Set(x)
+	for i := 0; i < reductions; i++ {
+		root := new(big.Float).SetPrec(work).Mul(r, r)
+		root.Add(root, one).Sqrt(root).Add(root, one)
+		r.Quo(r, root)
+	}
+	result := atanSeries(r, work)
+	return result.SetMantExp(result, reductions).SetPrec(prec)
+}
+
+// bigAtan2 returns the angle of the point (x, y), like math.Atan2
+func bigAtan2(y, x *big.Float, prec uint) *big.Float {
+	work := prec + 16
+	switch {
+	case x.Sign() == 0 && y.Sign() == 0:
+		return newFloat(0, prec)
+	case x.Sign() == 0:
+		halfPi := bigPi(prec)
+		halfPi.SetMantExp(halfPi, -1)
+		if y.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi
+	}
+
+	result := bigAtan(new(big.Float).SetPrec(work).Quo(y, x), work)
+	if x.Sign() < 0 {
+		pi := bigPi(work)
+		if y.Sign() < 0 {
+			result.Sub(result, pi)
+		} else {
+			result.Add(result, pi)
+		}
+	}
+	return result.SetPrec(prec)
+}
+
+// bigAsin returns the arcsine of x in [-1, 1], as atan(x / sqrt(1 - x^2))
+func bigAsin(x *big.Float, prec uint) (*big.Float, error) {
+	work := prec + 16
+	one := newFloat(1, work)
+	magnitude := new(big.Float).Abs(x)
+	switch magnitude.Cmp(one) {
+	case 1:
+		return nil, fmt.Errorf("argument must be between -1 and 1")
+	case 0:
+		halfPi := bigPi(prec)
+		halfPi.SetMantExp(halfPi, -1)
+		if x.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi, nil
+	}
+
+	cos := new(big.Float).SetPrec(work).Mul(x, x)
+	cos.Sub(one, cos).Sqrt(cos)
+	return bigAtan(cos.Quo(x, cos), prec), nil
+}
+
+// bigSinCos returns the sine and cosine of x
+// The argument is reduced by multiples of pi/2 to within pi/4 of zero before summing the series
+func bigSinCos(x *big.Float, prec uint) (*big.Float, *big.Float, error) {
+	exponent := x.MantExp(nil)
+	if exponent > maxTrigExponent {
+		return nil, nil, fmt.Errorf("argument too large")
+	}
+	if exponent < 0 {
+		exponent = 0
+	}
+	work := prec + uint(exponent) + 32
+
+	halfPi := bigPi(work)
+	halfPi.SetMantExp(halfPi, -1)
+	quotient := new(big.Float).SetPrec(work).Quo(x, halfPi)
+	quarter, _ := new(big.Float).SetPrec(work).Add(quotient, newFloat(0.5, work)).Int(nil)
+	if quotient.Sign() < 0 {
+		quarter, _ = new(big.Float).SetPrec(work).Sub(quotient, newFloat(0.5, work)).Int(nil)
+	}
+	r := new(big.Float).SetPrec(work).Mul(new(big.Float).SetPrec(work).SetInt(quarter), halfPi)
+	r.Sub(x, r)
+
+	// sin r = r - r^3/3! + …, cos r = 1 - r^2/2! + …, summed together
+	sin, cos := newFloat(0, work), newFloat(1, work)
+	term := newFloat(1, work)
+	limit := newFloat(1, work).SetMantExp(newFloat(1, work), -int(work))
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(n))
+		switch n % 4 {
+		case 1:
+			sin.Add(sin, term)
+		case 2:
+			cos.Sub(cos, term)
+		case 3:
+			sin.Sub(sin, term)
+		case 0:
+			cos.Add(cos, term)
+		}
+		if n > 1 && new(big.Float).Abs(term).Cmp(limit) < 0 {
+			break
+		}
+	}
+
+	switch new(big.Int).And(quarter, big.NewInt(3)).Int64() {
+	case 1:
+		sin, cos = cos, sin.Neg(sin)
+	case 2:
+		sin, cos = sin.Neg(sin), cos.Neg(cos)
+	case 3:
+		sin, cos = cos.Neg(cos), sin
+	}
+	return sin.SetPrec(prec), cos.SetPrec(prec), nil
+}
+
+// expPair returns e^x and e^-x with enough extra precision for their difference near zero
+func expPair(x *big.Float, prec uint) (*big.Float, *big.Float, uint, error) {
+	work := prec + 16
+	if exponent := x.MantExp(nil); exponent < 0 {
+		work += uint(-exponent)
+	}
+	positive, err := bigExp(new(big.Float).SetPrec(work).Set(x), work)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	negative := new(big.Float).SetPrec(work).Quo(newFloat(1, work), positive)
+	return positive, negative, work, nil
+}
+
+// bigSinh returns the hyperbolic sine of x, (e^x - e^-x) / 2
+func bigSinh(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() == 0 {
+		return newFloat(0, prec), nil
+	}
+	positive, negative, _, err := expPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	result := positive.Sub(positive, negative)
+	return result.SetMantExp(result, -1).SetPrec(prec), nil
+}
+
+// bigCosh returns the hyperbolic cosine of x, (e^x + e^-x) / 2
+func bigCosh(x *big.Float, prec uint) (*big.Float, error) {
+	positive, negative, _, err := expPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	result := positive.Add(positive, negative)
+	return result.SetMantExp(result, -1).SetPrec(prec), nil
+}
+
+// bigTanh returns the hyperbolic tangent of x, which is ±1 to any precision for large arguments
+func bigTanh(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() == 0 {
+		return newFloat(0, prec), nil
+	}
+	if f, _ := x.Float64(); math.Abs(f) > float64(prec) {
+		return newFloat(float64(x.Sign()), prec), nil
+	}
+	positive, negative, work, err := expPair(x, prec)
+	if err != nil {
+		return nil, err
+	}
+	numerator := new(big.Float).SetPrec(work).Sub(positive, negative)
+	return numerator.Quo(numerator, positive.Add(positive, negative)).SetPrec(prec), nil
+}
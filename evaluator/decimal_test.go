@@ -0,0 +1,134 @@
+package evaluator
+
+import "testing"
+
+// TestDecimalFunctions checks functions of decimal mode against their digits to the precision,
+// which floating point cannot reach
+func TestDecimalFunctions(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeDecimal})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"2^0.5", "1.414213562373095048801688724209698"},
+		{"2^-0.5", "0.707106781186547524400844362104849"},
+		{"1.5^2.5", "2.755675960631075360471944584044128"},
+		{"0^0.5", "0"},
+		{"pi", "3.141592653589793238462643383279503"},
+		{"e", "2.718281828459045235360287471352662"},
+		{"exp(0)", "1"},
+		{"exp(1) == e", "true"},
+		{"ln(2)", "0.6931471805599453094172321214581766"},
+		{"ln(0.001)", "-6.907755278982137052053974364053093"},
+		{"log10(1000)", "3"},
+		{"log(8, 2)", "3"},
+		{"sin(1)", "0.841470984807896506652502321630299"},
+		{"cos(1)", "0.5403023058681397174009366074429766"},
+		{"tan(1)", "1.55740772465490223050697480745836"},
+		{"sin(-1000000)", "0.3499935021712929521176524867807715"},
+		{"asin(0.5) * 6", "3.141592653589793238462643383279503"},
+		{"acos(1)", "0"},
+		{"atan(1) * 4", "3.141592653589793238462643383279503"},
+		{"atan2(-1, -1)", "-2.356194490192344928846982537459627"},
+		{"sinh(1)", "1.175201193643801456882381850595601"},
+		{"cosh(1)", "1.543080634815243778477905620757062"},
+		{"tanh(0.5)", "0.4621171572600097585023184836436725"},
+		{"hypot(3, 4)", "5"},
+		{"factorial(30)", "265252859812191058636308480000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := CompileWithRegistry(tt.expression, registry)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			got, err := program.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Evaluate(%q) = %s, want %s", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalFunctionErrors(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeDecimal})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"(-2)^0.5", "negative base raised to a non-integer power"},
+		{"0^-0.5", "zero raised to a negative power"},
+		{"ln(0)", "ln: argument must be positive"},
+		{"log(2, 1)", "log: base must be positive and not equal to 1"},
+		{"asin(1.5)", "asin: argument must be between -1 and 1"},
+		{"exp(10000000)", "exp: result out of range"},
+		{"factorial(2.5)", "factorial: argument must be a non-negative integer"},
+		{"factorial(100000)", "factorial: decimal result out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := CompileWithRegistry(tt.expression, registry)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			_, err = program.Evaluate(nil)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Evaluate(%q) error = %v, want %q", tt.expression, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestNumericModeOperatorsAreBuiltin checks that the operators a numeric mode installs count as built-ins:
+// they are folded at compile time, replaced when the mode is applied again, and never run as float arithmetic
+func TestNumericModeOperatorsAreBuiltin(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeDecimal})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	program, err := CompileWithRegistry("0.1 + 0.2 * 3", registry)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if folded, ok := program.root.(*ValueNode); !ok || folded.Value.String() != "0.7" {
+		t.Errorf("0.1 + 0.2 * 3 compiled to %s, want the constant 0.7", Format(program.root))
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: `len("abc") + 0.1`, want: "3.1"},
+		{expression: `len("abc") / len("abcdef")`, want: "0.5"},
+	})
+
+	coarse, err := registry.WithNumericMode(NumericOptions{Mode: ModeDecimal, Precision: 5})
+	if err != nil {
+		t.Fatalf("WithNumericMode failed: %v", err)
+	}
+	checkOutcomes(t, coarse, nil, []outcome{{expression: "1 / 3", want: "0.33333"}})
+
+	if err := registry.RegisterOperator("<+>", PrecedenceAdditive, LeftAssociative, func(left, right float64) (float64, error) {
+		return left + right, nil
+	}); err != nil {
+		t.Fatalf("RegisterOperator failed: %v", err)
+	}
+	program, err = CompileWithRegistry("1 <+> 2", registry)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if _, ok := program.root.(*BinaryOpNode); !ok {
+		t.Errorf("1 <+> 2 compiled to %s, want the custom operator left unfolded", Format(program.root))
+	}
+}
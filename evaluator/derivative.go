@@ -0,0 +1,451 @@
+package evaluator
+
+import (
+	"fmt"
+)
+
+// Differentiate returns the derivative of an expression tree with respect to variable, simplified
+// It applies the sum, product, quotient, power and chain rules and knows the derivative of every
+// numeric built-in function; piecewise functions such as abs, floor and min are differentiated
+// where they are smooth. Anything else that depends on variable, such as factorial or a custom
+// function or operator, is an error
+// env holds the variables the expression will be evaluated with, which may be nil; a variable named e
+// hides the constant e, as it does when evaluating
+func Differentiate(root ExprNode, variable string, env *Environment) (ExprNode, error) {
+	if !isIdentifier(variable) {
+		return nil, fmt.Errorf("invalid variable name: %q", variable)
+	}
+
+	derivative, err := derive(Simplify(root), variable, env)
+	if err != nil {
+		return nil, err
+	}
+	return Simplify(derivative), nil
+}
+
+// derive returns the unsimplified derivative of node with respect to x
+func derive(node ExprNode, x string, env *Environment) (ExprNode, error) {
+	if !dependsOn(node, x) {
+		return constant(0), nil
+	}
+
+	switch n := node.(type) {
+	case *VariableNode:
+		return constant(1), nil
+
+	case *UnaryOpNode:
+		du, err := derive(n.Operand, x, env)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Operator {
+		case "-":
+			return negate(du), nil
+		case "+":
+			return du, nil
+		}
+
+	case *BinaryOpNode:
+		return deriveBinary(n, x, env)
+
+	case *FunctionCallNode:
+		return deriveCall(n, x, env)
+
+	case *IterationNode:
+		return deriveIteration(n, x, env)
+
+	case *ConditionalNode:
+		then, err := derive(n.Then, x, env)
+		if err != nil {
+			return nil, err
+		}
+		otherwise, err := derive(n.Else, x, env)
+		if err != nil {
+			return nil, err
+		}
+		return &ConditionalNode{Condition: n.Condition, Then: then, Else: otherwise}, nil
+	}
+	return nil, fmt.Errorf("cannot differentiate %s with respect to %s", Format(node), x)
+}
+
+// deriveBinary differentiates an arithmetic operation
+func deriveBinary(n *BinaryOpNode, x string, env *Environment) (ExprNode, error) {
+	if op, err := n.resolve(); err != nil || !op.builtin {
+		return nil, fmt.Errorf("cannot differentiate custom operator %s", n.Operator)
+	}
+
+	u, v := n.Left, n.Right
+	du, err := derive(u, x, env)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := derive(v, x, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Operator {
+	case "+", "-":
+		return binary(du, n.Operator, dv), nil
+
+	case "*":
+		return binary(binary(du, "*", v), "+", binary(u, "*", dv)), nil
+
+	case "/":
+		if !dependsOn(v, x) {
+			return binary(du, "/", v), nil
+		}
+		numerator := binary(binary(du, "*", v), "-", binary(u, "*", dv))
+		return binary(numerator, "/", binary(v, "^", constant(2))), nil
+
+	case "^", "**":
+		switch {
+		case !dependsOn(v, x):
+			// Power rule: v * u^(v - 1) * u'
+			return binary(binary(v, "*", binary(u, "^", binary(v, "-", constant(1)))), "*", du), nil
+		case !dependsOn(u, x) && isEuler(u, env):
+			return binary(n, "*", dv), nil
+		case !dependsOn(u, x):
+			return binary(binary(n, "*", call("ln", u)), "*", dv), nil
+		default:
+			// u^v = exp(v * ln(u)), so its derivative is u^v * (v' * ln(u) + v * u' / u)
+			inner := binary(binary(dv, "*", call("ln", u)), "+", binary(binary(v, "*", du), "/", u))
+			return binary(n, "*", inner), nil
+		}
+
+	case "//":
+		// Floor division is piecewise constant
+		return constant(0), nil
+
+	case "%":
+		// u % v = u - v * floor(u / v), where the floor is piecewise constant
+		return binary(du, "-", binary(dv, "*", call("floor", binary(u, "/", v)))), nil
+	}
+	return nil, fmt.Errorf("cannot differentiate operator %s", n.Operator)
+}
+
+// isEuler reports whether node is the constant e rather than a variable bound in env, so that
+// e^x differentiates to e^x
+func isEuler(node ExprNode, env *Environment) bool {
+	v, ok := node.(*VariableNode)
+	if !ok || v.Name != "e" {
+		return false
+	}
+	_, bound := env.Lookup(v.Name)
+	return !bound
+}
+
+// derivatives maps the numeric built-in functions of one argument to their derivative at u
+var derivatives = map[string]func(u ExprNode) ExprNode{
+	"sqrt": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", binary(constant(2), "*", call("sqrt", u)))
+	},
+	"abs": func(u ExprNode) ExprNode {
+		return binary(u, "/", call("abs", u))
+	},
+	"exp": func(u ExprNode) ExprNode {
+		return call("exp", u)
+	},
+	"ln": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", u)
+	},
+	"log10": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", binary(u, "*", call("ln", constant(10))))
+	},
+	"sin": func(u ExprNode) ExprNode {
+		return call("cos", u)
+	},
+	"cos": func(u ExprNode) ExprNode {
+		return negate(call("sin", u))
+	},
+	"tan": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", binary(call("cos", u), "^", constant(2)))
+	},
+	"asin": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", call("sqrt", binary(constant(1), "-", binary(u, "^", constant(2)))))
+	},
+	"acos": func(u ExprNode) ExprNode {
+		return binary(constant(-1), "/", call("sqrt", binary(constant(1), "-", binary(u, "^", constant(2)))))
+	},
+	"atan": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", binary(constant(1), "+", binary(u, "^", constant(2))))
+	},
+	"sinh": func(u ExprNode) ExprNode {
+		return call("cosh", u)
+	},
+	"cosh": func(u ExprNode) ExprNode {
+		return call("sinh", u)
+	},
+	"tanh": func(u ExprNode) ExprNode {
+		return binary(constant(1), "/", binary(call("cosh", u), "^", constant(2)))
+	},
+	"floor": func(u ExprNode) ExprNode {
+		return constant(0)
+	},
+	"ceil": func(u ExprNode) ExprNode {
+		return constant(0)
+	},
+}
+
+// deriveCall differentiates a call to a built-in function by the chain rule
+// The rules hold for the built-in functions only, not for custom functions that replace them
+func deriveCall(n *FunctionCallNode, x string, env *Environment) (ExprNode, error) {
+	if function, err := n.resolve(); err != nil || !function.builtin {
+		return nil, fmt.Errorf("cannot differentiate custom function %s", n.Name)
+	}
+
+	args := n.Args
+	if derivative, ok := derivatives[n.Name]; ok && len(args) == 1 {
+		du, err := derive(args[0], x, env)
+		if err != nil {
+			return nil, err
+		}
+		return binary(derivative(args[0]), "*", du), nil
+	}
+
+	switch {
+	case n.Name == "round" && len(args) >= 1:
+		return constant(0), nil
+
+	case n.Name == "log" && len(args) == 2:
+		// log(u, b) = ln(u) / ln(b)
+		return derive(binary(call("ln", args[0]), "/", call("ln", args[1])), x, env)
+
+	case n.Name == "atan2" && len(args) == 2:
+		// d atan2(y, x) = (x * y' - y * x') / (x^2 + y^2)
+		y, w := args[0], args[1]
+		dy, err := derive(y, x, env)
+		if err != nil {
+			return nil, err
+		}
+		dw, err := derive(w, x, env)
+		if err != nil {
+			return nil, err
+		}
+		numerator := binary(binary(w, "*", dy), "-", binary(y, "*", dw))
+		return binary(numerator, "/", binary(binary(w, "^", constant(2)), "+", binary(y, "^", constant(2)))), nil
+
+	case n.Name == "hypot" && len(args) == 2:
+		// d hypot(a, b) = (a * a' + b * b') / hypot(a, b)
+		a, b := args[0], args[1]
+		da, err := derive(a, x, env)
+		if err != nil {
+			return nil, err
+		}
+		db, err := derive(b, x, env)
+		if err != nil {
+			return nil, err
+		}
+		return binary(binary(binary(a, "*", da), "+", binary(b, "*", db)), "/", n), nil
+
+	case (n.Name == "min" || n.Name == "max") && len(args) >= 1:
+		// The derivative of the selected argument: min(a, b...) is a <= min(b...) ? a' : min(b...)'
+		if len(args) == 1 {
+			return derive(args[0], x, env)
+		}
+		rest := args[1]
+		if len(args) > 2 {
+			rest = call(n.Name, args[1:]...)
+		}
+		comparison := "<="
+		if n.Name == "max" {
+			comparison = ">="
+		}
+		first, err := derive(args[0], x, env)
+		if err != nil {
+			return nil, err
+		}
+		others, err := derive(rest, x, env)
+		if err != nil {
+			return nil, err
+		}
+		return &ConditionalNode{Condition: binary(args[0], comparison, rest), Then: first, Else: others}, nil
+	}
+
+	return nil, fmt.Errorf("cannot differentiate %s: no derivative is known for it", n.Name)
+}
+
+// deriveIteration differentiates a sum term by term and an integral by the Leibniz rule:
+// d/dx integrate(f, t, a, b) = f(b) * b' - f(a) * a' + integrate(df/dx, t, a, b)
+func deriveIteration(n *IterationNode, x string, env *Environment) (ExprNode, error) {
+	if call, ok, err := n.aggregate(env); ok {
+		if err != nil {
+			return nil, err
+		}
+		return deriveCall(call, x, env)
+	}
+
+	body, err := derive(n.Body, x, env)
+	if n.Variable == x {
+		body, err = constant(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	inner := *n
+	inner.Body = body
+
+	switch {
+	case n.Form == "sum" && !dependsOn(n.From, x) && !dependsOn(n.To, x):
+		return &inner, nil
+
+	case n.Form == "integrate":
+		da, err := derive(n.From, x, env)
+		if err != nil {
+			return nil, err
+		}
+		db, err := derive(n.To, x, env)
+		if err != nil {
+			return nil, err
+		}
+		upper, ok := substitute(n.Body, n.Variable, n.To)
+		lower, ok2 := substitute(n.Body, n.Variable, n.From)
+		if ok && ok2 {
+			return binary(binary(binary(upper, "*", db), "-", binary(lower, "*", da)), "+", &inner), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot differentiate %s with respect to %s", Format(n), x)
+}
+
+// dependsOn reports whether the value of node can depend on the variable name
+// A lambda parameter with the same name hides the variable within the lambda's body
+func dependsOn(node ExprNode, name string) bool {
+	switch n := node.(type) {
+	case *VariableNode:
+		return n.Name == name
+	case *ValueNode, *NowNode:
+		return false
+	case *BinaryOpNode:
+		return dependsOn(n.Left, name) || dependsOn(n.Right, name)
+	case *UnaryOpNode:
+		return dependsOn(n.Operand, name)
+	case *LogicalOpNode:
+		return dependsOn(n.Left, name) || dependsOn(n.Right, name)
+	case *ConditionalNode:
+		return dependsOn(n.Condition, name) || dependsOn(n.Then, name) || dependsOn(n.Else, name)
+	case *ConversionNode:
+		return dependsOn(n.Operand, name)
+	case *FunctionCallNode:
+		return anyDependsOn(n.Args, name)
+	case *ListNode:
+		return anyDependsOn(n.Elements, name)
+	case *IndexNode:
+		return dependsOn(n.Target, name) || dependsOn(n.Index, name)
+	case *LambdaNode:
+		for _, param := range n.Params {
+			if param == name {
+				return false
+			}
+		}
+		return dependsOn(n.Body, name)
+	case *IterationNode:
+		return dependsOn(n.From, name) || dependsOn(n.To, name) || (n.Variable != name && dependsOn(n.Body, name))
+	}
+	return true
+}
+
+// anyDependsOn reports whether any of nodes can depend on the variable name
+func anyDependsOn(nodes []ExprNode, name string) bool {
+	for _, node := range nodes {
+		if dependsOn(node, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute returns node with the variable name replaced by replacement
+// It reports false when a lambda or iteration within node would capture a variable of replacement
+func substitute(node ExprNode, name string, replacement ExprNode) (ExprNode, bool) {
+	if !dependsOn(node, name) {
+		return node, true
+	}
+
+	switch n := node.(type) {
+	case *VariableNode:
+		return replacement, true
+
+	case *BinaryOpNode:
+		left, ok := substitute(n.Left, name, replacement)
+		right, ok2 := substitute(n.Right, name, replacement)
+		substituted := *n
+		substituted.Left, substituted.Right = left, right
+		return &substituted, ok && ok2
+
+	case *UnaryOpNode:
+		operand, ok := substitute(n.Operand, name, replacement)
+		return &UnaryOpNode{Operator: n.Operator, Operand: operand}, ok
+
+	case *LogicalOpNode:
+		left, ok := substitute(n.Left, name, replacement)
+		right, ok2 := substitute(n.Right, name, replacement)
+		return &LogicalOpNode{Left: left, Operator: n.Operator, Right: right}, ok && ok2
+
+	case *ConditionalNode:
+		nodes, ok := substituteAll([]ExprNode{n.Condition, n.Then, n.Else}, name, replacement)
+		if !ok {
+			return nil, false
+		}
+		return &ConditionalNode{Condition: nodes[0], Then: nodes[1], Else: nodes[2]}, true
+
+	case *ConversionNode:
+		operand, ok := substitute(n.Operand, name, replacement)
+		substituted := *n
+		substituted.Operand = operand
+		return &substituted, ok
+
+	case *FunctionCallNode:
+		args, ok := substituteAll(n.Args, name, replacement)
+		substituted := *n
+		substituted.Args = args
+		return &substituted, ok
+
+	case *ListNode:
+		elements, ok := substituteAll(n.Elements, name, replacement)
+		return &ListNode{Elements: elements}, ok
+
+	case *IndexNode:
+		target, ok := substitute(n.Target, name, replacement)
+		index, ok2 := substitute(n.Index, name, replacement)
+		return &IndexNode{Target: target, Index: index}, ok && ok2
+
+	case *LambdaNode:
+		for _, param := range n.Params {
+			if dependsOn(replacement, param) {
+				return nil, false
+			}
+		}
+		body, ok := substitute(n.Body, name, replacement)
+		return &LambdaNode{Params: n.Params, Body: body}, ok
+
+	case *IterationNode:
+		nodes, ok := substituteAll([]ExprNode{n.From, n.To}, name, replacement)
+		if !ok {
+			return nil, false
+		}
+		substituted := *n
+		substituted.From, substituted.To = nodes[0], nodes[1]
+		if n.Variable != name && dependsOn(n.Body, name) {
+			if dependsOn(replacement, n.Variable) {
+				return nil, false
+			}
+			if substituted.Body, ok = substitute(n.Body, name, replacement); !ok {
+				return nil, false
+			}
+		}
+		return &substituted, true
+	}
+	return nil, false
+}
+
+// substituteAll substitutes replacement for the variable name in each of nodes
+func substituteAll(nodes []ExprNode, name string, replacement ExprNode) ([]ExprNode, bool) {
+	substituted := make([]ExprNode, len(nodes))
+	for i, node := range nodes {
+		var ok bool
+		if substituted[i], ok = substitute(node, name, replacement); !ok {
+			return nil, false
+		}
+	}
+	return substituted, true
+}
@@ -0,0 +1,197 @@
+package evaluator
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// differentiate parses expression with registry and returns its derivative with respect to variable
+func differentiate(t *testing.T, registry *FunctionRegistry, expression, variable string, env *Environment) (string, error) {
+	t.Helper()
+	if registry == nil {
+		registry = NewFunctionRegistry()
+	}
+	root, err := NewParserWithRegistry(registry).Parse(expression)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", expression, err)
+	}
+	derivative, err := Differentiate(root, variable, env)
+	if err != nil {
+		return "", err
+	}
+	return Format(derivative), nil
+}
+
+func TestDerivativeRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"constant", "y^2", "0"},
+		{"sum", "x^2 + 3 * x", "2 * x + 3"},
+		{"product", "x^3 * sin(x)", "x^3 * cos(x) + 3 * x^2 * sin(x)"},
+		{"product with a constant factor", "x * y", "y"},
+		{"quotient", "sin(x) / x", "(x * cos(x) - sin(x)) / x^2"},
+		{"reciprocal", "1 / x", "-1 / x^2"},
+		{"chain", "sin(x^2)", "2 * x * cos(x^2)"},
+		{"chain through exp", "exp(2 * x)", "2 * exp(2 * x)"},
+		{"nested chain", "ln(cos(x))", "-(sin(x) / cos(x))"},
+		{"chain through sqrt", "sqrt(1 + x^2)", "x / sqrt(x^2 + 1)"},
+		{"exponential", "2^x", "2^x * ln(2)"},
+		{"general power", "x^x", "x^x * (ln(x) + 1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := differentiate(t, nil, tt.expression, "x", nil)
+			if err != nil {
+				t.Fatalf("Differentiate(%q) failed: %v", tt.expression, err)
+			}
+			if got != tt.want {
+				t.Errorf("Differentiate(%q) = %s, want %s", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionDerivatives(t *testing.T) {
+	tests := map[string]string{
+		"sin(x)":      "cos(x)",
+		"cos(x)":      "-sin(x)",
+		"tan(x)":      "1 / cos(x)^2",
+		"asin(x)":     "1 / sqrt(-x^2 + 1)",
+		"acos(x)":     "-1 / sqrt(-x^2 + 1)",
+		"atan(x)":     "1 / (x^2 + 1)",
+		"sinh(x)":     "cosh(x)",
+		"cosh(x)":     "sinh(x)",
+		"tanh(x)":     "1 / cosh(x)^2",
+		"exp(x)":      "exp(x)",
+		"ln(x)":       "1 / x",
+		"log10(x)":    "1 / (x * ln(10))",
+		"log(x, 2)":   "1 / (x * ln(2))",
+		"sqrt(x)":     "1 / (2 * sqrt(x))",
+		"abs(x)":      "x / abs(x)",
+		"floor(x)":    "0",
+		"ceil(x)":     "0",
+		"round(x)":    "0",
+		"atan2(x, 1)": "1 / (x^2 + 1)",
+		"hypot(x, 3)": "x / hypot(x, 3)",
+		"max(x, 2)":   "x >= 2 ? 1 : 0",
+		"min(x, 2)":   "x <= 2 ? 1 : 0",
+	}
+
+	for expression, want := range tests {
+		got, err := differentiate(t, nil, expression, "x", nil)
+		if err != nil {
+			t.Errorf("Differentiate(%q) failed: %v", expression, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Differentiate(%q) = %s, want %s", expression, got, want)
+		}
+	}
+}
+
+// TestDerivativeMatchesDifference checks the derivatives of a few expressions against a central difference
+func TestDerivativeMatchesDifference(t *testing.T) {
+	for _, expression := range []string{
+		"x^3 * sin(x)",
+		"(x^2 + 1) / (x - 3)",
+		"exp(-x^2) * cos(2 * x)",
+		"ln(1 + x^2) / sqrt(x + 2)",
+		"atan(x) * tanh(x) + x^x",
+		"log(x + 2, 3) - asin(x / 2)",
+	} {
+		program, err := Compile(expression)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expression, err)
+		}
+		derivative, err := Differentiate(program.root, "x", nil)
+		if err != nil {
+			t.Fatalf("Differentiate(%q) failed: %v", expression, err)
+		}
+		d := NewProgram(Format(derivative), derivative)
+
+		at := func(p *Program, x float64) float64 {
+			value, err := p.Evaluate(NewEnvironment(map[string]Value{"x": Number(x)}))
+			if err != nil {
+				t.Fatalf("Evaluate(%q) at %g failed: %v", p.Source(), x, err)
+			}
+			return float64(value.(Number))
+		}
+		for _, x := range []float64{0.3, 0.7, 1.1} {
+			const h = 1e-5
+			want := (at(program, x+h) - at(program, x-h)) / (2 * h)
+			if got := at(d, x); math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+				t.Errorf("derivative of %q = %s is %g at %g, want about %g", expression, d.Source(), got, x, want)
+			}
+		}
+	}
+}
+
+// TestEulerNumberInDerivatives checks that e is treated as Euler's number only when nothing hides it
+func TestEulerNumberInDerivatives(t *testing.T) {
+	bound := NewEnvironment(map[string]Value{"e": Number(2)})
+	tests := []struct {
+		expression string
+		variable   string
+		env        *Environment
+		want       string
+	}{
+		{"e^x", "x", nil, "e^x"},
+		{"e^(2 * x)", "x", nil, "2 * e^(2 * x)"},
+		{"e^x", "x", bound, "e^x * ln(e)"},
+		{"e^2", "e", nil, "2 * e"},
+		{"e^e", "e", nil, "e^e * (ln(e) + 1)"},
+	}
+
+	for _, tt := range tests {
+		got, err := differentiate(t, nil, tt.expression, tt.variable, tt.env)
+		if err != nil {
+			t.Errorf("Differentiate(%q, %s) failed: %v", tt.expression, tt.variable, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Differentiate(%q, %s) = %s, want %s", tt.expression, tt.variable, got, tt.want)
+		}
+	}
+}
+
+func TestDerivativeErrors(t *testing.T) {
+	registry := NewFunctionRegistry()
+	if err := registry.Register("sin", 1, func(args []float64) (float64, error) { return 2 * args[0], nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.RegisterOperator("<+>", PrecedenceAdditive, LeftAssociative, func(left, right float64) (float64, error) {
+		return left + right, nil
+	}); err != nil {
+		t.Fatalf("RegisterOperator failed: %v", err)
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"factorial(x)", "cannot differentiate factorial: no derivative is known for it"},
+		{"x > 1", "cannot differentiate operator >"},
+		{"sin(x)", "cannot differentiate custom function sin"},
+		{"cos(sin(x))", "cannot differentiate custom function sin"},
+		{"x <+> 1", "cannot differentiate custom operator <+>"},
+	}
+	for _, tt := range tests {
+		_, err := differentiate(t, registry, tt.expression, "x", nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Differentiate(%q) error = %v, want %q", tt.expression, err, tt.want)
+		}
+	}
+
+	// Calls that do not depend on the variable differentiate to zero, whatever they do
+	if got, err := differentiate(t, registry, "sin(y) * x", "x", nil); err != nil || got != "sin(y)" {
+		t.Errorf("Differentiate(sin(y) * x) = %s, %v, want sin(y)", got, err)
+	}
+	if _, err := Differentiate(&VariableNode{Name: "x"}, "2x", nil); err == nil {
+		t.Errorf("Differentiate with an invalid variable succeeded")
+	}
+}
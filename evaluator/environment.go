@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Environment holds the variable bindings available while evaluating an expression
+// A nil *Environment is valid and behaves as an environment with no bindings
+type Environment struct {
+	variables map[string]Value
+	parent    *Environment           // the enclosing environment of a lambda call, consulted for unbound names
+	now       time.Time              // the time now() returns; the zero time means the current time
+	steps     *int                   // the steps left to sum, prod and integrate, shared by every form of an evaluation; nil before it starts
+	numerals  map[string]interface{} // the variables holding JSON numbers or numeric strings, as decoded, for the exact numeric modes
+}
+
+// NewEnvironment creates a new environment from a set of variable bindings
+func NewEnvironment(variables map[string]Value) *Environment {
+	bindings := make(map[string]Value, len(variables))
+	for name, value := range variables {
+		bindings[name] = value
+	}
+
+	return &Environment{
+		variables: bindings,
+	}
+}
+
+// NewEnvironmentFromJSON creates a new environment from variables decoded from a JSON object
+// JSON arrays become lists. Numbers decoded as json.Number, and strings holding a number, keep their text
+// so that the exact numeric modes read them without a detour through float64
+func NewEnvironmentFromJSON(variables map[string]interface{}) (*Environment, error) {
+	// Variables are converted in name order, so the first invalid one is always the one reported
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bindings := make(map[string]Value, len(variables))
+	var numerals map[string]interface{}
+	for _, name := range names {
+		raw := variables[name]
+		value, err := ValueOf(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid variable %s: %v", name, err)
+		}
+		bindings[name] = value
+
+		if hasNumeral(raw) {
+			if numerals == nil {
+				numerals = make(map[string]interface{})
+			}
+			numerals[name] = raw
+		}
+	}
+
+	return &Environment{
+		variables: bindings,
+		numerals:  numerals,
+	}, nil
+}
+
+// hasNumeral reports whether a decoded JSON value is, or is a list containing, a json.Number or numeric string
+func hasNumeral(raw interface{}) bool {
+	switch v := raw.(type) {
+	case json.Number:
+		return true
+	case string:
+		return isNumeral(v)
+	case []interface{}:
+		for _, element := range v {
+			if hasNumeral(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNumeral reports whether text is a number in JSON syntax, such as -12, 0.5 or 6.02e23
+func isNumeral(text string) bool {
+	i := 0
+	if i < len(text) && text[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		start := i
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+
+	if digits() == 0 {
+		return false
+	}
+	if i < len(text) && text[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		i++
+		if i < len(text) && (text[i] == '+' || text[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(text)
+}
+
+// Lookup returns the value bound to name and whether it was found
+func (e *Environment) Lookup(name string) (Value, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	if value, ok := e.variables[name]; ok {
+		return value, true
+	}
+	return e.parent.Lookup(name)
+}
+
+// extend returns a child environment binding variables on top of e, as for a lambda call
+func (e *Environment) extend(variables map[string]Value) *Environment {
+	child := &Environment{variables: variables, parent: e}
+	if e != nil {
+		child.now, child.steps = e.now, e.steps
+	}
+	return child
+}
+
+// prepared returns the environment ready for one evaluation: with a fresh budget of MaxIterationSteps
+// for sum, prod and integrate and with now() pinned to the current time, unless it already has them,
+// so that every form evaluated within one evaluation shares the same budget and sees the same time
+func (e *Environment) prepared() *Environment {
+	if e != nil && e.steps != nil && !e.now.IsZero() {
+		return e
+	}
+	var prepared Environment
+	if e != nil {
+		prepared = *e
+	}
+	if prepared.steps == nil {
+		steps := MaxIterationSteps
+		prepared.steps = &steps
+	}
+	if prepared.now.IsZero() {
+		prepared.now = time.Now().UTC()
+	}
+	return &prepared
+}
+
+// WithNow returns a copy of the environment in which now() returns t,
+// so that expressions depending on the current time give reproducible results
+func (e *Environment) WithNow(t time.Time) *Environment {
+	pinned := &Environment{now: t}
+	if e != nil {
+		pinned.variables, pinned.parent, pinned.steps, pinned.numerals = e.variables, e.parent, e.steps, e.numerals
+	}
+	return pinned
+}
+
+// Now returns the time now() returns in the environment: the pinned time if there is one,
+// otherwise the current time in UTC
+func (e *Environment) Now() time.Time {
+	if e == nil || e.now.IsZero() {
+		return time.Now().UTC()
+	}
+	return e.now
+}
@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestEnvironmentFromJSONNumbers checks that variables decoded with UseNumber, and numeric strings,
+// keep all of their digits in the exact numeric modes
+func TestEnvironmentFromJSONNumbers(t *testing.T) {
+	body := `{"a": 0.1, "b": 0.2, "big": 123456789012345678901234567890, "text": "98765432109876543210", "name": "ada", "half": 1.5, "list": [0.1, "0.2"]}`
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var variables map[string]interface{}
+	if err := decoder.Decode(&variables); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	tests := []struct {
+		mode       NumericMode
+		expression string
+		want       string
+	}{
+		{ModeFloat, "a + b", "0.30000000000000004"},
+		{ModeFloat, "name", "ada"},
+		{ModeFloat, "text", "98765432109876543210"},
+		{ModeDecimal, "a + b", "0.3"},
+		{ModeDecimal, "big + 1", "123456789012345678901234567891"},
+		{ModeDecimal, "text * 10", "987654321098765432100"},
+		{ModeDecimal, "list[0] + list[1]", "0.3"},
+		{ModeRational, "a + b", "3/10"},
+		{ModeBigInt, "big + 1", "123456789012345678901234567891"},
+		{ModeBigInt, "text + 1", "98765432109876543211"},
+		{ModeBigInt, "half", "1.5"},
+		{ModeBigInt, "name", "ada"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+tt.expression, func(t *testing.T) {
+			registry, err := NewNumericRegistry(NumericOptions{Mode: tt.mode})
+			if err != nil {
+				t.Fatalf("NewNumericRegistry failed: %v", err)
+			}
+			program, err := CompileWithRegistry(tt.expression, registry)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			env, err := NewEnvironmentFromJSON(variables)
+			if err != nil {
+				t.Fatalf("NewEnvironmentFromJSON failed: %v", err)
+			}
+			got, err := program.Evaluate(env)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Evaluate(%q) = %s, want %s", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvironmentFromJSONErrorOrder(t *testing.T) {
+	variables := map[string]interface{}{"c": struct{}{}, "a": struct{}{}, "b": struct{}{}}
+	for i := 0; i < 20; i++ {
+		_, err := NewEnvironmentFromJSON(variables)
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid variable a:") {
+			t.Fatalf("NewEnvironmentFromJSON error = %v, want one for variable a", err)
+		}
+	}
+}
+
+func TestIsNumeral(t *testing.T) {
+	for text, want := range map[string]bool{
+		"0": true, "-12": true, "0.5": true, "6.02e23": true, "1E-3": true,
+		"": false, "-": false, "1.": false, ".5": false, "1e": false, " 1": false, "0x10": false, "Inf": false, "NaN": false, "1_000": false,
+	} {
+		if got := isNumeral(text); got != want {
+			t.Errorf("isNumeral(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError describes a syntax error at a specific position in the input
+// Line and Column are 1-based; Column counts characters, not bytes
+type ParseError struct {
+	Message  string   `json:"message"`
+	Offset   int      `json:"offset"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Token    string   `json:"token"`
+	Expected []string `json:"expected,omitempty"`
+	Snippet  string   `json:"snippet"`
+}
+
+// Error implements the error interface for ParseError
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+}
+
+// newParseError builds a ParseError for the token found at byte offset in input
+// An empty token denotes the end of the input
+func newParseError(input string, offset int, token string, expected []string, message string) *ParseError {
+	lineStart := strings.LastIndex(input[:offset], "\n") + 1
+	lineEnd := strings.Index(input[offset:], "\n")
+	if lineEnd < 0 {
+		lineEnd = len(input)
+	} else {
+		lineEnd += offset
+	}
+
+	line := strings.Count(input[:offset], "\n") + 1
+	column := utf8.RuneCountInString(input[lineStart:offset]) + 1
+
+	return &ParseError{
+		Message:  message,
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+		Token:    token,
+		Expected: expected,
+		Snippet:  input[lineStart:lineEnd] + "\n" + strings.Repeat(" ", column-1) + "^",
+	}
+}
+
+// describeToken renders a token for use in an error message
+func describeToken(token string) string {
+	if token == "" {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s'", token)
+}
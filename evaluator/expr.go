@@ -6,51 +6,317 @@ import (
 
 // Expr represents an expression that can be evaluated
 type ExprNode interface {
-	Evaluate() (float64, error)
+	Evaluate(env *Environment) (Value, error)
 }
 
 // BinaryExpr represents a binary operation (e.g., 1 + 2)
+// The parser resolves the operator against its registry; nodes built by hand
+// fall back to the built-in operators
 type BinaryOpNode struct {
 	Left     ExprNode
 	Operator string
 	Right    ExprNode
+
+	op *Operator
 }
 
-// LiteralExpr represents a number literal
+// LiteralExpr represents a literal value (e.g., 42 or true)
 type ValueNode struct {
-	Value float64
+	Value Value
+}
+
+// VariableNode represents a reference to a named variable (e.g., price)
+type VariableNode struct {
+	Name string
+}
+
+// FunctionCallNode represents a function call (e.g., max(a, b, 3))
+// Like BinaryOpNode, it falls back to the built-ins when not created by the parser
+type FunctionCallNode struct {
+	Name string
+	Args []ExprNode
+
+	function *builtinFunction
 }
 
+// UnaryOpNode represents a prefix operation (e.g., -3, +x, !done)
+type UnaryOpNode struct {
+	Operator string
+	Operand  ExprNode
+}
+
+// LogicalOpNode represents a short-circuit logical operation (e.g., a && b)
+// The right operand is only evaluated when the left one does not decide the result
+type LogicalOpNode struct {
+	Left     ExprNode
+	Operator string
+	Right    ExprNode
+}
+
+// ConditionalNode represents a conditional (e.g., cond ? a : b or if(cond, a, b))
+// Only the selected branch is evaluated
+type ConditionalNode struct {
+	Condition ExprNode
+	Then      ExprNode
+	Else      ExprNode
+}
+
+// ConversionNode represents a unit conversion (e.g., 5 km to mi or speed in m/s)
+// Like BinaryOpNode, the parser resolves the unit; nodes built by hand parse Unit when evaluated
+type ConversionNode struct {
+	Operand ExprNode
+	Unit    string
+
+	unit *Unit
+}
+
+// ListNode represents a list literal (e.g., [1, 2, x])
+type ListNode struct {
+	Elements []ExprNode
+}
+
+// IndexNode represents indexing into a list (e.g., xs[0] or xs[-1])
+type IndexNode struct {
+	Target ExprNode
+	Index  ExprNode
+}
+
+// LambdaNode represents an anonymous function (e.g., p -> p * 1.2 or (a, b) -> a + b)
+// Evaluating it creates a Lambda that closes over the current environment
+type LambdaNode struct {
+	Params []string
+	Body   ExprNode
+}
+
+// IterationNode represents sum, prod or integrate of a body over a local variable,
+// such as sum(k^2, k, 1, 100) or integrate(x^2, x, 0, 1)
+// The variable hides any variable of the same name within the body
+type IterationNode struct {
+	Form     string // "sum", "prod" or "integrate"
+	Body     ExprNode
+	Variable string
+	From     ExprNode
+	To       ExprNode
+
+	op       *Operator        // combines the terms of a sum or product
+	numbers  *numberSystem    // the number system of the expression; nil in float mode
+	function *builtinFunction // the list aggregate of the same name, as for sum; nil for prod and integrate
+}
+
+// NowNode represents a call to now(), the current time or the time pinned by the environment
+type NowNode struct{}
+
 // Evaluate implements the Expr interface for BinaryExpr
-func (b *BinaryOpNode) Evaluate() (float64, error) {
-	left, err := b.Left.Evaluate()
+func (b *BinaryOpNode) Evaluate(env *Environment) (Value, error) {
+	left, err := b.Left.Evaluate(env)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	right, err := b.Right.Evaluate()
+	right, err := b.Right.Evaluate(env)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	switch b.Operator {
-	case "+":
-		return left + right, nil
-	case "-":
-		return left - right, nil
-	case "*":
-		return left * right, nil
-	case "/":
-		if right == 0 {
-			return 0, fmt.Errorf("division by zero")
-		}
-		return left / right, nil
-	default:
-		return 0, fmt.Errorf("unknown operator: %s", b.Operator)
+	op, err := b.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return op.apply(left, right)
+}
+
+// resolve returns the operator the node applies
+func (b *BinaryOpNode) resolve() (*Operator, error) {
+	if b.op != nil {
+		return b.op, nil
+	}
+	if op, ok := defaultRegistry.operator(b.Operator); ok {
+		return op, nil
 	}
+	return nil, fmt.Errorf("unknown operator: %s", b.Operator)
 }
 
 // Evaluate implements the Expr interface for LiteralExpr
-func (l *ValueNode) Evaluate() (float64, error) {
+func (l *ValueNode) Evaluate(env *Environment) (Value, error) {
 	return l.Value, nil
 }
+
+// Evaluate implements the Expr interface for VariableNode
+func (v *VariableNode) Evaluate(env *Environment) (Value, error) {
+	return lookupVariable(env, v.Name)
+}
+
+// Evaluate implements the Expr interface for FunctionCallNode
+func (f *FunctionCallNode) Evaluate(env *Environment) (Value, error) {
+	args := make([]Value, len(f.Args))
+	for i, arg := range f.Args {
+		value, err := arg.Evaluate(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	function, err := f.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return function.call(args)
+}
+
+// resolve returns the function the node calls
+func (f *FunctionCallNode) resolve() (*builtinFunction, error) {
+	if f.function != nil {
+		return f.function, nil
+	}
+	if function, ok := defaultRegistry.function(f.Name); ok {
+		return function, nil
+	}
+	return nil, &FunctionError{Function: f.Name, Message: "unknown function"}
+}
+
+// Evaluate implements the Expr interface for UnaryOpNode
+func (u *UnaryOpNode) Evaluate(env *Environment) (Value, error) {
+	operand, err := u.Operand.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyUnary(u.Operator, operand)
+}
+
+// Evaluate implements the Expr interface for ConversionNode
+func (c *ConversionNode) Evaluate(env *Environment) (Value, error) {
+	operand, err := c.Operand.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertUnit(operand, unit)
+}
+
+// resolve returns the unit the node converts to
+func (c *ConversionNode) resolve() (Unit, error) {
+	if c.unit != nil {
+		return *c.unit, nil
+	}
+	return parseUnit(c.Unit)
+}
+
+// Evaluate implements the Expr interface for ListNode
+func (l *ListNode) Evaluate(env *Environment) (Value, error) {
+	list := make(List, len(l.Elements))
+	for i, element := range l.Elements {
+		value, err := element.Evaluate(env)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+// Evaluate implements the Expr interface for IndexNode
+func (x *IndexNode) Evaluate(env *Environment) (Value, error) {
+	target, err := x.Target.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := x.Index.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return index(target, i)
+}
+
+// Evaluate implements the Expr interface for LambdaNode
+func (l *LambdaNode) Evaluate(env *Environment) (Value, error) {
+	return Lambda{params: l.Params, body: l.Body, env: env}, nil
+}
+
+// Evaluate implements the Expr interface for NowNode
+func (n *NowNode) Evaluate(env *Environment) (Value, error) {
+	return Date{t: env.Now()}, nil
+}
+
+// Evaluate implements the Expr interface for LogicalOpNode
+func (l *LogicalOpNode) Evaluate(env *Environment) (Value, error) {
+	left, err := l.Left.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	decided, err := shortCircuits(l.Operator, left)
+	if err != nil {
+		return nil, err
+	}
+	if decided {
+		return left, nil
+	}
+
+	right, err := l.Right.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := asBool(right, "operator "+l.Operator); err != nil {
+		return nil, err
+	}
+	return right, nil
+}
+
+// Evaluate implements the Expr interface for ConditionalNode
+func (c *ConditionalNode) Evaluate(env *Environment) (Value, error) {
+	condition, err := c.Condition.Evaluate(env)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := asBool(condition, "condition")
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return c.Then.Evaluate(env)
+	}
+	return c.Else.Evaluate(env)
+}
+
+// lookupVariable resolves name against env, falling back to the named constants
+func lookupVariable(env *Environment, name string) (Value, error) {
+	if value, ok := env.Lookup(name); ok {
+		return value, nil
+	}
+	if value, ok := constants[name]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("undefined variable: %s", name)
+}
+
+// shortCircuits reports whether the left operand of a logical operator decides its result:
+// false for &&, true for ||
+func shortCircuits(operator string, left Value) (bool, error) {
+	value, err := asBool(left, "operator "+operator)
+	if err != nil {
+		return false, err
+	}
+
+	switch operator {
+	case "&&":
+		return !value, nil
+	case "||":
+		return value, nil
+	default:
+		return false, fmt.Errorf("unknown logical operator: %s", operator)
+	}
+}
@@ -0,0 +1,232 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// precedenceAtom is the precedence of nodes that never need parentheses, such as numbers and calls
+const precedenceAtom = math.MaxInt
+
+// Format renders an expression tree as expression text that parses back into an equivalent tree
+// Parentheses are added only where precedence and associativity require them
+func Format(node ExprNode) string {
+	text, _ := format(node)
+	return text
+}
+
+// format renders node and returns the precedence it binds with,
+// so the caller can decide whether it needs parentheses
+func format(node ExprNode) (string, int) {
+	switch n := node.(type) {
+	case *ValueNode:
+		return formatValue(n.Value)
+
+	case *VariableNode:
+		return n.Name, precedenceAtom
+
+	case *BinaryOpNode:
+		precedence, rightAssociative := 0, false
+		if op, err := n.resolve(); err == nil {
+			precedence, rightAssociative = op.Precedence, op.Associativity == RightAssociative
+		}
+		left := operand(n.Left, precedence, rightAssociative)
+		right := operand(n.Right, precedence, !rightAssociative)
+		if n.Operator == "^" || n.Operator == "**" {
+			return left + n.Operator + right, precedence
+		}
+		return left + " " + n.Operator + " " + right, precedence
+
+	case *UnaryOpNode:
+		// A nested prefix operator keeps its parentheses so that - -x does not lex as --x
+		return n.Operator + operand(n.Operand, PrecedenceUnary, true), PrecedenceUnary
+
+	case *LogicalOpNode:
+		precedence := logicalOperators[n.Operator]
+		return operand(n.Left, precedence, false) + " " + n.Operator + " " + operand(n.Right, precedence, true), precedence
+
+	case *ConditionalNode:
+		return operand(n.Condition, 0, true) + " ? " + Format(n.Then) + " : " + Format(n.Else), 0
+
+	case *ConversionNode:
+		return operand(n.Operand, PrecedenceConversion, false) + " to " + n.Unit, PrecedenceConversion
+
+	case *FunctionCallNode:
+		return n.Name + "(" + formatAll(n.Args) + ")", precedenceAtom
+
+	case *ListNode:
+		return "[" + formatAll(n.Elements) + "]", precedenceAtom
+
+	case *IndexNode:
+		return operand(n.Target, precedenceAtom, false) + "[" + Format(n.Index) + "]", precedenceAtom
+
+	case *LambdaNode:
+		params := strings.Join(n.Params, ", ")
+		if len(n.Params) != 1 {
+			params = "(" + params + ")"
+		}
+		return params + " -> " + Format(n.Body), 0
+
+	case *IterationNode:
+		return n.Form + "(" + formatAll([]ExprNode{n.Body, &VariableNode{Name: n.Variable}, n.From, n.To}) + ")", precedenceAtom
+
+	case *NowNode:
+		return "now()", precedenceAtom
+	}
+	return fmt.Sprintf("%v", node), precedenceAtom
+}
+
+// operand renders a child of an operator with the given precedence, adding parentheses
+// when the child binds more loosely, or equally loosely and tie is set
+func operand(node ExprNode, precedence int, tie bool) string {
+	text, p := format(node)
+	if p < precedence || (p == precedence && tie) {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// formatAll renders nodes separated by commas
+func formatAll(nodes []ExprNode) string {
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		texts[i] = Format(node)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// formatValue renders a constant as a literal, with the precedence of a prefix minus when it is negative
+func formatValue(value Value) (string, int) {
+	var text string
+	switch v := value.(type) {
+	case String:
+		text = quoteString(string(v))
+	case Duration:
+		text = durationLiteral(time.Duration(v))
+	case List:
+		elements := make([]string, len(v))
+		for i, element := range v {
+			elements[i], _ = formatValue(element)
+		}
+		return "[" + strings.Join(elements, ", ") + "]", precedenceAtom
+	default:
+		text = value.String()
+	}
+
+	if strings.HasPrefix(text, "-") {
+		return text, PrecedenceUnary
+	}
+	return text, precedenceAtom
+}
+
+// quoteString renders s as a string literal using only the escapes the lexer accepts
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if unicode.IsControl(c) {
+				fmt.Fprintf(&b, `\u%04x`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// durationLiteral renders d as a duration literal such as 1d4h or 1.5s
+func durationLiteral(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"d", day}, {"h", time.Hour}, {"m", time.Minute}} {
+		if d >= unit.size {
+			fmt.Fprintf(&b, "%d%s", d/unit.size, unit.suffix)
+			d %= unit.size
+		}
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s")
+	}
+	return b.String()
+}
+
+// AST is the JSON form of an expression tree
+// Type names the kind of node; operands, arguments, elements and bodies are its Children, in order
+type AST struct {
+	Type     string   `json:"type"`               // "value", "variable", "binary", "unary", "logical", "conditional", "conversion", "call", "list", "index", "lambda", "iteration" or "now"
+	Kind     string   `json:"kind,omitempty"`     // The kind of a value node's value, e.g. "number"
+	Value    Value    `json:"value,omitempty"`    // The value of a value node
+	Name     string   `json:"name,omitempty"`     // The name of a variable or function, or the form of an iteration
+	Operator string   `json:"operator,omitempty"` // The operator of a binary, unary or logical node
+	Unit     string   `json:"unit,omitempty"`     // The unit a conversion node converts to
+	Params   []string `json:"params,omitempty"`   // The parameters of a lambda, or the variable of an iteration
+	Children []*AST   `json:"children,omitempty"`
+}
+
+// ASTOf converts an expression tree into its JSON form
+func ASTOf(node ExprNode) *AST {
+	switch n := node.(type) {
+	case *ValueNode:
+		return &AST{Type: "value", Kind: n.Value.Kind().String(), Value: n.Value}
+	case *VariableNode:
+		return &AST{Type: "variable", Name: n.Name}
+	case *BinaryOpNode:
+		return &AST{Type: "binary", Operator: n.Operator, Children: astsOf(n.Left, n.Right)}
+	case *UnaryOpNode:
+		return &AST{Type: "unary", Operator: n.Operator, Children: astsOf(n.Operand)}
+	case *LogicalOpNode:
+		return &AST{Type: "logical", Operator: n.Operator, Children: astsOf(n.Left, n.Right)}
+	case *ConditionalNode:
+		return &AST{Type: "conditional", Children: astsOf(n.Condition, n.Then, n.Else)}
+	case *ConversionNode:
+		return &AST{Type: "conversion", Unit: n.Unit, Children: astsOf(n.Operand)}
+	case *FunctionCallNode:
+		return &AST{Type: "call", Name: n.Name, Children: astsOf(n.Args...)}
+	case *ListNode:
+		return &AST{Type: "list", Children: astsOf(n.Elements...)}
+	case *IndexNode:
+		return &AST{Type: "index", Children: astsOf(n.Target, n.Index)}
+	case *LambdaNode:
+		return &AST{Type: "lambda", Params: n.Params, Children: astsOf(n.Body)}
+	case *IterationNode:
+		return &AST{Type: "iteration", Name: n.Form, Params: []string{n.Variable}, Children: astsOf(n.Body, n.From, n.To)}
+	case *NowNode:
+		return &AST{Type: "now"}
+	}
+	return &AST{Type: fmt.Sprintf("%T", node)}
+}
+
+// astsOf converts each of nodes into its JSON form
+func astsOf(nodes ...ExprNode) []*AST {
+	asts := make([]*AST, len(nodes))
+	for i, node := range nodes {
+		asts[i] = ASTOf(node)
+	}
+	return asts
+}
@@ -0,0 +1,232 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+)
+
+// FunctionError represents a failure while calling a function,
+// such as an arity mismatch or an argument outside the function's domain
+type FunctionError struct {
+	Function string
+	Message  string
+}
+
+// Error implements the error interface for FunctionError
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Function, e.Message)
+}
+
+// builtinFunction describes a function and the number of arguments it accepts
+// A maxArgs of -1 means the function accepts any number of arguments from minArgs upward
+// Exactly one of fn and valueFn is set; fn functions only accept numbers
+type builtinFunction struct {
+	name    string
+	minArgs int
+	maxArgs int
+	fn      Function
+	valueFn ValueFunction
+
+	builtin bool // set for the built-in functions, which have no side effects and may be evaluated ahead of time
+}
+
+// constants holds the named constants available to every expression
+// Variables supplied by the caller take precedence over these
+var constants = map[string]Value{
+	"pi": Number(math.Pi),
+	"e":  Number(math.E),
+}
+
+// builtinTables lists the tables of functions every registry starts with
+var builtinTables = []map[string]builtinFunction{
+	builtins,
+	stringBuiltins,
+	dateBuiltins,
+	listBuiltins,
+	matrixBuiltins,
+}
+
+// builtins holds the numeric functions available to every expression
+var builtins = map[string]builtinFunction{
+	"sqrt": unary(func(x float64) (float64, error) {
+		if x < 0 {
+			return 0, fmt.Errorf("argument must be non-negative")
+		}
+		return math.Sqrt(x), nil
+	}),
+	"abs":   unary(total(math.Abs)),
+	"floor": unary(total(math.Floor)),
+	"ceil":  unary(total(math.Ceil)),
+	"round": {minArgs: 1, maxArgs: 2, fn: round},
+	"exp": unary(func(x float64) (float64, error) {
+		result := math.Exp(x)
+		if math.IsInf(result, 0) {
+			return 0, fmt.Errorf("result out of range")
+		}
+		return result, nil
+	}),
+	"ln":    unary(logarithm(math.Log)),
+	"log10": unary(logarithm(math.Log10)),
+	"log": {minArgs: 2, maxArgs: 2, fn: func(args []float64) (float64, error) {
+		x, base := args[0], args[1]
+		if x <= 0 {
+			return 0, fmt.Errorf("argument must be positive")
+		}
+		if base <= 0 || base == 1 {
+			return 0, fmt.Errorf("base must be positive and not equal to 1")
+		}
+		return math.Log(x) / math.Log(base), nil
+	}},
+	"sin":  unary(total(math.Sin)),
+	"cos":  unary(total(math.Cos)),
+	"tan":  unary(total(math.Tan)),
+	"asin": unary(inverseTrig(math.Asin)),
+	"acos": unary(inverseTrig(math.Acos)),
+	"atan": unary(total(math.Atan)),
+	"atan2": {minArgs: 2, maxArgs: 2, fn: func(args []float64) (float64, error) {
+		return math.Atan2(args[0], args[1]), nil
+	}},
+	"sinh": unary(total(math.Sinh)),
+	"cosh": unary(total(math.Cosh)),
+	"tanh": unary(total(math.Tanh)),
+	"hypot": {minArgs: 2, maxArgs: 2, fn: func(args []float64) (float64, error) {
+		return math.Hypot(args[0], args[1]), nil
+	}},
+	"factorial": unary(func(x float64) (float64, error) {
+		if x < 0 || x != math.Trunc(x) {
+			return 0, fmt.Errorf("argument must be a non-negative integer")
+		}
+		if x > 170 {
+			return 0, fmt.Errorf("result out of range")
+		}
+		result := 1.0
+		for i := 2.0; i <= x; i++ {
+			result *= i
+		}
+		return result, nil
+	}),
+	"min": acrossUnits(builtinFunction{minArgs: 1, maxArgs: -1, fn: func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result, nil
+	}}),
+	"max": acrossUnits(builtinFunction{minArgs: 1, maxArgs: -1, fn: func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result, nil
+	}}),
+}
+
+// call checks the arity of the function and invokes it
+func (f *builtinFunction) call(args []Value) (Value, error) {
+	if len(args) < f.minArgs || (f.maxArgs >= 0 && len(args) > f.maxArgs) {
+		return nil, &FunctionError{Function: f.name, Message: arityMessage(f.minArgs, f.maxArgs, len(args))}
+	}
+
+	result, err := f.invoke(args)
+	if err != nil {
+		return nil, &FunctionError{Function: f.name, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// invoke runs the implementation, converting arguments for numeric functions
+func (f *builtinFunction) invoke(args []Value) (Value, error) {
+	if f.valueFn != nil {
+		return f.valueFn(args)
+	}
+
+	numbers := make([]float64, len(args))
+	for i, arg := range args {
+		n, err := asNumber(arg, fmt.Sprintf("argument %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = n
+	}
+
+	result, err := f.fn(numbers)
+	if err != nil {
+		return nil, err
+	}
+	return Number(result), nil
+}
+
+// arityMessage describes the expected number of arguments for an arity error
+func arityMessage(minArgs, maxArgs, got int) string {
+	switch {
+	case maxArgs < 0:
+		return fmt.Sprintf("expected at least %d argument(s), got %d", minArgs, got)
+	case minArgs == maxArgs:
+		return fmt.Sprintf("expected %d argument(s), got %d", minArgs, got)
+	default:
+		return fmt.Sprintf("expected %d to %d arguments, got %d", minArgs, maxArgs, got)
+	}
+}
+
+// unary adapts a single-argument function to the builtinFunction form
+func unary(fn func(x float64) (float64, error)) builtinFunction {
+	return builtinFunction{
+		minArgs: 1,
+		maxArgs: 1,
+		fn: func(args []float64) (float64, error) {
+			return fn(args[0])
+		},
+	}
+}
+
+// total adapts a function defined for every real argument
+func total(fn func(x float64) float64) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		return fn(x), nil
+	}
+}
+
+// logarithm adapts a logarithm, which is only defined for positive arguments
+func logarithm(fn func(x float64) float64) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, fmt.Errorf("argument must be positive")
+		}
+		return fn(x), nil
+	}
+}
+
+// inverseTrig adapts asin and acos, which are only defined on [-1, 1]
+func inverseTrig(fn func(x float64) float64) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		if x < -1 || x > 1 {
+			return 0, fmt.Errorf("argument must be between -1 and 1")
+		}
+		return fn(x), nil
+	}
+}
+
+// maxRoundDigits bounds the digits of round to the decimal exponents of float64
+const maxRoundDigits = 308
+
+// round rounds x half away from zero to the given number of decimal digits (default 0)
+func round(args []float64) (float64, error) {
+	if len(args) == 1 {
+		return math.Round(args[0]), nil
+	}
+
+	digits := args[1]
+	if digits != math.Trunc(digits) {
+		return 0, fmt.Errorf("digits must be an integer")
+	}
+	if math.Abs(digits) > maxRoundDigits {
+		return 0, fmt.Errorf("digits must be between -%d and %d", maxRoundDigits, maxRoundDigits)
+	}
+
+	// A number already has fewer digits than asked for when scaling it overflows
+	scale := math.Pow(10, digits)
+	if scaled := args[0] * scale; !math.IsInf(scaled, 0) {
+		return math.Round(scaled) / scale, nil
+	}
+	return args[0], nil
+}
@@ -0,0 +1,51 @@
+package evaluator
+
+import "testing"
+
+func TestMathFunctions(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "sqrt(16)", want: "4"},
+		{expression: "abs(-3)", want: "3"},
+		{expression: "floor(-1.5)", want: "-2"},
+		{expression: "ceil(-1.5)", want: "-1"},
+		{expression: "round(-2.5)", want: "-3"},
+		{expression: "round(2.345, 2)", want: "2.35"},
+		{expression: "round(1234, -2)", want: "1200"},
+		{expression: "round(1.5, 308)", want: "1.5"},
+		{expression: "round(1e300, 308)", want: "1e+300"},
+		{expression: "round(1.5, -308)", want: "0"},
+		{expression: "log(8, 2)", want: "3"},
+		{expression: "log10(1000)", want: "3"},
+		{expression: "atan2(1, 1) * 4 == pi", want: "true"},
+		{expression: "hypot(3, 4)", want: "5"},
+		{expression: "max(3, 1, 2)", want: "3"},
+		{expression: "min(3, 1, 2)", want: "1"},
+		{expression: "factorial(5)", want: "120"},
+	})
+}
+
+func TestMathFunctionErrors(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		// Arity
+		{expression: "sqrt(1, 2)", err: "sqrt: expected 1 argument(s), got 2"},
+		{expression: "sin()", err: "sin: expected 1 argument(s), got 0"},
+		{expression: "min()", err: "min: expected at least 1 argument(s), got 0"},
+		{expression: "log(8)", err: "log: expected 2 argument(s), got 1"},
+		{expression: "round(1, 2, 3)", err: "round"},
+		// Domain
+		{expression: "sqrt(-1)", err: "sqrt: argument must be non-negative"},
+		{expression: "ln(0)", err: "ln: argument must be positive"},
+		{expression: "log10(-1)", err: "log10: argument must be positive"},
+		{expression: "log(8, 1)", err: "log: base must be positive and not equal to 1"},
+		{expression: "log(-8, 2)", err: "log: argument must be positive"},
+		{expression: "asin(2)", err: "asin: argument must be between -1 and 1"},
+		{expression: "acos(-1.5)", err: "acos: argument must be between -1 and 1"},
+		{expression: "exp(1000)", err: "exp: result out of range"},
+		{expression: "factorial(-1)", err: "factorial: argument must be a non-negative integer"},
+		{expression: "factorial(2.5)", err: "factorial: argument must be a non-negative integer"},
+		{expression: "round(1.5, 0.5)", err: "round: digits must be an integer"},
+		{expression: "round(1.5, 400)", err: "round: digits must be between -308 and 308"},
+		{expression: "round(1.5, -400)", err: "round: digits must be between -308 and 308"},
+		{expression: "nosuch(1)", err: "unknown function: nosuch"},
+	})
+}
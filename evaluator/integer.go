@@ -0,0 +1,408 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Limits on the size of bigint results, in bits
+const (
+	DefaultIntegerBits = 1 << 16
+	MaxIntegerBits     = 1 << 20
+)
+
+// Integer is an arbitrary-precision integer, produced in bigint mode
+// Integers are immutable; every operation returns a new value
+type Integer struct {
+	n *big.Int
+}
+
+// Kind implements the Value interface for Integer
+func (i Integer) Kind() Kind {
+	return KindInteger
+}
+
+// String implements the Value interface for Integer
+func (i Integer) String() string {
+	return i.int().String()
+}
+
+// MarshalJSON encodes the integer as a JSON string, since JSON numbers
+// are commonly read as float64 and would lose digits above 2^53
+func (i Integer) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(i.String())), nil
+}
+
+// int returns the integer, treating the zero Integer as 0
+func (i Integer) int() *big.Int {
+	if i.n == nil {
+		return new(big.Int)
+	}
+	return i.n
+}
+
+// float returns the nearest float64 to i
+func (i Integer) float() float64 {
+	f, _ := new(big.Float).SetInt(i.int()).Float64()
+	return f
+}
+
+// neg returns -i
+func (i Integer) neg() Integer {
+	return Integer{n: new(big.Int).Neg(i.int())}
+}
+
+// toInteger converts an Integer, or a Number holding a whole value, to a big.Int
+func toInteger(v Value) (*big.Int, bool) {
+	switch n := v.(type) {
+	case Integer:
+		return n.int(), true
+	case Number:
+		f := float64(n)
+		if math.IsInf(f, 0) || f != math.Trunc(f) {
+			return nil, false
+		}
+		i, _ := big.NewFloat(f).Int(nil)
+		return i, true
+	default:
+		return nil, false
+	}
+}
+
+// integerContext holds the size limit applied to every bigint result
+type integerContext struct {
+	maxBits int
+}
+
+// checked wraps n as a Value, rejecting results beyond the size limit
+func (ctx integerContext) checked(n *big.Int) (Value, error) {
+	if n.BitLen() > ctx.maxBits {
+		return nil, ctx.tooLarge()
+	}
+	return Integer{n: n}, nil
+}
+
+// tooLarge reports a result beyond the size limit
+func (ctx integerContext) tooLarge() error {
+	return fmt.Errorf("integer result exceeds %d bits", ctx.maxBits)
+}
+
+// fits reports whether a result estimated at bits bits is within the size limit
+// Estimates come from floating point, so a little slack is allowed and the exact
+// result is checked again afterwards
+func (ctx integerContext) fits(bits float64) bool {
+	return bits <= float64(ctx.maxBits)+64
+}
+
+// numberSystem parses literals as integers and converts bound numbers holding whole values
+// Other numbers, and the results of functions computed in floating point, stay numbers,
+// which arithmetic accepts only when whole
+func (ctx integerContext) numberSystem() *numberSystem {
+	return &numberSystem{
+		literal: func(text string) (Value, error) {
+			d, err := parseDecimal(text)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := decimalInteger(d)
+			if !ok {
+				return nil, fmt.Errorf("invalid number: %s is not an integer", text)
+			}
+			return ctx.checked(n)
+		},
+		convert: func(value Value) (Value, error) {
+			if _, ok := value.(Number); !ok {
+				return value, nil
+			}
+			n, ok := toInteger(value)
+			if !ok {
+				return value, nil
+			}
+			return ctx.checked(n)
+		},
+	}
+}
+
+// decimalInteger returns d as a big.Int when it is a whole number
+func decimalInteger(d Decimal) (*big.Int, bool) {
+	if d.exponent >= 0 {
+		return new(big.Int).Mul(d.coef(), pow10(d.exponent)), true
+	}
+	q, r := new(big.Int).QuoRem(d.coef(), pow10(-d.exponent), new(big.Int))
+	return q, r.Sign() == 0
+}
+
+// operators returns the arithmetic and bitwise operators of bigint mode
+// The bitwise operators take Go's precedence: & << >> bind like *, and | xor like +
+// Comparisons use the built-in operators, which compare integers exactly
+func (ctx integerContext) operators() []*Operator {
+	return []*Operator{
+		integerOperator("+", PrecedenceAdditive, func(a, b *big.Int) (Value, error) {
+			return ctx.checked(new(big.Int).Add(a, b))
+		}),
+		integerOperator("-", PrecedenceAdditive, func(a, b *big.Int) (Value, error) {
+			return ctx.checked(new(big.Int).Sub(a, b))
+		}),
+		integerOperator("*", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			if a.BitLen()+b.BitLen() > ctx.maxBits+1 {
+				return nil, ctx.tooLarge()
+			}
+			return ctx.checked(new(big.Int).Mul(a, b))
+		}),
+		integerOperator("/", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+			if r.Sign() != 0 {
+				return nil, fmt.Errorf("%s / %s is not an integer; use // for floor division", a, b)
+			}
+			return Integer{n: q}, nil
+		}),
+		integerOperator("//", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return Integer{n: floorQuo(a, b)}, nil
+		}),
+		integerOperator("%", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("modulo by zero")
+			}
+			return Integer{n: new(big.Int).Sub(a, new(big.Int).Mul(b, floorQuo(a, b)))}, nil
+		}),
+		rightAssociative(integerOperator("^", PrecedenceExponent, ctx.pow)),
+		rightAssociative(integerOperator("**", PrecedenceExponent, ctx.pow)),
+		integerOperator("&", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			return Integer{n: new(big.Int).And(a, b)}, nil
+		}),
+		integerOperator("|", PrecedenceAdditive, func(a, b *big.Int) (Value, error) {
+			return Integer{n: new(big.Int).Or(a, b)}, nil
+		}),
+		integerOperator("xor", PrecedenceAdditive, func(a, b *big.Int) (Value, error) {
+			return Integer{n: new(big.Int).Xor(a, b)}, nil
+		}),
+		integerOperator("<<", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			shift, err := shiftCount(b)
+			if err != nil {
+				return nil, err
+			}
+			if a.Sign() != 0 && uint64(a.BitLen())+shift > uint64(ctx.maxBits) {
+				return nil, ctx.tooLarge()
+			}
+			return Integer{n: new(big.Int).Lsh(a, uint(shift))}, nil
+		}),
+		integerOperator(">>", PrecedenceMultiplicative, func(a, b *big.Int) (Value, error) {
+			shift, err := shiftCount(b)
+			if err != nil {
+				return nil, err
+			}
+			if shift > uint64(a.BitLen()) {
+				shift = uint64(a.BitLen())
+			}
+			return Integer{n: new(big.Int).Rsh(a, uint(shift))}, nil
+		}),
+	}
+}
+
+// integerOperator creates a left associative operator over integers
+// Numbers holding whole values, such as the results of len, are accepted too
+func integerOperator(symbol string, precedence int, fn func(a, b *big.Int) (Value, error)) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: precedence,
+		ValueFn: func(left, right Value) (Value, error) {
+			l, lok := toInteger(left)
+			r, rok := toInteger(right)
+			if !lok || !rok {
+				return nil, operandTypeError(symbol, left, right)
+			}
+			return fn(l, r)
+		},
+	}
+}
+
+// shiftCount validates the right operand of a shift
+func shiftCount(n *big.Int) (uint64, error) {
+	if n.Sign() < 0 {
+		return 0, fmt.Errorf("negative shift count")
+	}
+	if !n.IsUint64() || n.Uint64() > math.MaxInt32 {
+		return math.MaxInt32, nil
+	}
+	return n.Uint64(), nil
+}
+
+// pow raises base to a non-negative integer exponent
+func (ctx integerContext) pow(base, exponent *big.Int) (Value, error) {
+	if exponent.Sign() < 0 {
+		return nil, fmt.Errorf("negative exponent in bigint mode")
+	}
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		// 0, 1 and -1 stay small whatever the exponent
+		if base.Sign() < 0 && exponent.Bit(0) == 0 {
+			return Integer{n: big.NewInt(1)}, nil
+		}
+		if base.Sign() == 0 && exponent.Sign() == 0 {
+			return Integer{n: big.NewInt(1)}, nil
+		}
+		return Integer{n: base}, nil
+	}
+
+	if !exponent.IsInt64() || !ctx.fits(float64(base.BitLen()-1)*float64(exponent.Int64())) {
+		return nil, ctx.tooLarge()
+	}
+	return ctx.checked(new(big.Int).Exp(base, exponent, nil))
+}
+
+// functions returns the functions of bigint mode
+func (ctx integerContext) functions() map[string]builtinFunction {
+	return map[string]builtinFunction{
+		"factorial": integerFunction(1, 1, func(args []*big.Int) (Value, error) {
+			n, err := nonNegative(args[0], "argument")
+			if err != nil {
+				return nil, err
+			}
+			if !ctx.fits(lgammaBits(n + 1)) {
+				return nil, ctx.tooLarge()
+			}
+			return ctx.checked(new(big.Int).MulRange(1, n))
+		}),
+		"nCr": integerFunction(2, 2, func(args []*big.Int) (Value, error) {
+			n, k, err := choose(args)
+			if err != nil {
+				return nil, err
+			}
+			if !ctx.fits(lgammaBits(n+1) - lgammaBits(k+1) - lgammaBits(n-k+1)) {
+				return nil, ctx.tooLarge()
+			}
+			return ctx.checked(new(big.Int).Binomial(n, k))
+		}),
+		"nPr": integerFunction(2, 2, func(args []*big.Int) (Value, error) {
+			n, k, err := choose(args)
+			if err != nil {
+				return nil, err
+			}
+			if !ctx.fits(lgammaBits(n+1) - lgammaBits(n-k+1)) {
+				return nil, ctx.tooLarge()
+			}
+			return ctx.checked(new(big.Int).MulRange(n-k+1, n))
+		}),
+		"gcd": integerFunction(1, Variadic, func(args []*big.Int) (Value, error) {
+			result := new(big.Int).Abs(args[0])
+			for _, arg := range args[1:] {
+				result.GCD(nil, nil, result, new(big.Int).Abs(arg))
+			}
+			return Integer{n: result}, nil
+		}),
+		"lcm": integerFunction(1, Variadic, func(args []*big.Int) (Value, error) {
+			result := new(big.Int).Abs(args[0])
+			for _, arg := range args[1:] {
+				if result.Sign() == 0 || arg.Sign() == 0 {
+					result.SetInt64(0)
+					continue
+				}
+				gcd := new(big.Int).GCD(nil, nil, result, new(big.Int).Abs(arg))
+				result.Mul(result.Quo(result, gcd), new(big.Int).Abs(arg))
+				if result.BitLen() > ctx.maxBits {
+					return nil, ctx.tooLarge()
+				}
+			}
+			return Integer{n: result}, nil
+		}),
+		"modpow": integerFunction(3, 3, func(args []*big.Int) (Value, error) {
+			base, exponent, modulus := args[0], args[1], args[2]
+			if modulus.Sign() <= 0 {
+				return nil, fmt.Errorf("modulus must be positive")
+			}
+			result := new(big.Int).Exp(base, exponent, modulus)
+			if result == nil {
+				return nil, fmt.Errorf("base has no inverse modulo %s", modulus)
+			}
+			return Integer{n: result}, nil
+		}),
+		"abs": integerFunction(1, 1, func(args []*big.Int) (Value, error) {
+			return Integer{n: new(big.Int).Abs(args[0])}, nil
+		}),
+		"floor": integerFunction(1, 1, integerIdentity),
+		"ceil":  integerFunction(1, 1, integerIdentity),
+		"round": integerFunction(1, 2, func(args []*big.Int) (Value, error) {
+			if len(args) == 1 || args[1].Sign() >= 0 {
+				return Integer{n: args[0]}, nil
+			}
+			if args[1].CmpAbs(big.NewInt(maxDecimalExponent)) > 0 {
+				return nil, fmt.Errorf("digits out of range")
+			}
+			rounded := ratRound(new(big.Rat).SetInt(args[0]), int(args[1].Int64()))
+			return Integer{n: rounded.Num()}, nil
+		}),
+		"min": integerFunction(1, Variadic, integerExtremum(-1)),
+		"max": integerFunction(1, Variadic, integerExtremum(1)),
+	}
+}
+
+// integerFunction creates a function over integers
+// Numbers holding whole values are accepted as arguments too
+func integerFunction(minArgs, maxArgs int, fn func(args []*big.Int) (Value, error)) builtinFunction {
+	return builtinFunction{minArgs: minArgs, maxArgs: maxArgs, valueFn: func(args []Value) (Value, error) {
+		ints := make([]*big.Int, len(args))
+		for i, arg := range args {
+			n, ok := toInteger(arg)
+			if !ok {
+				return nil, newTypeError("argument %d expects an integer, got %s", i+1, arg.Kind())
+			}
+			ints[i] = n
+		}
+		return fn(ints)
+	}}
+}
+
+// integerIdentity returns its only argument unchanged
+func integerIdentity(args []*big.Int) (Value, error) {
+	return Integer{n: args[0]}, nil
+}
+
+// integerExtremum creates min (sign -1) or max (sign 1) over integers
+func integerExtremum(sign int) func(args []*big.Int) (Value, error) {
+	return func(args []*big.Int) (Value, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) == sign {
+				result = arg
+			}
+		}
+		return Integer{n: result}, nil
+	}
+}
+
+// nonNegative returns n as an int64, rejecting negative and oversized values
+func nonNegative(n *big.Int, name string) (int64, error) {
+	if n.Sign() < 0 || !n.IsInt64() {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n.Int64(), nil
+}
+
+// choose validates the n and r arguments of nCr and nPr
+func choose(args []*big.Int) (int64, int64, error) {
+	n, err := nonNegative(args[0], "n")
+	if err != nil {
+		return 0, 0, err
+	}
+	k, err := nonNegative(args[1], "r")
+	if err != nil {
+		return 0, 0, err
+	}
+	if k > n {
+		return 0, 0, fmt.Errorf("r must not exceed n")
+	}
+	return n, k, nil
+}
+
+// lgammaBits returns log2 of (n - 1)!, the number of bits in the factorial
+func lgammaBits(n int64) float64 {
+	lgamma, _ := math.Lgamma(float64(n))
+	return lgamma / math.Ln2
+}
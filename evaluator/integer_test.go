@@ -0,0 +1,61 @@
+package evaluator
+
+import "testing"
+
+func TestBigIntArithmetic(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeBigInt})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "2^70", want: "1180591620717411303424"},
+		{expression: "30!", want: "265252859812191058636308480000000"},
+		{expression: "factorial(30) == 30!", want: "true"},
+		{expression: "10^20 - 1 == 99999999999999999999", want: "true"},
+		{expression: "7 // 2", want: "3"},
+		{expression: "-7 % 3", want: "2"},
+		{expression: "gcd(12, 18)", want: "6"},
+		{expression: "min(3, 2^70)", want: "3"},
+		{expression: "7 / 2", err: "7 / 2 is not an integer; use // for floor division"},
+		{expression: "1 / 0", err: "division by zero"},
+		{expression: "2^-1", err: "negative exponent in bigint mode"},
+		{expression: "1.5", err: "1.5 is not an integer"},
+	})
+}
+
+// TestBigIntBitLimit checks that results larger than maxBits are rejected before they are computed
+func TestBigIntBitLimit(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeBigInt})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "2^65535 == 2^65534 * 2", want: "true"},
+		{expression: "2^100000", err: "integer result exceeds 65536 bits"},
+		{expression: "2^(2^40)", err: "integer result exceeds 65536 bits"},
+		{expression: "2^40000 * 2^40000", err: "integer result exceeds 65536 bits"},
+		{expression: "factorial(100000)", err: "factorial: integer result exceeds 65536 bits"},
+	})
+
+	small, err := NewNumericRegistry(NumericOptions{Mode: ModeBigInt, MaxBits: 64})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+	checkOutcomes(t, small, nil, []outcome{
+		{expression: "2^63", want: "9223372036854775808"},
+		{expression: "2^64", err: "integer result exceeds 64 bits"},
+		{expression: "2^63 + 2^63", err: "integer result exceeds 64 bits"},
+		{expression: "21!", err: "integer result exceeds 64 bits"},
+	})
+
+	for _, options := range []NumericOptions{
+		{Mode: ModeBigInt, MaxBits: -1},
+		{Mode: ModeBigInt, MaxBits: MaxIntegerBits + 1},
+		{Mode: ModeDecimal, MaxBits: 64},
+	} {
+		if _, err := NewNumericRegistry(options); err == nil {
+			t.Errorf("NewNumericRegistry(%+v) succeeded, want an error", options)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxIterationSteps limits the work of sum, prod and integrate within one evaluation: the number of terms
+// of every sum and product and of evaluations of every integrand, nested, side by side or called from lambdas
+const MaxIterationSteps = 1000000
+
+// maxIterationBound is the largest magnitude of the bounds of a sum or product, 2^53,
+// beyond which consecutive whole numbers are no longer all float64 values
+const maxIterationBound = 1 << 53
+
+// Limits of the adaptive quadrature behind integrate
+const (
+	integralTolerance   = 1e-10 // the accepted absolute or relative error of an integral
+	maxIntegralSegments = 1000  // the number of segments the interval may be split into
+)
+
+// iterationForms are the functions written like calls that bind a local variable when their
+// second argument is a variable, as in sum(k^2, k, 1, 100); sum(list) remains the list aggregate,
+// as does sum(a, b, c, d) when b is a bound variable or a constant, for which prod fails
+var iterationForms = map[string]bool{
+	"sum":       true,
+	"prod":      true,
+	"integrate": true,
+}
+
+// Evaluate implements the Expr interface for IterationNode
+func (n *IterationNode) Evaluate(env *Environment) (Value, error) {
+	if call, ok, err := n.aggregate(env); ok {
+		if err != nil {
+			return nil, err
+		}
+		return call.Evaluate(env)
+	}
+
+	from, err := n.bound(n.From, env)
+	if err != nil {
+		return nil, err
+	}
+	to, err := n.bound(n.To, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Programs set the budget once per evaluation; a form evaluated on its own, as when
+	// constants are folded, sets the budget that nested forms draw on
+	env = env.prepared()
+
+	if n.Form == "integrate" {
+		return n.integrate(env, from, to)
+	}
+	return n.accumulate(env, from, to)
+}
+
+// aggregate returns the ordinary call that a sum or prod stands for when its variable is already bound in env
+// or is a constant, so that sum(a, b, c, d) adds four numbers rather than a from c to d over a local b
+// prod, which is not a list aggregate, fails instead, and integrate always binds its variable
+func (n *IterationNode) aggregate(env *Environment) (*FunctionCallNode, bool, error) {
+	if n.Form == "integrate" {
+		return nil, false, nil
+	}
+	if _, err := lookupVariable(env, n.Variable); err != nil {
+		return nil, false, nil
+	}
+	if n.function == nil {
+		return nil, true, fmt.Errorf("%s cannot bind %s, which is already a variable or a constant", n.Form, n.Variable)
+	}
+	return &FunctionCallNode{Name: n.Form, Args: []ExprNode{n.Body, &VariableNode{Name: n.Variable}, n.From, n.To}, function: n.function}, true, nil
+}
+
+// bound evaluates one of the bounds of the form as a real number
+func (n *IterationNode) bound(node ExprNode, env *Environment) (float64, error) {
+	value, err := node.Evaluate(env)
+	if err != nil {
+		return 0, err
+	}
+	x, err := asNumber(value, n.Form)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, fmt.Errorf("%s expects finite bounds, got %v", n.Form, x)
+	}
+	return x, nil
+}
+
+// step uses one step of the budget, failing once it is exhausted
+func (n *IterationNode) step(env *Environment) error {
+	if *env.steps <= 0 {
+		return fmt.Errorf("%s exceeds the limit of %d steps", n.Form, MaxIterationSteps)
+	}
+	*env.steps--
+	return nil
+}
+
+// number converts x into the number system of the expression
+func (n *IterationNode) number(x float64) (Value, error) {
+	if n.numbers == nil {
+		return Number(x), nil
+	}
+	return n.numbers.convert(Number(x))
+}
+
+// at evaluates the body with the variable bound to x
+func (n *IterationNode) at(env *Environment, x float64) (Value, error) {
+	value, err := n.number(x)
+	if err != nil {
+		return nil, err
+	}
+	return n.Body.Evaluate(env.extend(map[string]Value{n.Variable: value}))
+}
+
+// accumulate adds or multiplies the values of the body for each whole number from..to
+// An empty range gives 0 for a sum and 1 for a product
+func (n *IterationNode) accumulate(env *Environment, from, to float64) (Value, error) {
+	if from != math.Trunc(from) || to != math.Trunc(to) {
+		return nil, fmt.Errorf("%s expects whole-number bounds, got %v and %v", n.Form, from, to)
+	}
+	if math.Abs(from) > maxIterationBound || math.Abs(to) > maxIterationBound {
+		return nil, fmt.Errorf("%s expects bounds of at most 2^53 in magnitude, got %v and %v", n.Form, from, to)
+	}
+	terms := to - from + 1
+	if terms > float64(*env.steps) {
+		// The evaluation cannot finish, so it uses up the budget as if it had run out of steps
+		left := *env.steps
+		*env.steps = 0
+		if left < MaxIterationSteps {
+			return nil, fmt.Errorf("%s over %v terms exceeds the limit of %d steps, of which %d are left", n.Form, terms, MaxIterationSteps, left)
+		}
+		return nil, fmt.Errorf("%s over %v terms exceeds the limit of %d steps", n.Form, terms, MaxIterationSteps)
+	}
+
+	// Counting the terms with an integer keeps each k exact, where k++ would stop advancing at 2^53
+	var result Value
+	for i := 0; i < int(terms); i++ {
+		if err := n.step(env); err != nil {
+			return nil, err
+		}
+		term, err := n.at(env, from+float64(i))
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = term
+		} else if result, err = n.op.apply(result, term); err != nil {
+			return nil, err
+		}
+	}
+
+	if result == nil {
+		if n.Form == "prod" {
+			return n.number(1)
+		}
+		return n.number(0)
+	}
+	return result, nil
+}
+
+// segment is a part of the interval of integration with its Gauss–Kronrod estimate
+type segment struct {
+	a, b     float64
+	integral float64
+	err      float64
+}
+
+// integrate integrates the body over from..to by adaptive Gauss–Kronrod quadrature:
+// the segment with the largest error estimate is halved until the total estimate is within tolerance
+func (n *IterationNode) integrate(env *Environment, from, to float64) (Value, error) {
+	first, err := n.kronrod(env, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []segment{first}
+	for {
+		integral, estimate := 0.0, 0.0
+		for _, s := range segments {
+			integral += s.integral
+			estimate += s.err
+		}
+		if estimate <= math.Max(integralTolerance, integralTolerance*math.Abs(integral)) {
+			return n.approximate(integral)
+		}
+		if len(segments) >= maxIntegralSegments {
+			return nil, fmt.Errorf("integrate did not converge: the estimated error is %g", estimate)
+		}
+
+		// Halve the worst segment, which is kept last
+		worst := segments[len(segments)-1]
+		middle := worst.a + (worst.b-worst.a)/2
+		left, err := n.kronrod(env, worst.a, middle)
+		if err != nil {
+			return nil, err
+		}
+		right, err := n.kronrod(env, middle, worst.b)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments[:len(segments)-1], left, right)
+		sort.Slice(segments, func(i, j int) bool { return segments[i].err < segments[j].err })
+	}
+}
+
+// approximate converts the value of an integral into the number system, where it has an approximation
+func (n *IterationNode) approximate(x float64) (Value, error) {
+	if n.numbers == nil || n.numbers.approximate == nil {
+		return Number(x), nil
+	}
+	return n.numbers.approximate(x)
+}
+
+// Nodes and weights of the 15-point Kronrod rule on [-1, 1] and of the 7-point Gauss rule it extends,
+// whose nodes are the odd-numbered Kronrod nodes; only the non-negative half is listed
+var (
+	kronrodNodes = [8]float64{
+		0.991455371120812639206854697526329, 0.949107912342758524526189684047851,
+		0.864864423359769072789712788640926, 0.741531185599394439863864773280788,
+		0.586087235467691130294144845693013, 0.405845151377397166906606412076961,
+		0.207784955007898467600689403773245, 0,
+	}
+	kronrodWeights = [8]float64{
+		0.022935322010529224963732008058970, 0.063092092629978553290700663189204,
+		0.104790010322250183839876322541518, 0.140653259715525918745189590510238,
+		0.169004726639267902826583426598550, 0.190350578064785409913256402421014,
+		0.204432940075298892414161999234649, 0.209482141084727828012999174891714,
+	}
+	gaussWeights = [4]float64{
+		0.129484966168869693270611432679082, 0.279705391489276667901467771423780,
+		0.381830050505118944950369775488975, 0.417959183673469387755102040816327,
+	}
+)
+
+// kronrod estimates the integral over [a, b] with the 15-point Kronrod rule,
+// taking the difference from the embedded 7-point Gauss rule as its error
+func (n *IterationNode) kronrod(env *Environment, a, b float64) (segment, error) {
+	center, half := a+(b-a)/2, (b-a)/2
+	kronrod, gauss := 0.0, 0.0
+	for i, node := range kronrodNodes {
+		points := []float64{center - half*node, center + half*node}
+		if node == 0 {
+			points = points[:1]
+		}
+		for _, x := range points {
+			y, err := n.integrand(env, x)
+			if err != nil {
+				return segment{}, err
+			}
+			kronrod += kronrodWeights[i] * y
+			if i%2 == 1 {
+				gauss += gaussWeights[i/2] * y
+			}
+		}
+	}
+	return segment{a: a, b: b, integral: kronrod * half, err: math.Abs((kronrod - gauss) * half)}, nil
+}
+
+// integrand evaluates the body at x as a finite real number
+func (n *IterationNode) integrand(env *Environment, x float64) (float64, error) {
+	if err := n.step(env); err != nil {
+		return 0, err
+	}
+	value, err := n.at(env, x)
+	if err != nil {
+		return 0, err
+	}
+	y, err := asNumber(value, "integrate")
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		return 0, fmt.Errorf("integrate: the integrand is not finite at %s = %v", n.Variable, x)
+	}
+	return y, nil
+}
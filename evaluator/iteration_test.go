@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestIterationBudget checks that every sum, prod and integrate of one evaluation draws on a single budget,
+// whether the forms are nested, side by side or called from lambdas
+func TestIterationBudget(t *testing.T) {
+	tests := []struct {
+		expression string
+		exceeds    bool
+	}{
+		{"sum(1, k, 1, 600000) + sum(1, k, 1, 600000)", true},
+		{"[sum(1, k, 1, 400000), prod(1, k, 1, 400000), sum(1, k, 1, 400000)]", true},
+		{"map([1, 2], n -> sum(1, k, 1, 600000))", true},
+		{"filter([1, 2], n -> sum(1, k, 1, 600000) > 0)", true},
+		{"sum(sum(1, j, 1, 1000), k, 1, 1000)", true},
+		{"sum(1, k, 1, 400000) + sum(1, k, 1, 400000)", false},
+		{"map([1, 2], n -> sum(1, k, 1, 400000))", false},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.expression)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+		}
+		_, vmErr := program.Evaluate(nil)
+		_, treeErr := program.EvaluateTree(nil)
+
+		for _, err := range []error{vmErr, treeErr} {
+			exceeded := err != nil && strings.Contains(err.Error(), "exceeds the limit")
+			if exceeded != tt.exceeds {
+				t.Errorf("Evaluate(%q): error = %v, want exceeding the limit %v", tt.expression, err, tt.exceeds)
+			}
+		}
+	}
+}
+
+// TestIterationBudgetNumericModes checks that binding variables into a numeric mode keeps the budget
+// of the evaluation, so that forms draw on it rather than on a fresh one
+func TestIterationBudgetNumericModes(t *testing.T) {
+	for _, mode := range []NumericMode{ModeDecimal, ModeRational, ModeBigInt} {
+		registry, err := NewNumericRegistry(NumericOptions{Mode: mode})
+		if err != nil {
+			t.Fatalf("NewNumericRegistry(%s) failed: %v", mode, err)
+		}
+		program, err := CompileWithRegistry("sum(n, k, 1, 5)", registry)
+		if err != nil {
+			t.Fatalf("Compile in %s mode failed: %v", mode, err)
+		}
+
+		env := NewEnvironment(map[string]Value{"n": Number(1)}).prepared()
+		*env.steps = 8
+		if _, err := program.Evaluate(env); err != nil {
+			t.Errorf("Evaluate in %s mode failed: %v", mode, err)
+		}
+		if *env.steps != 3 {
+			t.Errorf("Evaluate in %s mode left %d steps, want 3", mode, *env.steps)
+		}
+		if _, err := program.Evaluate(env); err == nil || !strings.Contains(err.Error(), "of which 3 are left") {
+			t.Errorf("Evaluate in %s mode over the budget: error = %v, want exceeding the limit", mode, err)
+		}
+	}
+}
+
+// TestAggregateWithFourArguments checks that sum with four arguments is the list aggregate when its second
+// argument is a bound variable or a constant, and binds a local variable only when it is a new name
+func TestAggregateWithFourArguments(t *testing.T) {
+	env := NewEnvironment(map[string]Value{"a": Number(1), "b": Number(2), "c": Number(3), "d": Number(4)})
+	checkOutcomes(t, nil, env, []outcome{
+		{expression: "sum(a, b, c, d)", want: "10"},
+		{expression: "sum(a, b, c, d) * 2", want: "20"},
+		{expression: "sum(1, pi, 1, 2) - pi", want: "4"},
+		{expression: "sum(k^2, k, c, d)", want: "25"},
+		{expression: "prod(k, k, 1, d)", want: "24"},
+		{expression: "sum(a, k, 1, 3)", want: "3"},
+		{expression: "map([1, 2], n -> sum(n, k, 1, 3))", want: "[3, 6]"},
+		{expression: "integrate(2 * a, a, 0, 1)", want: "1"},
+		{expression: "prod(a, b, c, d)", err: "prod cannot bind b, which is already a variable or a constant"},
+	})
+}
+
+// TestIterationBounds checks that sums and products count exactly up to bounds of 2^53 and reject larger ones
+func TestIterationBounds(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "sum(1, k, 2^53 - 2, 2^53)", want: "3"},
+		{expression: "sum(k - 2^53, k, 2^53 - 3, 2^53)", want: "-6"},
+		{expression: "sum(1, k, -2^53, -2^53 + 4)", want: "5"},
+		{expression: "sum(k, k, 5, 1)", want: "0"},
+		{expression: "sum(1, k, 2^53, 2^53 + 2)", err: "sum expects bounds of at most 2^53 in magnitude"},
+		{expression: "prod(1, k, -2^60, -2^60 + 1)", err: "prod expects bounds of at most 2^53 in magnitude"},
+		{expression: "sum(k, k, 1.5, 3)", err: "sum expects whole-number bounds"},
+	})
+}
@@ -0,0 +1,231 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind classifies a token
+type TokenKind int
+
+const (
+	// TokenEOF marks the end of the input
+	TokenEOF TokenKind = iota
+	// TokenNumber is a numeric literal such as 42, 3.14, 1e-5 or the imaginary 4i
+	TokenNumber
+	// TokenIdentifier is a variable, function or word-operator name
+	TokenIdentifier
+	// TokenOperator is a punctuation operator such as + or **
+	TokenOperator
+	// TokenLeftParen is '('
+	TokenLeftParen
+	// TokenRightParen is ')'
+	TokenRightParen
+	// TokenComma is ','
+	TokenComma
+	// TokenString is a double-quoted string literal such as "abc" or "a\tb", quotes included
+	TokenString
+	// TokenDuration is a duration literal such as 30d, 4h, 15min or 1h30m
+	TokenDuration
+	// TokenLeftBracket is '['
+	TokenLeftBracket
+	// TokenRightBracket is ']'
+	TokenRightBracket
+)
+
+// Token is a single lexical element of an expression
+// Offset is the byte offset of the token's first character in the input
+type Token struct {
+	Kind   TokenKind
+	Text   string
+	Offset int
+}
+
+// lexer splits an expression into tokens
+type lexer struct {
+	input   string
+	symbols []string
+	pos     int
+	tokens  []Token
+}
+
+// tokenize splits the expression into tokens, ending with a TokenEOF
+// Runs of operator characters are split using the longest matching symbol,
+// so with symbols "*" and "**" the input "2**3" yields 2, **, 3
+// Malformed input is reported as *ParseError
+func tokenize(expression string, symbols []string) ([]Token, error) {
+	l := &lexer{
+		input:   expression,
+		symbols: symbols,
+	}
+
+	for l.pos < len(l.input) {
+		c, size := utf8.DecodeRuneInString(l.input[l.pos:])
+
+		switch {
+		case unicode.IsSpace(c):
+			l.pos += size
+		case c == '(':
+			l.emit(TokenLeftParen, l.pos+size)
+		case c == ')':
+			l.emit(TokenRightParen, l.pos+size)
+		case c == '[':
+			l.emit(TokenLeftBracket, l.pos+size)
+		case c == ']':
+			l.emit(TokenRightBracket, l.pos+size)
+		case c == ',':
+			l.emit(TokenComma, l.pos+size)
+		case isDigit(c) || (c == '.' && isDigit(l.runeAt(l.pos+size))):
+			if err := l.lexNumber(); err != nil {
+				return nil, err
+			}
+		case c == '"':
+			if err := l.lexString(); err != nil {
+				return nil, err
+			}
+		case c == '_' || unicode.IsLetter(c):
+			l.emit(TokenIdentifier, l.scanWhile(l.pos, isIdentifierChar))
+		case isOperatorChar(c) || (c == '.' && isOperatorChar(l.runeAt(l.pos+size))):
+			symbol := longestSymbol(l.input[l.pos:], l.symbols)
+			if symbol == "" {
+				return nil, l.errorAt(l.pos, l.pos+size, "unknown operator: %s", string(c))
+			}
+			l.emit(TokenOperator, l.pos+len(symbol))
+		default:
+			return nil, l.errorAt(l.pos, l.pos+size, "unexpected character: %q", c)
+		}
+	}
+
+	l.tokens = append(l.tokens, Token{Kind: TokenEOF, Offset: len(l.input)})
+	return l.tokens, nil
+}
+
+// lexNumber scans a numeric literal: digits ['.' digits] [('e' | 'E') ['+' | '-'] digits] ['i'],
+// or a duration literal: (digits ['.' digits] ('w' | 'd' | 'h' | 'min' | 'm' | 's' | 'ms'))+
+// A number running straight into letters or another '.' (3abc, 1.2.3) is rejected, and so is a
+// number of minutes written as 15m, which could as well mean 15 metres
+func (l *lexer) lexNumber() error {
+	if end := l.pos + scanDuration(l.input[l.pos:]); end > l.pos {
+		if c := l.runeAt(end); c != '.' && !isIdentifierChar(c) {
+			if text := l.input[l.pos:end]; ambiguousMinutes(text) {
+				number := strings.TrimSuffix(text, "m")
+				return l.errorAt(l.pos, end, "ambiguous literal %s: write %smin for minutes or %s m for metres", text, number, number)
+			}
+			l.emit(TokenDuration, end)
+			return nil
+		}
+	}
+
+	end := l.scanWhile(l.pos, isDigit)
+	if l.runeAt(end) == '.' {
+		end = l.scanWhile(end+1, isDigit)
+	}
+
+	if c := l.runeAt(end); c == 'e' || c == 'E' {
+		exponent := end + 1
+		if sign := l.runeAt(exponent); sign == '+' || sign == '-' {
+			exponent++
+		}
+		if isDigit(l.runeAt(exponent)) {
+			end = l.scanWhile(exponent, isDigit)
+		}
+	}
+
+	// A trailing i marks an imaginary literal such as 4i
+	if l.runeAt(end) == 'i' && !isIdentifierChar(l.runeAt(end+1)) {
+		end++
+	}
+
+	if c := l.runeAt(end); c == '.' || isIdentifierChar(c) {
+		bad := l.scanWhile(end, func(c rune) bool { return c == '.' || isIdentifierChar(c) })
+		return l.errorAt(l.pos, bad, "invalid number: %s", l.input[l.pos:bad])
+	}
+
+	l.emit(TokenNumber, end)
+	return nil
+}
+
+// lexString scans a double-quoted string literal, skipping over backslash escapes
+// The escapes themselves are decoded by the parser
+func (l *lexer) lexString() error {
+	for end := l.pos + 1; end < len(l.input); end++ {
+		switch l.input[end] {
+		case '\\':
+			end++
+		case '"':
+			l.emit(TokenString, end+1)
+			return nil
+		}
+	}
+	return l.errorAt(l.pos, len(l.input), "unterminated string")
+}
+
+// emit appends the token spanning from the current position to end and advances past it
+func (l *lexer) emit(kind TokenKind, end int) {
+	l.tokens = append(l.tokens, Token{Kind: kind, Text: l.input[l.pos:end], Offset: l.pos})
+	l.pos = end
+}
+
+// scanWhile returns the offset of the first character at or after start not matching accept
+func (l *lexer) scanWhile(start int, accept func(rune) bool) int {
+	for start < len(l.input) {
+		c, size := utf8.DecodeRuneInString(l.input[start:])
+		if !accept(c) {
+			break
+		}
+		start += size
+	}
+	return start
+}
+
+// runeAt returns the character at byte offset i, or utf8.RuneError past the end
+func (l *lexer) runeAt(i int) rune {
+	if i >= len(l.input) {
+		return utf8.RuneError
+	}
+	c, _ := utf8.DecodeRuneInString(l.input[i:])
+	return c
+}
+
+// errorAt creates a ParseError for the malformed text between start and end
+func (l *lexer) errorAt(start, end int, format string, args ...interface{}) *ParseError {
+	return newParseError(l.input, start, l.input[start:end], nil, fmt.Sprintf(format, args...))
+}
+
+// longestSymbol returns the longest symbol that prefixes input, or "" if none does
+func longestSymbol(input string, symbols []string) string {
+	match := ""
+	for _, symbol := range symbols {
+		if len(symbol) > len(match) && strings.HasPrefix(input, symbol) {
+			match = symbol
+		}
+	}
+	return match
+}
+
+// isDigit reports whether c is an ASCII decimal digit
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentifierChar reports whether c may appear after the first character of an identifier
+func isIdentifierChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// isIdentifier reports whether token is a valid variable name:
+// a letter or underscore followed by letters, digits or underscores
+func isIdentifier(token string) bool {
+	for i, c := range token {
+		if c == '_' || unicode.IsLetter(c) {
+			continue
+		}
+		if i > 0 && unicode.IsDigit(c) {
+			continue
+		}
+		return false
+	}
+	return token != ""
+}
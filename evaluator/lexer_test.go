@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMalformedInput is a regression corpus of inputs the parser must reject,
+// with the position and token each error reports
+func TestMalformedInput(t *testing.T) {
+	tests := []struct {
+		input   string
+		offset  int
+		token   string
+		message string
+	}{
+		// Trailing tokens
+		{"1 2 3", 2, "2", "unexpected '2'"},
+		{"(1+2))", 5, ")", "unexpected ')'"},
+		{"x y", 2, "y", "unexpected 'y'"},
+		{")", 0, ")", "unexpected ')'"},
+
+		// Malformed numbers
+		{"1..2", 0, "1..2", "invalid number: 1..2"},
+		{"1.2.3", 0, "1.2.3", "invalid number: 1.2.3"},
+		{"1e", 0, "1e", "invalid number: 1e"},
+		{"1e+", 0, "1e", "invalid number: 1e"},
+		{"1e5.2", 0, "1e5.2", "invalid number: 1e5.2"},
+		{"3abc", 0, "3abc", "invalid number: 3abc"},
+		{"2 * 3abc", 4, "3abc", "invalid number: 3abc"},
+
+		// Minutes written as m could as well be metres
+		{"15m", 0, "15m", "ambiguous literal 15m: write 15min for minutes or 15 m for metres"},
+		{"now() + 2.5m", 8, "2.5m", "ambiguous literal 2.5m: write 2.5min for minutes or 2.5 m for metres"},
+
+		// in converts units, so it cannot be the inch
+		{"5 in", 4, "", "expected a unit after in but found end of expression; in converts units, write inch for inches"},
+		{"3 m to in", 7, "in", "expected a unit after to but found 'in'; in converts units, write inch for inches"},
+
+		// Unterminated strings
+		{`"abc`, 0, `"abc`, "unterminated string"},
+		{`concat("a", "b)`, 12, `"b)`, "unterminated string"},
+
+		// Unknown characters
+		{"1 @ 2", 2, "@", "unknown operator: @"},
+		{".", 0, ".", "unknown operator: ."},
+
+		// Incomplete expressions
+		{"", 0, "", "unexpected end of expression"},
+		{"  ", 2, "", "unexpected end of expression"},
+		{"2 +", 3, "", "unexpected end of expression"},
+		{"(1+2", 4, "", "expected ')' but found end of expression"},
+		{"sqrt(2,)", 7, ")", "unexpected ')'"},
+		{"[1, 2", 5, "", "expected ',' or ']' in list but found end of expression"},
+		{"a ? b", 5, "", "expected ':' but found end of expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Compile(tt.input)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Compile(%q) error = %v, want a *ParseError", tt.input, err)
+			}
+			if parseErr.Offset != tt.offset || parseErr.Token != tt.token || parseErr.Message != tt.message {
+				t.Errorf("Compile(%q) error at offset %d, token %q: %q; want offset %d, token %q: %q",
+					tt.input, parseErr.Offset, parseErr.Token, parseErr.Message, tt.offset, tt.token, tt.message)
+			}
+		})
+	}
+}
+
+func TestScientificNotation(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Number
+	}{
+		{"1e5", 1e5},
+		{"1e-5", 1e-5},
+		{"1E+5", 1e5},
+		{"2.5e3 - 1e-3", 2499.999},
+		{"1e-5*2", 2e-5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program, err := Compile(tt.input)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.input, err)
+			}
+			got, err := program.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
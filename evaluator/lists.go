@@ -0,0 +1,478 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// List is an ordered list of values, such as [1, 2, 3]
+type List []Value
+
+// Lambda is an anonymous function value such as p -> p * 1.2
+// It closes over the environment it was created in
+type Lambda struct {
+	params []string
+	body   ExprNode
+	env    *Environment
+}
+
+// Kind implements the Value interface for List
+func (l List) Kind() Kind {
+	return KindList
+}
+
+// String implements the Value interface for List, e.g. [1, 2, 3]
+func (l List) String() string {
+	elements := make([]string, len(l))
+	for i, element := range l {
+		if s, ok := element.(String); ok {
+			elements[i] = fmt.Sprintf("%q", string(s))
+		} else {
+			elements[i] = element.String()
+		}
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// MarshalJSON encodes the list as a JSON array of its elements
+func (l List) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Value(l))
+}
+
+// Kind implements the Value interface for Lambda
+func (l Lambda) Kind() Kind {
+	return KindFunction
+}
+
+// String implements the Value interface for Lambda, naming its parameters, e.g. function(a, b)
+func (l Lambda) String() string {
+	return "function(" + strings.Join(l.params, ", ") + ")"
+}
+
+// MarshalJSON encodes the lambda as its description, since functions have no JSON form
+func (l Lambda) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// call applies the lambda to args, binding them to its parameters on top of the captured environment
+func (l Lambda) call(args ...Value) (Value, error) {
+	if len(args) != len(l.params) {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", l, len(l.params), len(args))
+	}
+
+	bindings := make(map[string]Value, len(args))
+	for i, param := range l.params {
+		bindings[param] = args[i]
+	}
+	return l.body.Evaluate(l.env.extend(bindings))
+}
+
+// asList returns v as a List or a TypeError naming what needed a list
+func asList(v Value, context string) (List, error) {
+	if l, ok := v.(List); ok {
+		return l, nil
+	}
+	return nil, newTypeError("%s expects a list, got %s", context, v.Kind())
+}
+
+// isList reports whether v is a list
+func isList(v Value) bool {
+	_, ok := v.(List)
+	return ok
+}
+
+// asLambda returns v as a Lambda or a TypeError naming what needed a function
+func asLambda(v Value, context string) (Lambda, error) {
+	if l, ok := v.(Lambda); ok {
+		return l, nil
+	}
+	return Lambda{}, newTypeError("%s expects a function such as x -> x * 2, got %s", context, v.Kind())
+}
+
+// index returns target[i]; negative indexes count from the end, so xs[-1] is the last element
+func index(target, i Value) (Value, error) {
+	list, err := asList(target, "indexing")
+	if err != nil {
+		return nil, err
+	}
+	n, err := asNumber(i, "index")
+	if err != nil {
+		return nil, err
+	}
+	if n != math.Trunc(n) {
+		return nil, fmt.Errorf("index must be an integer, got %s", i)
+	}
+
+	position := int(n)
+	if n < 0 {
+		position = len(list) + int(n)
+	}
+	if n >= float64(len(list)) || position < 0 {
+		return nil, fmt.Errorf("index %s out of range for list of length %d", i, len(list))
+	}
+	return list[position], nil
+}
+
+// listsEqual reports whether two lists have equal elements in the same order
+func listsEqual(symbol string, left, right List) (bool, error) {
+	if len(left) != len(right) {
+		return false, nil
+	}
+	for i := range left {
+		equal, err := equals(symbol, left[i], right[i])
+		if err != nil || !equal {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// listBuiltins holds the list functions available to every expression
+// The higher-order functions take a lambda as their last argument, e.g. map(xs, x -> x * 2)
+var listBuiltins = map[string]builtinFunction{
+	"map": listFunction(2, 2, func(list List, args []Value) (Value, error) {
+		fn, err := asLambda(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		result := make(List, len(list))
+		for i, element := range list {
+			if result[i], err = fn.call(element); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}),
+	"filter": listFunction(2, 2, func(list List, args []Value) (Value, error) {
+		test, err := predicate(args[1])
+		if err != nil {
+			return nil, err
+		}
+		result := make(List, 0, len(list))
+		for _, element := range list {
+			keep, err := test(element)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				result = append(result, element)
+			}
+		}
+		return result, nil
+	}),
+	"reduce": listFunction(2, 3, func(list List, args []Value) (Value, error) {
+		fn, err := asLambda(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 2 {
+			if len(list) == 0 {
+				return nil, fmt.Errorf("cannot reduce an empty list without an initial value")
+			}
+			args = append(args, list[0])
+			list = list[1:]
+		}
+
+		accumulator := args[2]
+		for _, element := range list {
+			if accumulator, err = fn.call(accumulator, element); err != nil {
+				return nil, err
+			}
+		}
+		return accumulator, nil
+	}),
+	"sort": listFunction(1, 2, sortList),
+	"any": listFunction(2, 2, func(list List, args []Value) (Value, error) {
+		test, err := predicate(args[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range list {
+			ok, err := test(element)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return Bool(true), nil
+			}
+		}
+		return Bool(false), nil
+	}),
+	"all": listFunction(2, 2, func(list List, args []Value) (Value, error) {
+		test, err := predicate(args[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range list {
+			ok, err := test(element)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return Bool(false), nil
+			}
+		}
+		return Bool(true), nil
+	}),
+	"count": listFunction(1, 2, func(list List, args []Value) (Value, error) {
+		if len(args) == 1 {
+			return Number(len(list)), nil
+		}
+		test, err := predicate(args[1])
+		if err != nil {
+			return nil, err
+		}
+		count := 0
+		for _, element := range list {
+			ok, err := test(element)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				count++
+			}
+		}
+		return Number(count), nil
+	}),
+	"sum": aggregate(0, func(xs []float64) (float64, error) {
+		total := 0.0
+		for _, x := range xs {
+			total += x
+		}
+		return total, nil
+	}),
+	"avg": aggregate(1, func(xs []float64) (float64, error) {
+		return mean(xs), nil
+	}),
+	"median": aggregate(1, func(xs []float64) (float64, error) {
+		return quantile(xs, 0.5), nil
+	}),
+	"stddev": aggregate(2, func(xs []float64) (float64, error) {
+		m, squares := mean(xs), 0.0
+		for _, x := range xs {
+			squares += (x - m) * (x - m)
+		}
+		return math.Sqrt(squares / float64(len(xs)-1)), nil
+	}),
+	"percentile": listFunction(2, 2, func(list List, args []Value) (Value, error) {
+		xs, err := listNumbers(list, 1)
+		if err != nil {
+			return nil, err
+		}
+		p, err := asNumber(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		if !(p >= 0 && p <= 100) {
+			return nil, fmt.Errorf("percentile must be between 0 and 100")
+		}
+		return Number(quantile(xs, p/100)), nil
+	}),
+}
+
+// listFunction creates a function whose first argument must be a list
+func listFunction(minArgs, maxArgs int, fn func(list List, args []Value) (Value, error)) builtinFunction {
+	return builtinFunction{
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		valueFn: func(args []Value) (Value, error) {
+			list, err := asList(args[0], "argument 1")
+			if err != nil {
+				return nil, err
+			}
+			return fn(list, args)
+		},
+	}
+}
+
+// aggregate creates a statistical function over a list of numbers, or over its arguments
+// when called with several numbers, as in sum(1, 2, 3); minimum is the fewest values it accepts
+// Quantities of the same dimensions are aggregated in the unit of the first, as in sum([1 m, 20 cm])
+func aggregate(minimum int, fn func(xs []float64) (float64, error)) builtinFunction {
+	return builtinFunction{
+		minArgs: 1,
+		maxArgs: Variadic,
+		valueFn: func(args []Value) (Value, error) {
+			values := List(args)
+			if len(args) == 1 {
+				list, err := asList(args[0], "argument 1")
+				if err != nil {
+					return nil, err
+				}
+				values = list
+			}
+
+			if len(values) < minimum {
+				return nil, fmt.Errorf("expected at least %d value(s), got %d", minimum, len(values))
+			}
+			magnitudes, unit, ok, err := commonUnit(values, "element")
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if unit.relative {
+					return nil, fmt.Errorf("temperatures in %s cannot be aggregated; convert them to K first", unit)
+				}
+				result, err := fn(magnitudes)
+				if err != nil {
+					return nil, err
+				}
+				return inUnit(result, unit, values)
+			}
+
+			xs, err := listNumbers(values, minimum)
+			if err != nil {
+				return nil, err
+			}
+			result, err := fn(xs)
+			if err != nil {
+				return nil, err
+			}
+			return Number(result), nil
+		},
+	}
+}
+
+// exactAggregate wraps the built-in sum or avg so that it folds with the arithmetic of a numeric mode
+// and keeps the mode's type, so sum([0.1, 0.2]) is exactly 0.3 in decimal mode
+// Values the mode cannot fold, such as 1.5 among integers in bigint mode, are aggregated in floating point
+func exactAggregate(inner *builtinFunction, numbers *numberSystem, add, divide *Operator, average bool) builtinFunction {
+	fallback := *inner
+	return builtinFunction{
+		minArgs: inner.minArgs,
+		maxArgs: inner.maxArgs,
+		valueFn: func(args []Value) (Value, error) {
+			values := List(args)
+			if list, ok := args[0].(List); ok && len(args) == 1 {
+				values = list
+			}
+			if average && len(values) == 0 {
+				return fallback.invoke(args)
+			}
+
+			total, err := numbers.literal("0")
+			if err != nil {
+				return fallback.invoke(args)
+			}
+			for _, value := range values {
+				if total, err = add.apply(total, value); err != nil {
+					return fallback.invoke(args)
+				}
+			}
+			if !average {
+				return total, nil
+			}
+
+			count, err := numbers.literal(strconv.Itoa(len(values)))
+			if err != nil {
+				return fallback.invoke(args)
+			}
+			result, err := divide.apply(total, count)
+			if err != nil {
+				return fallback.invoke(args)
+			}
+			return result, nil
+		},
+	}
+}
+
+// listNumbers converts the elements of a list to numbers, requiring at least minimum of them
+func listNumbers(list List, minimum int) ([]float64, error) {
+	if len(list) < minimum {
+		return nil, fmt.Errorf("expected at least %d value(s), got %d", minimum, len(list))
+	}
+
+	xs := make([]float64, len(list))
+	for i, element := range list {
+		x, err := asNumber(element, fmt.Sprintf("element %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		xs[i] = x
+	}
+	return xs, nil
+}
+
+// predicate adapts a lambda returning a boolean, such as s -> s > 50
+func predicate(v Value) (func(element Value) (bool, error), error) {
+	fn, err := asLambda(v, "argument 2")
+	if err != nil {
+		return nil, err
+	}
+	return func(element Value) (bool, error) {
+		result, err := fn.call(element)
+		if err != nil {
+			return false, err
+		}
+		return asBool(result, "predicate result")
+	}, nil
+}
+
+// sortList sorts a list in ascending order, comparing the elements themselves
+// or the keys returned by an optional lambda, as in sort(people, p -> p[1])
+// The sort is stable; elements that cannot be compared are a type error
+func sortList(list List, args []Value) (Value, error) {
+	keys := list
+	if len(args) == 2 {
+		fn, err := asLambda(args[1], "argument 2")
+		if err != nil {
+			return nil, err
+		}
+		keys = make(List, len(list))
+		for i, element := range list {
+			if keys[i], err = fn.call(element); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+
+	var sortErr error
+	sort.SliceStable(order, func(i, j int) bool {
+		c, err := compare("<", keys[order[i]], keys[order[j]])
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	result := make(List, len(list))
+	for i, j := range order {
+		result[i] = list[j]
+	}
+	return result, nil
+}
+
+// mean returns the arithmetic mean of xs
+func mean(xs []float64) float64 {
+	total := 0.0
+	for _, x := range xs {
+		total += x
+	}
+	return total / float64(len(xs))
+}
+
+// quantile returns the q-th quantile of xs, interpolating linearly between the closest ranks
+func quantile(xs []float64, q float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+
+	rank := q * float64(len(sorted)-1)
+	lower := math.Floor(rank)
+	upper := math.Ceil(rank)
+	return sorted[int(lower)] + (rank-lower)*(sorted[int(upper)]-sorted[int(lower)])
+}
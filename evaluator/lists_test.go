@@ -0,0 +1,69 @@
+package evaluator
+
+import "testing"
+
+// TestAggregatesInNumericModes checks that sum, avg, min and max keep the type of the numeric mode
+func TestAggregatesInNumericModes(t *testing.T) {
+	tests := []struct {
+		mode       NumericMode
+		expression string
+		want       string
+		kind       Kind
+	}{
+		{ModeDecimal, "sum([0.1, 0.2])", "0.3", KindDecimal},
+		{ModeDecimal, "sum(0.1, 0.2, 0.3)", "0.6", KindDecimal},
+		{ModeDecimal, "sum([])", "0", KindDecimal},
+		{ModeDecimal, "avg([0.1, 0.2])", "0.15", KindDecimal},
+		{ModeDecimal, "min(0.3, 0.1, 0.2)", "0.1", KindDecimal},
+		{ModeDecimal, "max(0.3, 0.1, 0.2)", "0.3", KindDecimal},
+		{ModeDecimal, "median([1, 2])", "1.5", KindNumber},
+		{ModeRational, "sum([1/3, 1/6])", "1/2", KindRational},
+		{ModeRational, "avg([1, 2])", "3/2", KindRational},
+		{ModeRational, "max(1/3, 1/4)", "1/3", KindRational},
+		{ModeBigInt, "sum([2^70, 1])", "1180591620717411303425", KindInteger},
+		{ModeBigInt, "avg([2, 4])", "3", KindInteger},
+		{ModeBigInt, "avg([1, 2])", "1.5", KindNumber},
+		{ModeBigInt, "min(2^70, 3)", "3", KindInteger},
+		{ModeComplex, "sum([1+2i, 3])", "4+2i", KindComplex},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+tt.expression, func(t *testing.T) {
+			registry, err := NewNumericRegistry(NumericOptions{Mode: tt.mode})
+			if err != nil {
+				t.Fatalf("NewNumericRegistry failed: %v", err)
+			}
+			program, err := CompileWithRegistry(tt.expression, registry)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			got, err := program.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got.String() != tt.want || got.Kind() != tt.kind {
+				t.Errorf("Evaluate(%q) = %s (%s), want %s (%s)", tt.expression, got, got.Kind(), tt.want, tt.kind)
+			}
+		})
+	}
+}
+
+func TestAggregateErrorsInNumericModes(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeDecimal})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+
+	for expression, want := range map[string]string{
+		`sum([1, "a"])`: "sum: type error: element 2 expects a number, got string",
+		"avg([])":       "avg: expected at least 1 value(s), got 0",
+	} {
+		program, err := CompileWithRegistry(expression, registry)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expression, err)
+		}
+		if _, err := program.Evaluate(nil); err == nil || err.Error() != want {
+			t.Errorf("Evaluate(%q) error = %v, want %q", expression, err, want)
+		}
+	}
+}
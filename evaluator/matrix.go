@@ -0,0 +1,564 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// machineEpsilon is the gap between 1 and the next larger float64
+const machineEpsilon = 2.220446049250313e-16
+
+// maxIdentitySize is the largest n accepted by identity(n)
+const maxIdentitySize = 1000
+
+// maxMatrixWork is the most multiply-adds one matrix operation may take, about a second of work,
+// so that products, powers, inverses and determinants of large matrices are rejected instead of
+// keeping the service busy; it allows an n x n product or inverse up to n = 464
+const maxMatrixWork = 100_000_000
+
+// matrix is a dense matrix of numbers stored row by row
+// Lists of numbers are vectors, stored as a single column and converted back to flat lists;
+// lists of equally long lists of numbers are matrices
+type matrix struct {
+	rows, cols int
+	data       []float64
+	vector     bool
+}
+
+// newMatrix creates a rows x cols matrix of zeros
+func newMatrix(rows, cols int) *matrix {
+	return &matrix{rows: rows, cols: cols, data: make([]float64, rows*cols)}
+}
+
+// identityMatrix creates the n x n identity matrix
+func identityMatrix(n int) *matrix {
+	m := newMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.set(i, i, 1)
+	}
+	return m
+}
+
+// at returns the element in row i and column j
+func (m *matrix) at(i, j int) float64 {
+	return m.data[i*m.cols+j]
+}
+
+// set sets the element in row i and column j
+func (m *matrix) set(i, j int, x float64) {
+	m.data[i*m.cols+j] = x
+}
+
+// shape describes the matrix for error messages, e.g. "2x3 matrix" or "3-vector"
+func (m *matrix) shape() string {
+	if m.vector {
+		return fmt.Sprintf("%d-vector", m.rows)
+	}
+	return fmt.Sprintf("%dx%d matrix", m.rows, m.cols)
+}
+
+// sameShape reports whether m and other are both vectors or both matrices of the same dimensions
+func (m *matrix) sameShape(other *matrix) bool {
+	return m.rows == other.rows && m.cols == other.cols && m.vector == other.vector
+}
+
+// value converts the matrix back into a list of rows, or a flat list for a vector
+func (m *matrix) value() Value {
+	if m.vector {
+		list := make(List, len(m.data))
+		for i, x := range m.data {
+			list[i] = Number(x)
+		}
+		return list
+	}
+
+	rows := make(List, m.rows)
+	for i := range rows {
+		row := make(List, m.cols)
+		for j := range row {
+			row[j] = Number(m.at(i, j))
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// transpose returns the transpose of m; a vector becomes a matrix with a single row
+func (m *matrix) transpose() *matrix {
+	t := newMatrix(m.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			t.set(j, i, m.at(i, j))
+		}
+	}
+	return t
+}
+
+// multiply returns the matrix product m * other, or an error naming both shapes when they do not fit
+// A vector on the left is treated as a row and a vector on the right as a column,
+// and the product of a vector and a matrix is a vector
+func (m *matrix) multiply(other *matrix) (*matrix, error) {
+	if m.vector && other.vector {
+		return nil, fmt.Errorf("operator * cannot multiply two vectors; use dot(u, v) for the dot product or .* for the element-wise product")
+	}
+
+	left := m
+	if m.vector {
+		left = m.transpose()
+	}
+	if left.cols != other.rows {
+		return nil, fmt.Errorf("operator * cannot multiply a %s by a %s: inner dimensions %d and %d differ",
+			m.shape(), other.shape(), left.cols, other.rows)
+	}
+
+	work := float64(left.rows) * float64(left.cols) * float64(other.cols)
+	if err := checkWork(fmt.Sprintf("operator *: multiplying a %s by a %s", m.shape(), other.shape()), work); err != nil {
+		return nil, err
+	}
+
+	product := newMatrix(left.rows, other.cols)
+	for i := 0; i < left.rows; i++ {
+		for k := 0; k < left.cols; k++ {
+			a := left.at(i, k)
+			for j := 0; j < other.cols; j++ {
+				product.data[i*product.cols+j] += a * other.at(k, j)
+			}
+		}
+	}
+	product.vector = m.vector || other.vector
+	if product.vector {
+		product.rows, product.cols = len(product.data), 1
+	}
+	return product, nil
+}
+
+// square returns an error naming what needed a square matrix unless m is one small enough to factor,
+// which takes about n^3 multiply-adds
+func (m *matrix) square(context string) error {
+	if m.vector || m.rows != m.cols {
+		return fmt.Errorf("%s expects a square matrix, got a %s", context, m.shape())
+	}
+	n := float64(m.rows)
+	return checkWork(fmt.Sprintf("%s: factoring a %s", context, m.shape()), n*n*n)
+}
+
+// checkWork returns an error describing the operation when it would take more than maxMatrixWork multiply-adds
+func checkWork(operation string, work float64) error {
+	if work > maxMatrixWork {
+		return fmt.Errorf("%s would take more than %d multiply-adds", operation, maxMatrixWork)
+	}
+	return nil
+}
+
+// lu factors a square matrix with partial pivoting into unit lower and upper triangular factors,
+// stored together, and returns the row permutation and its sign
+// singular reports a pivot too small, relative to the largest element, to divide by
+func (m *matrix) lu() (lu *matrix, perm []int, sign float64, singular bool) {
+	n := m.rows
+	lu = &matrix{rows: n, cols: n, data: append([]float64(nil), m.data...)}
+	perm = make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	sign = 1
+
+	largest := 0.0
+	for _, x := range lu.data {
+		largest = math.Max(largest, math.Abs(x))
+	}
+	tolerance := float64(n) * machineEpsilon * largest
+
+	for k := 0; k < n; k++ {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(lu.at(i, k)) > math.Abs(lu.at(p, k)) {
+				p = i
+			}
+		}
+		if math.Abs(lu.at(p, k)) <= tolerance {
+			return lu, perm, sign, true
+		}
+
+		if p != k {
+			for j := 0; j < n; j++ {
+				a, b := lu.at(k, j), lu.at(p, j)
+				lu.set(k, j, b)
+				lu.set(p, j, a)
+			}
+			perm[k], perm[p] = perm[p], perm[k]
+			sign = -sign
+		}
+
+		for i := k + 1; i < n; i++ {
+			f := lu.at(i, k) / lu.at(k, k)
+			lu.set(i, k, f)
+			for j := k + 1; j < n; j++ {
+				lu.set(i, j, lu.at(i, j)-f*lu.at(k, j))
+			}
+		}
+	}
+	return lu, perm, sign, false
+}
+
+// determinant returns the determinant of a square matrix, which is 0 for singular matrices
+func (m *matrix) determinant() float64 {
+	lu, _, det, singular := m.lu()
+	if singular {
+		return 0
+	}
+	for i := 0; i < m.rows; i++ {
+		det *= lu.at(i, i)
+	}
+	return det
+}
+
+// solve returns x such that m * x = b for a square matrix m and a vector or matrix b
+func (m *matrix) solve(b *matrix) (*matrix, error) {
+	if b.rows != m.rows {
+		return nil, fmt.Errorf("cannot solve a system with a %s and a %s: expected %d rows on the right-hand side",
+			m.shape(), b.shape(), m.rows)
+	}
+
+	lu, perm, _, singular := m.lu()
+	if singular {
+		return nil, fmt.Errorf("matrix is singular, so the system has no unique solution")
+	}
+
+	n := m.rows
+	x := newMatrix(n, b.cols)
+	x.vector = b.vector
+	for c := 0; c < b.cols; c++ {
+		// Forward substitution through the unit lower factor, then back substitution through the upper
+		for i := 0; i < n; i++ {
+			sum := b.at(perm[i], c)
+			for j := 0; j < i; j++ {
+				sum -= lu.at(i, j) * x.at(j, c)
+			}
+			x.set(i, c, sum)
+		}
+		for i := n - 1; i >= 0; i-- {
+			sum := x.at(i, c)
+			for j := i + 1; j < n; j++ {
+				sum -= lu.at(i, j) * x.at(j, c)
+			}
+			x.set(i, c, sum/lu.at(i, i))
+		}
+	}
+	return x, nil
+}
+
+// asMatrix converts a list of numbers into a vector, or a list of equally long lists of numbers
+// into a matrix, returning an error naming what needed a matrix when v is neither
+func asMatrix(v Value, context string) (*matrix, error) {
+	list, err := asList(v, context)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s expects a non-empty vector or matrix", context)
+	}
+
+	first, ok := list[0].(List)
+	if !ok {
+		m := newMatrix(len(list), 1)
+		m.vector = true
+		for i, element := range list {
+			if m.data[i], err = asNumber(element, fmt.Sprintf("%s element %d", context, i+1)); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	if len(first) == 0 {
+		return nil, fmt.Errorf("%s expects a non-empty vector or matrix", context)
+	}
+	m := newMatrix(len(list), len(first))
+	for i, element := range list {
+		row, ok := element.(List)
+		if !ok {
+			return nil, newTypeError("%s row %d expects a list, got %s", context, i+1, element.Kind())
+		}
+		if len(row) != m.cols {
+			return nil, fmt.Errorf("%s expects rows of the same length: row %d has %d element(s), expected %d", context, i+1, len(row), m.cols)
+		}
+		for j, x := range row {
+			if m.data[i*m.cols+j], err = asNumber(x, fmt.Sprintf("%s row %d element %d", context, i+1, j+1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// matrixOperators implement the arithmetic operators when either operand is a list
+// + and - work element by element on operands of the same shape, * is the matrix product
+// and ^ raises a square matrix to an integer power; a number on either side of + - * /
+// applies to every element
+var matrixOperators = map[string]func(left, right Value) (Value, error){
+	"+": elementwise("+", func(left, right float64) (float64, error) {
+		return left + right, nil
+	}),
+	"-": elementwise("-", func(left, right float64) (float64, error) {
+		return left - right, nil
+	}),
+	"*": matrixProduct,
+	"/": func(left, right Value) (Value, error) {
+		if isList(right) {
+			return nil, fmt.Errorf("operator / cannot divide by a matrix; use inv(A) or solve(A, b)")
+		}
+		return elementwise("/", divide)(left, right)
+	},
+	"^":  matrixPower("^"),
+	"**": matrixPower("**"),
+}
+
+// withMatrices extends a numeric mode's arithmetic operator to accept vectors and matrices
+func withMatrices(op *Operator) *Operator {
+	matrixOp, ok := matrixOperators[op.Symbol]
+	if !ok {
+		return op
+	}
+
+	extended := *op
+	extended.ValueFn = func(left, right Value) (Value, error) {
+		if isList(left) || isList(right) {
+			return matrixOp(left, right)
+		}
+		return op.apply(left, right)
+	}
+	extended.Fn = nil
+	return &extended
+}
+
+// elementwise creates an operator applying fn to corresponding elements of two vectors or matrices
+// of the same shape, or to every element and a number; two numbers are combined directly
+func elementwise(symbol string, fn OperatorFunc) func(left, right Value) (Value, error) {
+	context := "operator " + symbol
+	return func(left, right Value) (Value, error) {
+		if !isList(left) && !isList(right) {
+			l, err := asNumber(left, context)
+			if err != nil {
+				return nil, err
+			}
+			r, err := asNumber(right, context)
+			if err != nil {
+				return nil, err
+			}
+			result, err := fn(l, r)
+			if err != nil {
+				return nil, err
+			}
+			return Number(result), nil
+		}
+
+		var a, b *matrix
+		var l, r float64
+		var err error
+		switch {
+		case isList(left) && isList(right):
+			if a, err = asMatrix(left, context); err != nil {
+				return nil, err
+			}
+			if b, err = asMatrix(right, context); err != nil {
+				return nil, err
+			}
+			if !a.sameShape(b) {
+				return nil, fmt.Errorf("operator %s requires operands of the same shape, got a %s and a %s",
+					symbol, a.shape(), b.shape())
+			}
+		case isList(left):
+			if a, err = asMatrix(left, context); err != nil {
+				return nil, err
+			}
+			if r, err = asNumber(right, context); err != nil {
+				return nil, err
+			}
+		default:
+			if l, err = asNumber(left, context); err != nil {
+				return nil, err
+			}
+			if b, err = asMatrix(right, context); err != nil {
+				return nil, err
+			}
+		}
+
+		shape := a
+		if shape == nil {
+			shape = b
+		}
+		result := &matrix{rows: shape.rows, cols: shape.cols, data: make([]float64, len(shape.data)), vector: shape.vector}
+		for i := range result.data {
+			if a != nil {
+				l = a.data[i]
+			}
+			if b != nil {
+				r = b.data[i]
+			}
+			if result.data[i], err = fn(l, r); err != nil {
+				return nil, err
+			}
+		}
+		return result.value(), nil
+	}
+}
+
+// matrixProduct implements * for vectors and matrices, scaling them when the other operand is a number
+func matrixProduct(left, right Value) (Value, error) {
+	if !isList(left) || !isList(right) {
+		return elementwise("*", func(l, r float64) (float64, error) {
+			return l * r, nil
+		})(left, right)
+	}
+
+	a, err := asMatrix(left, "operator *")
+	if err != nil {
+		return nil, err
+	}
+	b, err := asMatrix(right, "operator *")
+	if err != nil {
+		return nil, err
+	}
+	product, err := a.multiply(b)
+	if err != nil {
+		return nil, err
+	}
+	return product.value(), nil
+}
+
+// matrixPower creates an operator raising a square matrix to an integer power;
+// negative powers raise the inverse
+func matrixPower(symbol string) func(left, right Value) (Value, error) {
+	context := "operator " + symbol
+	return func(left, right Value) (Value, error) {
+		if isList(right) || !isList(left) {
+			return nil, fmt.Errorf("operator %s raises a square matrix to a number; use .^ for element-wise powers", symbol)
+		}
+
+		m, err := asMatrix(left, context)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.square(context); err != nil {
+			return nil, err
+		}
+		n, err := asNumber(right, context)
+		if err != nil {
+			return nil, err
+		}
+		if n != math.Trunc(n) || math.Abs(n) > 1<<31 {
+			return nil, fmt.Errorf("operator %s expects an integer exponent for a matrix, got %s", symbol, right)
+		}
+		// Squaring and multiplying takes up to two products per bit of the exponent
+		size := float64(m.rows)
+		work := 2 * float64(bits.Len64(uint64(math.Abs(n)))) * size * size * size
+		if err := checkWork(fmt.Sprintf("%s: raising a %s to the power %s", context, m.shape(), right), work); err != nil {
+			return nil, err
+		}
+
+		if n < 0 {
+			inverse, err := m.solve(identityMatrix(m.rows))
+			if err != nil {
+				return nil, fmt.Errorf("operator %s: matrix is singular and has no inverse", symbol)
+			}
+			m, n = inverse, -n
+		}
+
+		// Square and multiply
+		result := identityMatrix(m.rows)
+		for k := int64(n); k > 0; k >>= 1 {
+			if k&1 == 1 {
+				result, _ = result.multiply(m)
+			}
+			m, _ = m.multiply(m)
+		}
+		return result.value(), nil
+	}
+}
+
+// negateMatrix implements unary minus for vectors and matrices
+func negateMatrix(v Value) (Value, error) {
+	m, err := asMatrix(v, "operator -")
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.data {
+		m.data[i] = -m.data[i]
+	}
+	return m.value(), nil
+}
+
+// matrixBuiltins holds the linear algebra functions available to every expression
+// They are computed in floating point
+var matrixBuiltins = map[string]builtinFunction{
+	"transpose": matrixFunction(1, func(args []*matrix) (Value, error) {
+		return args[0].transpose().value(), nil
+	}),
+	"det": matrixFunction(1, func(args []*matrix) (Value, error) {
+		if err := args[0].square("argument 1"); err != nil {
+			return nil, err
+		}
+		return Number(args[0].determinant()), nil
+	}),
+	"inv": matrixFunction(1, func(args []*matrix) (Value, error) {
+		if err := args[0].square("argument 1"); err != nil {
+			return nil, err
+		}
+		inverse, err := args[0].solve(identityMatrix(args[0].rows))
+		if err != nil {
+			return nil, fmt.Errorf("matrix is singular and has no inverse")
+		}
+		return inverse.value(), nil
+	}),
+	"dot": matrixFunction(2, func(args []*matrix) (Value, error) {
+		u, v := args[0], args[1]
+		if !u.vector || !v.vector || u.rows != v.rows {
+			return nil, fmt.Errorf("expected two vectors of the same length, got a %s and a %s", u.shape(), v.shape())
+		}
+		total := 0.0
+		for i := range u.data {
+			total += u.data[i] * v.data[i]
+		}
+		return Number(total), nil
+	}),
+	"solve": matrixFunction(2, func(args []*matrix) (Value, error) {
+		if err := args[0].square("argument 1"); err != nil {
+			return nil, err
+		}
+		x, err := args[0].solve(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return x.value(), nil
+	}),
+	"identity": {minArgs: 1, maxArgs: 1, valueFn: func(args []Value) (Value, error) {
+		n, err := asNumber(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		if n != math.Trunc(n) || n < 1 || n > maxIdentitySize {
+			return nil, fmt.Errorf("size must be an integer between 1 and %d", maxIdentitySize)
+		}
+		return identityMatrix(int(n)).value(), nil
+	}},
+}
+
+// matrixFunction creates a function of n arguments that must all be vectors or matrices
+func matrixFunction(n int, fn func(args []*matrix) (Value, error)) builtinFunction {
+	return builtinFunction{
+		minArgs: n,
+		maxArgs: n,
+		valueFn: func(args []Value) (Value, error) {
+			matrices := make([]*matrix, len(args))
+			for i, arg := range args {
+				m, err := asMatrix(arg, fmt.Sprintf("argument %d", i+1))
+				if err != nil {
+					return nil, err
+				}
+				matrices[i] = m
+			}
+			return fn(matrices)
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package evaluator
+
+import "testing"
+
+func TestMatrixOperations(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "[[1, 2], [3, 4]] * [5, 6]", want: "[17, 39]"},
+		{expression: "[1, 2] * [[1, 2], [3, 4]]", want: "[7, 10]"},
+		{expression: "[[1, 2, 3], [4, 5, 6]] * [[1, 0], [0, 1], [1, 1]]", want: "[[4, 5], [10, 11]]"},
+		{expression: "[[1, 2], [3, 4]] .* [[1, 2], [3, 4]]", want: "[[1, 4], [9, 16]]"},
+		{expression: "[[1, 2], [3, 4]] + 1", want: "[[2, 3], [4, 5]]"},
+		{expression: "2 * [1, 2]", want: "[2, 4]"},
+		{expression: "[[1, 2], [3, 4]] ^ 2", want: "[[7, 10], [15, 22]]"},
+		{expression: "[[2, 0], [0, 4]] ^ -1", want: "[[0.5, 0], [0, 0.25]]"},
+		{expression: "[[1, 2], [3, 4]] ^ 0", want: "[[1, 0], [0, 1]]"},
+		{expression: "-[[1, -2]]", want: "[[-1, 2]]"},
+		{expression: "dot([1, 2, 3], [4, 5, 6])", want: "32"},
+		{expression: "transpose([[1, 2, 3], [4, 5, 6]])", want: "[[1, 4], [2, 5], [3, 6]]"},
+		{expression: "transpose([1, 2])", want: "[[1, 2]]"},
+		{expression: "transpose(transpose([[1, 2], [3, 4]]))", want: "[[1, 2], [3, 4]]"},
+		{expression: "identity(1)", want: "[[1]]"},
+		{expression: "identity(3)", want: "[[1, 0, 0], [0, 1, 0], [0, 0, 1]]"},
+		{expression: "[[1, 2], [3, 4]] * identity(2)", want: "[[1, 2], [3, 4]]"},
+		{expression: "det([[1, 2], [3, 4]])", want: "-2"},
+		{expression: "det([[0, 1], [1, 0]])", want: "-1"},
+		{expression: "det(identity(4))", want: "1"},
+		{expression: "inv([[2, 0], [0, 4]])", want: "[[0.5, 0], [0, 0.25]]"},
+		{expression: "solve([[2, 1], [1, 3]], [3, 5])", want: "[0.8, 1.4]"},
+		{expression: "solve([[2, 0], [0, 4]], [[2, 4], [4, 8]])", want: "[[1, 2], [1, 2]]"},
+	})
+}
+
+func TestMatrixShapeErrors(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "[[1, 2, 3], [4, 5, 6]] * [[1, 2], [3, 4]]", err: "operator * cannot multiply a 2x3 matrix by a 2x2 matrix: inner dimensions 3 and 2 differ"},
+		{expression: "[[1, 2], [3, 4]] * [1, 2, 3]", err: "operator * cannot multiply a 2x2 matrix by a 3-vector"},
+		{expression: "[1, 2] * [3, 4]", err: "operator * cannot multiply two vectors; use dot(u, v)"},
+		{expression: "[1, 2] + [1, 2, 3]", err: "operator + requires operands of the same shape, got a 2-vector and a 3-vector"},
+		{expression: "[[1, 2]] - [1, 2]", err: "operator - requires operands of the same shape, got a 1x2 matrix and a 2-vector"},
+		{expression: "[[1, 2], [3, 4]] / [[1, 2], [3, 4]]", err: "operator / cannot divide by a matrix; use inv(A) or solve(A, b)"},
+		{expression: "[[1, 2], [3, 4]] ^ 0.5", err: "operator ^ expects an integer exponent for a matrix, got 0.5"},
+		{expression: "[[1, 2, 3], [4, 5, 6]] ^ 2", err: "operator ^ expects a square matrix, got a 2x3 matrix"},
+		{expression: "det([[1, 2, 3], [4, 5, 6]])", err: "det: argument 1 expects a square matrix, got a 2x3 matrix"},
+		{expression: "det([[1, 2], [3]])", err: "argument 1 expects rows of the same length: row 2 has 1 element(s), expected 2"},
+		{expression: "inv([1, 2])", err: "inv: argument 1 expects a square matrix, got a 2-vector"},
+		{expression: "det([])", err: "argument 1 expects a non-empty vector or matrix"},
+		{expression: `transpose([[1, "a"]])`, err: "argument 1 row 1 element 2 expects a number, got string"},
+		{expression: "dot([1, 2], [1, 2, 3])", err: "dot: expected two vectors of the same length, got a 2-vector and a 3-vector"},
+		{expression: "solve([[2, 1], [1, 3]], [1, 2, 3])", err: "expected 2 rows on the right-hand side"},
+		{expression: "identity(0)", err: "identity: size must be an integer between 1 and 1000"},
+		{expression: "identity(1.5)", err: "identity: size must be an integer between 1 and 1000"},
+	})
+}
+
+func TestSingularMatrices(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "det([[1, 2], [2, 4]])", want: "0"},
+		{expression: "det([[1, 2, 3], [4, 5, 6], [7, 8, 9]])", want: "0"},
+		{expression: "det([[0, 0], [0, 0]])", want: "0"},
+		{expression: "inv([[1, 2], [2, 4]])", err: "inv: matrix is singular and has no inverse"},
+		{expression: "[[1, 2], [2, 4]] ^ -1", err: "operator ^: matrix is singular and has no inverse"},
+		{expression: "solve([[1, 2], [2, 4]], [1, 2])", err: "solve: matrix is singular, so the system has no unique solution"},
+		{expression: "solve([[1, 2, 3], [4, 5, 6], [7, 8, 9]], [1, 2, 3])", err: "matrix is singular"},
+	})
+}
+
+// TestMatrixWorkLimit checks that operations on large matrices are rejected before they start
+func TestMatrixWorkLimit(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "count(identity(200) * identity(200))", want: "200"},
+		{expression: "det(identity(200))", want: "1"},
+		{expression: "identity(500) * identity(500)", err: "operator *: multiplying a 500x500 matrix by a 500x500 matrix would take more than 100000000 multiply-adds"},
+		{expression: "identity(300) ^ 1000", err: "operator ^: raising a 300x300 matrix to the power 1000 would take more than 100000000 multiply-adds"},
+		{expression: "det(identity(500))", err: "det: argument 1: factoring a 500x500 matrix would take more than 100000000 multiply-adds"},
+		{expression: "inv(identity(500))", err: "would take more than 100000000 multiply-adds"},
+		{expression: "solve(identity(500), map(identity(500), row -> 1))", err: "would take more than 100000000 multiply-adds"},
+	})
+}
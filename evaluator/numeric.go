@@ -0,0 +1,340 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NumericMode selects how numbers are represented while evaluating an expression
+type NumericMode string
+
+const (
+	// ModeFloat evaluates with 64-bit floating point numbers, the default
+	ModeFloat NumericMode = "float"
+	// ModeDecimal evaluates with exact base-10 decimals rounded to a fixed precision
+	ModeDecimal NumericMode = "decimal"
+	// ModeRational evaluates with exact fractions
+	ModeRational NumericMode = "rational"
+	// ModeBigInt evaluates with arbitrary-precision integers
+	ModeBigInt NumericMode = "bigint"
+	// ModeComplex evaluates with complex numbers
+	ModeComplex NumericMode = "complex"
+)
+
+// NumericOptions selects and configures the numeric mode of an evaluation
+// The zero value selects float mode; unset settings take their defaults
+type NumericOptions struct {
+	Mode      NumericMode  `json:"numericMode,omitempty"`
+	Precision int          `json:"precision,omitempty"` // Significant digits kept in decimal mode
+	Rounding  RoundingMode `json:"rounding,omitempty"`  // How decimal results are rounded to the precision
+	MaxBits   int          `json:"maxBits,omitempty"`   // Largest result allowed in bigint mode, in bits
+}
+
+// normalize fills in defaults and validates the options
+func (o NumericOptions) normalize() (NumericOptions, error) {
+	if o.Mode == "" {
+		o.Mode = ModeFloat
+	}
+
+	if o.Mode != ModeDecimal && (o.Precision != 0 || o.Rounding != "") {
+		return o, fmt.Errorf("precision and rounding only apply to decimal mode")
+	}
+	if o.Mode != ModeBigInt && o.MaxBits != 0 {
+		return o, fmt.Errorf("maxBits only applies to bigint mode")
+	}
+
+	switch o.Mode {
+	case ModeFloat, ModeRational, ModeComplex:
+	case ModeBigInt:
+		if o.MaxBits == 0 {
+			o.MaxBits = DefaultIntegerBits
+		}
+		if o.MaxBits < 1 || o.MaxBits > MaxIntegerBits {
+			return o, fmt.Errorf("maxBits must be between 1 and %d", MaxIntegerBits)
+		}
+	case ModeDecimal:
+		if o.Precision == 0 {
+			o.Precision = DefaultDecimalPrecision
+		}
+		if o.Precision < 1 || o.Precision > MaxDecimalPrecision {
+			return o, fmt.Errorf("precision must be between 1 and %d", MaxDecimalPrecision)
+		}
+		if o.Rounding == "" {
+			o.Rounding = RoundHalfEven
+		}
+		if !o.Rounding.valid() {
+			return o, fmt.Errorf("unknown rounding mode: %s", o.Rounding)
+		}
+	default:
+		return o, fmt.Errorf("unknown numeric mode: %s", o.Mode)
+	}
+
+	return o, nil
+}
+
+// Validate reports whether the options name a known mode with valid settings
+func (o NumericOptions) Validate() error {
+	_, err := o.normalize()
+	return err
+}
+
+// Key returns a string identifying the options after defaults are applied,
+// or an empty string for float mode
+// Expressions compiled for options with different keys must not be shared
+func (o NumericOptions) Key() string {
+	o, err := o.normalize()
+	switch {
+	case err != nil || o.Mode == ModeFloat:
+		return ""
+	case o.Mode == ModeDecimal:
+		return fmt.Sprintf("%s:%d:%s", o.Mode, o.Precision, o.Rounding)
+	case o.Mode == ModeBigInt:
+		return fmt.Sprintf("%s:%d", o.Mode, o.MaxBits)
+	default:
+		return string(o.Mode)
+	}
+}
+
+// NewNumericRegistry creates a registry whose number literals, arithmetic operators
+// and numeric functions use the representation selected by options
+// Custom functions are not carried over; float mode returns NewFunctionRegistry()
+func NewNumericRegistry(options NumericOptions) (*FunctionRegistry, error) {
+	return NewFunctionRegistry().WithNumericMode(options)
+}
+
+// WithNumericMode returns a copy of the registry whose number literals, arithmetic operators
+// and numeric functions use the representation selected by options
+// Custom functions and operators are kept, and take precedence over the built-ins of the mode;
+// numeric custom functions are computed in floating point like the other functions without a replacement
+// Later registrations on r do not affect the copy
+func (r *FunctionRegistry) WithNumericMode(options NumericOptions) (*FunctionRegistry, error) {
+	options, err := options.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	c := r.clone()
+	switch options.Mode {
+	case ModeDecimal:
+		ctx := decimalContext{precision: options.Precision, rounding: options.Rounding}
+		c.install(ctx.numberSystem(), ctx.operators(), ctx.functions())
+	case ModeRational:
+		c.install(rationalNumberSystem(), rationalOperators(), rationalFunctions())
+	case ModeBigInt:
+		ctx := integerContext{maxBits: options.MaxBits}
+		c.install(ctx.numberSystem(), ctx.operators(), ctx.functions())
+	case ModeComplex:
+		c.install(complexNumberSystem(), complexOperators(), complexFunctions())
+	}
+	return c, nil
+}
+
+// numberSystem converts number literals and bound variables into the values of a numeric mode
+// imaginary parses imaginary literals such as 4i and is nil in modes without them
+// approximate represents the results of functions computed in floating point;
+// when it is nil those results stay numbers
+// constants are bound in addition to the built-in constants, unless a variable shadows them
+type numberSystem struct {
+	literal     func(text string) (Value, error)
+	imaginary   func(text string) (Value, error)
+	convert     func(value Value) (Value, error)
+	approximate func(f float64) (Value, error)
+	constants   map[string]Value
+}
+
+// install replaces the registry's number system and its built-in operators and functions with those of a numeric mode
+// Numeric functions without a replacement keep working through floating point,
+// and the arithmetic operators keep accepting dates, durations, vectors and matrices
+// Custom functions and operators are left in place
+func (r *FunctionRegistry) install(numbers *numberSystem, operators []*Operator, functions map[string]builtinFunction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.numbers = numbers
+	for name, function := range r.functions {
+		if function.fn != nil && numbers.approximate != nil {
+			r.functions[name] = numbers.viaFloat(function)
+		}
+	}
+	for name, function := range functions {
+		if existing, ok := r.functions[name]; ok && !existing.builtin {
+			continue
+		}
+		function.name, function.builtin = name, true
+		r.functions[name] = &function
+	}
+	for _, op := range operators {
+		if existing, ok := r.operators[op.Symbol]; ok && !existing.builtin {
+			continue
+		}
+		installed := withMatrices(withTemporal(op))
+		installed.builtin, installed.inline = true, false
+		r.operators[op.Symbol] = installed
+	}
+
+	// sum and avg add with the mode's own operators, where it has them
+	arithmetic := make(map[string]*Operator, len(operators))
+	for _, op := range operators {
+		arithmetic[op.Symbol] = op
+	}
+	add, divide := arithmetic["+"], arithmetic["/"]
+	if add == nil || divide == nil {
+		return
+	}
+	for name, average := range map[string]bool{"sum": false, "avg": true} {
+		if existing, ok := r.functions[name]; ok && existing.builtin {
+			function := exactAggregate(existing, numbers, add, divide, average)
+			function.name, function.builtin = name, true
+			r.functions[name] = &function
+		}
+	}
+}
+
+// number converts the text of a number literal using the registry's number system
+func (r *FunctionRegistry) number(text string) (Value, error) {
+	numbers := r.numberSystem()
+	if strings.HasSuffix(text, "i") {
+		if numbers == nil || numbers.imaginary == nil {
+			return nil, fmt.Errorf("imaginary number %s requires complex mode", text)
+		}
+		return numbers.imaginary(text)
+	}
+	if numbers != nil {
+		return numbers.literal(text)
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: %s", text)
+	}
+	return Number(value), nil
+}
+
+// numberSystem returns the registry's number system, or nil in float mode
+func (r *FunctionRegistry) numberSystem() *numberSystem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.numbers
+}
+
+// viaFloat wraps a numeric function so its numeric result is converted into the number system
+func (n *numberSystem) viaFloat(function *builtinFunction) *builtinFunction {
+	inner := *function
+	return &builtinFunction{
+		name:    function.name,
+		minArgs: function.minArgs,
+		maxArgs: function.maxArgs,
+		builtin: function.builtin,
+		valueFn: func(args []Value) (Value, error) {
+			result, err := inner.invoke(args)
+			if err != nil {
+				return nil, err
+			}
+			// Functions such as max also accept durations, which are not converted
+			number, ok := result.(Number)
+			if !ok {
+				return result, nil
+			}
+			return n.approximate(float64(number))
+		},
+	}
+}
+
+// bind returns env with every bound number converted into the number system
+// and the number system's constants added
+func (n *numberSystem) bind(env *Environment) (*Environment, error) {
+	if n == nil {
+		return env, nil
+	}
+
+	bindings := make(map[string]Value, len(n.constants))
+	for name, value := range n.constants {
+		bindings[name] = value
+	}
+	if env == nil {
+		return &Environment{variables: bindings}, nil
+	}
+
+	// Variables are converted in name order, so the first invalid one is always the one reported
+	names := make([]string, 0, len(env.variables))
+	for name := range env.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var converted Value
+		var err error
+		if raw, ok := env.numerals[name]; ok {
+			converted, err = n.fromJSON(raw)
+		} else {
+			converted, err = n.convertAll(env.variables[name])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid variable %s: %v", name, err)
+		}
+		bindings[name] = converted
+	}
+	return &Environment{variables: bindings, now: env.now, steps: env.steps, numerals: env.numerals}, nil
+}
+
+// fromJSON converts a decoded JSON value into the number system, reading numbers and numeric strings
+// from their text so that no digits are lost
+// Numbers the mode has no literal for, such as 1.5 in bigint mode, are converted like bound numbers
+func (n *numberSystem) fromJSON(raw interface{}) (Value, error) {
+	switch v := raw.(type) {
+	case json.Number:
+		if value, err := n.literal(string(v)); err == nil {
+			return value, nil
+		}
+		f, err := ValueOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return n.convert(f)
+	case string:
+		if isNumeral(v) {
+			if value, err := n.literal(v); err == nil {
+				return value, nil
+			}
+		}
+		return String(v), nil
+	case []interface{}:
+		list := make(List, len(v))
+		for i, element := range v {
+			value, err := n.fromJSON(element)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i+1, err)
+			}
+			list[i] = value
+		}
+		return list, nil
+	}
+
+	value, err := ValueOf(raw)
+	if err != nil {
+		return nil, err
+	}
+	return n.convertAll(value)
+}
+
+// convertAll converts a bound value into the number system, including the elements of lists
+func (n *numberSystem) convertAll(value Value) (Value, error) {
+	list, ok := value.(List)
+	if !ok {
+		return n.convert(value)
+	}
+
+	converted := make(List, len(list))
+	for i, element := range list {
+		c, err := n.convertAll(element)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = c
+	}
+	return converted, nil
+}
@@ -0,0 +1,333 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// builtinOperators returns the binary operators every registry starts with
+func builtinOperators() []*Operator {
+	operators := []*Operator{
+		arithmetic("+", PrecedenceAdditive, func(left, right float64) (float64, error) {
+			return left + right, nil
+		}),
+		arithmetic("-", PrecedenceAdditive, func(left, right float64) (float64, error) {
+			return left - right, nil
+		}),
+		arithmetic("*", PrecedenceMultiplicative, func(left, right float64) (float64, error) {
+			return left * right, nil
+		}),
+		arithmetic("/", PrecedenceMultiplicative, divide),
+		arithmetic("//", PrecedenceMultiplicative, func(left, right float64) (float64, error) {
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return math.Floor(left / right), nil
+		}),
+		arithmetic("%", PrecedenceMultiplicative, func(left, right float64) (float64, error) {
+			if right == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			return floorMod(left, right), nil
+		}),
+		rightAssociative(arithmetic("^", PrecedenceExponent, power)),
+		rightAssociative(arithmetic("**", PrecedenceExponent, power)),
+		elementwiseOperator(".*", PrecedenceMultiplicative, func(left, right float64) (float64, error) {
+			return left * right, nil
+		}),
+		elementwiseOperator("./", PrecedenceMultiplicative, divide),
+		rightAssociative(elementwiseOperator(".^", PrecedenceExponent, power)),
+		equality("==", true),
+		equality("!=", false),
+		comparison("<", func(c int) bool { return c < 0 }),
+		comparison("<=", func(c int) bool { return c <= 0 }),
+		comparison(">", func(c int) bool { return c > 0 }),
+		comparison(">=", func(c int) bool { return c >= 0 }),
+	}
+
+	for _, op := range operators {
+		op.builtin, op.inline = true, true
+	}
+	return operators
+}
+
+// arithmetic creates a left associative operator over numbers, which also accepts quantities, dates,
+// durations, vectors and matrices
+func arithmetic(symbol string, precedence int, fn OperatorFunc) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: precedence,
+		ValueFn: func(left, right Value) (Value, error) {
+			l, lok := left.(Number)
+			r, rok := right.(Number)
+			if !lok || !rok {
+				if fn, ok := matrixOperators[symbol]; ok && (isList(left) || isList(right)) {
+					return fn(left, right)
+				}
+				if lq, rq, ok := quantityPair(left, right); ok {
+					if err := checkRelative(symbol, lq, rq); err != nil {
+						return nil, err
+					}
+					return quantityOperators[symbol](lq, rq)
+				}
+				if fn, ok := temporalOperators[symbol]; ok && (isTemporal(left) || isTemporal(right)) {
+					return fn(left, right)
+				}
+				return nil, operandTypeError(symbol, left, right)
+			}
+
+			result, err := fn(float64(l), float64(r))
+			if err != nil {
+				return nil, err
+			}
+			return Number(result), nil
+		},
+	}
+}
+
+// elementwiseOperator creates a left associative operator applying fn to the elements of vectors and matrices
+func elementwiseOperator(symbol string, precedence int, fn OperatorFunc) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: precedence,
+		ValueFn:    elementwise(symbol, fn),
+	}
+}
+
+// equality creates an equality operator yielding want when its operands are equal
+func equality(symbol string, want bool) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: PrecedenceEquality,
+		ValueFn: func(left, right Value) (Value, error) {
+			equal, err := equals(symbol, left, right)
+			if err != nil {
+				return nil, err
+			}
+			return Bool(equal == want), nil
+		},
+	}
+}
+
+// comparison creates an ordering operator from a test on the three-way comparison of its operands
+func comparison(symbol string, test func(c int) bool) *Operator {
+	return &Operator{
+		Symbol:     symbol,
+		Precedence: PrecedenceComparison,
+		ValueFn: func(left, right Value) (Value, error) {
+			c, err := compare(symbol, left, right)
+			if err != nil {
+				return nil, err
+			}
+			return Bool(test(c)), nil
+		},
+	}
+}
+
+// rightAssociative marks an operator as right associative
+func rightAssociative(op *Operator) *Operator {
+	op.Associativity = RightAssociative
+	return op
+}
+
+// operandTypeError reports a binary operator applied to operands it does not support
+func operandTypeError(symbol string, left, right Value) *TypeError {
+	return newTypeError("operator %s cannot be applied to %s and %s", symbol, left.Kind(), right.Kind())
+}
+
+// equals reports whether two values of the same kind are equal
+// Decimals, rationals and integers are compared exactly with each other and with numbers,
+// complex numbers by both parts, quantities after conversion to a common unit and lists element by element
+func equals(symbol string, left, right Value) (bool, error) {
+	if l, r, ok := decimalPair(left, right); ok {
+		return l.cmp(r) == 0, nil
+	}
+	if l, r, ok := exactPair(left, right); ok {
+		return l.Cmp(r) == 0, nil
+	}
+	if l, r, ok := complexPair(left, right); ok {
+		return l == r, nil
+	}
+	if l, r, ok := quantityPair(left, right); ok {
+		if err := checkCompatible(symbol, l, r); err != nil {
+			return false, err
+		}
+		return l.magnitude == r.in(l.unit), nil
+	}
+
+	switch l := left.(type) {
+	case Number:
+		if r, ok := right.(Number); ok {
+			return l == r, nil
+		}
+	case Bool:
+		if r, ok := right.(Bool); ok {
+			return l == r, nil
+		}
+	case String:
+		if r, ok := right.(String); ok {
+			return l == r, nil
+		}
+	case Date:
+		if r, ok := right.(Date); ok {
+			return l.t.Equal(r.t), nil
+		}
+	case Duration:
+		if r, ok := right.(Duration); ok {
+			return l == r, nil
+		}
+	case List:
+		if r, ok := right.(List); ok {
+			return listsEqual(symbol, l, r)
+		}
+	}
+	return false, operandTypeError(symbol, left, right)
+}
+
+// compare returns -1, 0 or 1 as left is less than, equal to or greater than right
+// Numbers, decimals, rationals, integers and complex numbers without an imaginary part
+// compare numerically, quantities of the same dimensions by size, dates and durations chronologically,
+// and strings lexicographically by byte
+func compare(symbol string, left, right Value) (int, error) {
+	if l, r, ok := decimalPair(left, right); ok {
+		return l.cmp(r), nil
+	}
+	if l, r, ok := exactPair(left, right); ok {
+		return l.Cmp(r), nil
+	}
+	if l, r, ok := complexPair(left, right); ok && imag(l) == 0 && imag(r) == 0 {
+		return threeWay(real(l) < real(r), real(l) > real(r)), nil
+	}
+	if l, r, ok := quantityPair(left, right); ok {
+		if err := checkCompatible(symbol, l, r); err != nil {
+			return 0, err
+		}
+		m := r.in(l.unit)
+		return threeWay(l.magnitude < m, l.magnitude > m), nil
+	}
+
+	switch l := left.(type) {
+	case Number:
+		if r, ok := right.(Number); ok {
+			return threeWay(l < r, l > r), nil
+		}
+	case String:
+		if r, ok := right.(String); ok {
+			return strings.Compare(string(l), string(r)), nil
+		}
+	case Date:
+		if r, ok := right.(Date); ok {
+			return l.t.Compare(r.t), nil
+		}
+	case Duration:
+		if r, ok := right.(Duration); ok {
+			return threeWay(l < r, l > r), nil
+		}
+	}
+	return 0, operandTypeError(symbol, left, right)
+}
+
+// threeWay converts the results of less-than and greater-than tests into -1, 0 or 1
+func threeWay(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// applyUnary applies a prefix operator to its operand
+// Minus and plus apply to every kind of number, to durations and to vectors and matrices; logical not applies to booleans
+func applyUnary(operator string, operand Value) (Value, error) {
+	switch operator {
+	case "-", "+":
+		switch n := operand.(type) {
+		case Decimal:
+			if operator == "-" {
+				return n.neg(), nil
+			}
+			return n, nil
+		case Rational:
+			if operator == "-" {
+				return n.neg(), nil
+			}
+			return n, nil
+		case Integer:
+			if operator == "-" {
+				return n.neg(), nil
+			}
+			return n, nil
+		case Complex:
+			if operator == "-" {
+				// Subtracting from zero keeps a zero part positive, so sqrt(-1) is i rather than -i
+				return 0 - n, nil
+			}
+			return n, nil
+		case Quantity:
+			if operator == "-" {
+				n.magnitude = -n.magnitude
+			}
+			return n, nil
+		case Duration:
+			if operator == "-" {
+				return -n, nil
+			}
+			return n, nil
+		case List:
+			if operator == "-" {
+				return negateMatrix(n)
+			}
+			return n, nil
+		}
+
+		n, err := asNumber(operand, "unary "+operator)
+		if err != nil {
+			return nil, err
+		}
+		if operator == "-" {
+			n = -n
+		}
+		return Number(n), nil
+	case "!":
+		b, err := asBool(operand, "operator !")
+		if err != nil {
+			return nil, err
+		}
+		return Bool(!b), nil
+	default:
+		return nil, fmt.Errorf("unknown unary operator: %s", operator)
+	}
+}
+
+// divide divides left by right, rejecting division by zero
+func divide(left, right float64) (float64, error) {
+	if right == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return left / right, nil
+}
+
+// floorMod returns the remainder of floored division, so the result always
+// takes the sign of the divisor (-7 % 3 == 2, 7 % -3 == -2)
+func floorMod(left, right float64) float64 {
+	result := math.Mod(left, right)
+	if result != 0 && (result < 0) != (right < 0) {
+		result += right
+	}
+	return result
+}
+
+// power raises base to exponent, rejecting results outside the real domain
+func power(base, exponent float64) (float64, error) {
+	if base == 0 && exponent < 0 {
+		return 0, fmt.Errorf("zero raised to a negative power")
+	}
+	if base < 0 && exponent != math.Trunc(exponent) {
+		return 0, fmt.Errorf("negative base raised to a non-integer power")
+	}
+	return math.Pow(base, exponent), nil
+}
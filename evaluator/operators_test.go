@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// evaluateIn compiles expression with registry and evaluates it against env on the VM, checking
+// that walking the tree gives the same result
+func evaluateIn(t *testing.T, registry *FunctionRegistry, expression string, env *Environment) (Value, error) {
+	t.Helper()
+	if registry == nil {
+		registry = NewFunctionRegistry()
+	}
+	program, err := CompileWithRegistry(expression, registry)
+	if err != nil {
+		return nil, err
+	}
+	value, err := program.Evaluate(env)
+	treeValue, treeErr := program.EvaluateTree(env)
+	if !sameResult(value, err, treeValue, treeErr) {
+		t.Errorf("Evaluate(%q) = %v, %v on the VM but %v, %v walking the tree", expression, value, err, treeValue, treeErr)
+	}
+	return value, err
+}
+
+// outcome describes the expected result of an expression: its rendering, or a fragment of its error
+type outcome struct {
+	expression string
+	want       string
+	err        string
+}
+
+// checkOutcomes evaluates each expression with registry against env and compares it with its outcome
+func checkOutcomes(t *testing.T, registry *FunctionRegistry, env *Environment, tests []outcome) {
+	t.Helper()
+	for _, tt := range tests {
+		value, err := evaluateIn(t, registry, tt.expression, env)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Evaluate(%q) = %v, %v, want an error containing %q", tt.expression, value, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("Evaluate(%q) failed: %v", tt.expression, err)
+		case value.String() != tt.want:
+			t.Errorf("Evaluate(%q) = %s, want %s", tt.expression, value, tt.want)
+		}
+	}
+}
+
+func TestArithmeticOperators(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "2^3^2", want: "512"},
+		{expression: "(2^3)^2", want: "64"},
+		{expression: "2**3", want: "8"},
+		{expression: "-2^2", want: "-4"},
+		{expression: "2^-2", want: "0.25"},
+		{expression: "7 % 3", want: "1"},
+		{expression: "-7 % 3", want: "2"},
+		{expression: "5 % -3", want: "-1"},
+		{expression: "7.5 % 2", want: "1.5"},
+		{expression: "7 // 2", want: "3"},
+		{expression: "-7 // 2", want: "-4"},
+		{expression: "2 * 7 // 2 % 4", want: "3"},
+		{expression: "7 % 0", err: "modulo by zero"},
+		{expression: "7 // 0", err: "division by zero"},
+		{expression: "7 / 0", err: "division by zero"},
+		{expression: "(-8)^(1/3)", err: "negative base raised to a non-integer power"},
+		{expression: "0^-1", err: "zero raised to a negative power"},
+	})
+}
+
+func TestLogicAndConditionals(t *testing.T) {
+	env := NewEnvironment(map[string]Value{"x": Number(3), "name": String("ada")})
+	checkOutcomes(t, nil, env, []outcome{
+		{expression: "x > 1 && x < 5", want: "true"},
+		{expression: "x == 3 || undefined", want: "true"},
+		{expression: "x != 3 && undefined", want: "false"},
+		{expression: "false && (1 / 0 > 0)", want: "false"},
+		{expression: `"a" < "b"`, want: "true"},
+		{expression: `name == "ada"`, want: "true"},
+		{expression: "[1, 2] == [1, 2]", want: "true"},
+		{expression: `x > 2 ? "big" : "small"`, want: "big"},
+		{expression: "true ? 1 : false ? 2 : 3", want: "1"},
+		{expression: "true ? 2 : 1 / 0", want: "2"},
+		{expression: "false ? 1 / 0 : 3", want: "3"},
+		{expression: `if(x > 5, "a", "b")`, want: "b"},
+	})
+}
+
+func TestLogicTypeErrors(t *testing.T) {
+	env := NewEnvironment(map[string]Value{"x": Number(3), "name": String("ada")})
+	tests := []outcome{
+		{expression: "true && 1", err: "operator && expects a boolean, got number"},
+		{expression: "x || true", err: "operator || expects a boolean, got number"},
+		{expression: "x ? 2 : 3", err: "condition expects a boolean, got number"},
+		{expression: "if(name, 2, 3)", err: "condition expects a boolean, got string"},
+		{expression: `1 < "a"`, err: "operator < cannot be applied to number and string"},
+		{expression: `x == "3"`, err: "operator == cannot be applied to number and string"},
+		{expression: "true == 1", err: "operator == cannot be applied to boolean and number"},
+		{expression: "true < false", err: "operator < cannot be applied to boolean and boolean"},
+		{expression: "1 < 2 < 3", err: "operator < cannot be applied to boolean and number"},
+	}
+	checkOutcomes(t, nil, env, tests)
+
+	for _, tt := range tests {
+		_, err := evaluateIn(t, nil, tt.expression, env)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("Evaluate(%q) error = %v, want a TypeError", tt.expression, err)
+		}
+	}
+}
@@ -3,152 +3,644 @@ package evaluator
 import (
 	"fmt"
 	"strconv"
-	"strings"
-	"unicode"
 )
 
 // Parser represents an expression parser
+// A Parser holds no per-call state, so it is safe for concurrent use
 type Parser struct {
-	tokens []string
-	pos    int
+	registry *FunctionRegistry
 }
 
-// NewParser creates a new parser instance
+// parseState holds the position of a single Parse call within its tokens
+type parseState struct {
+	registry *FunctionRegistry
+	input    string
+	tokens   []Token
+	pos      int
+}
+
+// NewParser creates a new parser instance using the built-in functions and operators
 func NewParser() *Parser {
+	return NewParserWithRegistry(defaultRegistry)
+}
+
+// NewParserWithRegistry creates a new parser that resolves functions and operators against registry
+func NewParserWithRegistry(registry *FunctionRegistry) *Parser {
 	return &Parser{
-		tokens: make([]string, 0),
-		pos:    0,
+		registry: registry,
 	}
 }
 
 // Parse parses an expression string into an expression tree
+// The whole input must form a single expression; syntax errors are reported as *ParseError
 func (p *Parser) Parse(expression string) (ExprNode, error) {
 	// Tokenize the expression
-	p.tokens = tokenize(expression)
-	p.pos = 0
+	tokens, err := tokenize(expression, p.registry.symbols())
+	if err != nil {
+		return nil, err
+	}
+	return p.parseTokens(expression, tokens)
+}
+
+// ParseEquation parses an equation such as x^2 = 2 into the expression x^2 - 2, whose zeros are its solutions
+// Input without an = is parsed as an expression, which the equation sets equal to zero
+func (p *Parser) ParseEquation(equation string) (ExprNode, error) {
+	minus, _ := p.registry.operator("-")
+	if _, ok := p.registry.operator("="); ok || minus == nil {
+		return p.Parse(equation)
+	}
+
+	tokens, err := tokenize(equation, append(p.registry.symbols(), "="))
+	if err != nil {
+		return nil, err
+	}
+	split := -1
+	for i, token := range tokens {
+		if token.Kind == TokenOperator && token.Text == "=" {
+			if split >= 0 {
+				return nil, newParseError(equation, token.Offset, token.Text, nil, "an equation has only one '='")
+			}
+			split = i
+		}
+	}
+	if split < 0 {
+		return p.parseTokens(equation, tokens)
+	}
+
+	// Each side is parsed from its own tokens, so errors still point into the whole equation
+	equals := tokens[split]
+	left, err := p.parseTokens(equation, append(tokens[:split:split], Token{Kind: TokenEOF, Offset: equals.Offset}))
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.parseTokens(equation, tokens[split+1:])
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryOpNode{Left: left, Operator: minus.Symbol, Right: right, op: minus}, nil
+}
+
+// parseTokens parses the tokens of input, which must form a single expression
+func (p *Parser) parseTokens(input string, tokens []Token) (ExprNode, error) {
+	state := &parseState{
+		registry: p.registry,
+		input:    input,
+		tokens:   tokens,
+		pos:      0,
+	}
 
 	// Parse the expression
-	return p.parseExpression()
+	expr, err := state.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject trailing tokens such as the "2 3" in "1 2 3" or the extra ')' in "(1+2))"
+	if token := state.peek(); token.Kind != TokenEOF {
+		return nil, state.errorAt(token, []string{"operator", "end of expression"}, "unexpected %s", describeToken(token.Text))
+	}
+
+	return expr, nil
+}
+
+// parseExpression parses a full expression: binary ('?' expression ':' expression)?
+// The conditional operator has the lowest precedence and is right associative,
+// so a ? b : c ? d : e parses as a ? b : (c ? d : e)
+func (p *parseState) parseExpression() (ExprNode, error) {
+	condition, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().Text != "?" {
+		return condition, nil
+	}
+	p.pos++
+
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if colon := p.next(); colon.Text != ":" {
+		return nil, p.errorAt(colon, []string{"':'"}, "expected ':' but found %s", describeToken(colon.Text))
+	}
+
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionalNode{Condition: condition, Then: then, Else: otherwise}, nil
+}
+
+// logicalOperators maps the short-circuit operators to their precedence
+// They are parsed into LogicalOpNode rather than resolved against the registry
+var logicalOperators = map[string]int{
+	"&&": PrecedenceAnd,
+	"||": PrecedenceOr,
 }
 
-// parseExpression parses an expression: term (('+' | '-') term)*
-func (p *Parser) parseExpression() (ExprNode, error) {
-	expr, err := p.parseTerm()
+// parseBinary parses operators by precedence climbing: unary (op unary)*
+// Only operators binding at least as tightly as minPrecedence are consumed,
+// so 1 + 2 * 3 groups as 1 + (2 * 3)
+func (p *parseState) parseBinary(minPrecedence int) (ExprNode, error) {
+	expr, err := p.parseUnary()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.pos < len(p.tokens) {
-		op := p.tokens[p.pos]
-		if op != "+" && op != "-" {
+	for {
+		token := p.peek()
+		if precedence, ok := logicalOperators[token.Text]; ok && token.Kind == TokenOperator {
+			if precedence < minPrecedence {
+				break
+			}
+			p.pos++
+
+			right, err := p.parseBinary(precedence + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			expr = &LogicalOpNode{Left: expr, Operator: token.Text, Right: right}
+			continue
+		}
+
+		op, ok := p.binaryOperator(token)
+		if !ok && isConversionWord(token) {
+			if PrecedenceConversion < minPrecedence {
+				break
+			}
+			p.pos++
+
+			unit, err := p.parseUnitAnnotation()
+			if err != nil {
+				return nil, err
+			}
+			if unit == nil {
+				next := p.peek()
+				if token.Text == "in" || next.Text == "in" {
+					// in converts units, so the inch is spelled out
+					return nil, p.errorAt(next, []string{"unit"}, "expected a unit after %s but found %s; in converts units, write inch for inches",
+						token.Text, describeToken(next.Text))
+				}
+				return nil, p.errorAt(next, []string{"unit"}, "expected a unit after %s but found %s", token.Text, describeToken(next.Text))
+			}
+
+			expr = &ConversionNode{Operand: expr, Unit: unit.String(), unit: unit}
+			continue
+		}
+		if !ok || op.Precedence < minPrecedence {
 			break
 		}
 		p.pos++
 
-		right, err := p.parseTerm()
+		// A right associative operator accepts an operand containing itself,
+		// which makes 2 ^ 3 ^ 2 parse as 2 ^ (3 ^ 2)
+		nextPrecedence := op.Precedence + 1
+		if op.Associativity == RightAssociative {
+			nextPrecedence = op.Precedence
+		}
+
+		right, err := p.parseBinary(nextPrecedence)
 		if err != nil {
 			return nil, err
 		}
 
 		expr = &BinaryOpNode{
 			Left:     expr,
-			Operator: op,
+			Operator: op.Symbol,
 			Right:    right,
+			op:       op,
 		}
 	}
 
 	return expr, nil
 }
 
-// parseTerm parses a term: factor (('*' | '/') factor)*
-func (p *Parser) parseTerm() (ExprNode, error) {
+// parseUnary parses a unary expression: ('-' | '+' | '!') operand | postfix
+// Prefix operators bind tighter than '*' and '/' but looser than '^',
+// so -2 * 3 is (-2) * 3 and -2 ^ 2 is -(2 ^ 2)
+func (p *parseState) parseUnary() (ExprNode, error) {
+	if token := p.peek(); token.Kind == TokenOperator && isPrefixOperator(token.Text) {
+		p.pos++
+
+		operand, err := p.parseBinary(PrecedenceUnary)
+		if err != nil {
+			return nil, err
+		}
+
+		return &UnaryOpNode{
+			Operator: token.Text,
+			Operand:  operand,
+		}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a factor followed by any number of factorials and indexes: factor ('!' | '[' expression ']')*
+// A factorial is a call to the registry's factorial function; both bind tighter
+// than every other operator, so -3! is -(3!), 2^3! is 2^(3!) and -xs[0] is -(xs[0])
+func (p *parseState) parsePostfix() (ExprNode, error) {
 	expr, err := p.parseFactor()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.pos < len(p.tokens) {
-		op := p.tokens[p.pos]
-		if op != "*" && op != "/" {
-			break
+	for {
+		token := p.peek()
+		switch {
+		case token.Kind == TokenOperator && token.Text == "!":
+			p.pos++
+
+			function, ok := p.registry.function("factorial")
+			if !ok {
+				return nil, p.errorAt(token, nil, "unknown function: factorial")
+			}
+			expr = &FunctionCallNode{Name: "factorial", Args: []ExprNode{expr}, function: function}
+
+		case token.Kind == TokenLeftBracket:
+			p.pos++
+
+			i, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.next(); closing.Kind != TokenRightBracket {
+				return nil, p.errorAt(closing, []string{"']'"}, "expected ']' but found %s", describeToken(closing.Text))
+			}
+			expr = &IndexNode{Target: expr, Index: i}
+
+		default:
+			return expr, nil
 		}
-		p.pos++
+	}
+}
+
+// operandTokens lists what may start an operand, for error reporting
+var operandTokens = []string{"number", "duration", "string", "identifier", "'('", "'['", "'-'", "'+'", "'!'"}
+
+// parseFactor parses a factor: number | duration | string | 'true' | 'false' | identifier | call |
+// list | lambda | '(' expression ')'
+func (p *parseState) parseFactor() (ExprNode, error) {
+	token := p.next()
 
-		right, err := p.parseFactor()
+	switch token.Kind {
+	case TokenLeftParen:
+		if params, ok := p.lambdaParameters(); ok {
+			return p.parseLambda(params)
+		}
+
+		expr, err := p.parseExpression()
 		if err != nil {
 			return nil, err
 		}
 
-		expr = &BinaryOpNode{
-			Left:     expr,
-			Operator: op,
-			Right:    right,
+		if closing := p.next(); closing.Kind != TokenRightParen {
+			return nil, p.errorAt(closing, []string{"')'"}, "expected ')' but found %s", describeToken(closing.Text))
 		}
-	}
 
-	return expr, nil
-}
+		return expr, nil
 
-// parseFactor parses a factor: number | '(' expression ')'
-func (p *Parser) parseFactor() (ExprNode, error) {
-	if p.pos >= len(p.tokens) {
-		return nil, fmt.Errorf("unexpected end of expression")
-	}
+	case TokenIdentifier:
+		if p.peek().Kind == TokenLeftParen {
+			return p.parseCall(token)
+		}
+		if p.peek().Text == "->" {
+			return p.parseLambda([]Token{token})
+		}
+		switch token.Text {
+		case "true":
+			return &ValueNode{Value: Bool(true)}, nil
+		case "false":
+			return &ValueNode{Value: Bool(false)}, nil
+		}
+		return &VariableNode{Name: token.Text}, nil
 
-	token := p.tokens[p.pos]
-	p.pos++
+	case TokenNumber:
+		value, err := p.registry.number(token.Text)
+		if err != nil {
+			return nil, p.errorAt(token, nil, "%v", err)
+		}
 
-	if token == "(" {
-		expr, err := p.parseExpression()
+		unit, err := p.parseUnitAnnotation()
 		if err != nil {
 			return nil, err
 		}
+		if unit != nil {
+			if value, err = quantityLiteral(float64(value.(Number)), *unit); err != nil {
+				return nil, p.errorAt(token, nil, "%v", err)
+			}
+		}
+		return &ValueNode{Value: value}, nil
+
+	case TokenDuration:
+		value, err := parseDuration(token.Text)
+		if err != nil {
+			return nil, p.errorAt(token, nil, "%v", err)
+		}
+		return &ValueNode{Value: value}, nil
 
-		if p.pos >= len(p.tokens) || p.tokens[p.pos] != ")" {
-			return nil, fmt.Errorf("expected ')'")
+	case TokenString:
+		value, err := strconv.Unquote(token.Text)
+		if err != nil {
+			return nil, p.errorAt(token, nil, "invalid string: %s", token.Text)
 		}
+		return &ValueNode{Value: String(value)}, nil
+
+	case TokenLeftBracket:
+		return p.parseList()
+
+	case TokenEOF:
+		return nil, p.errorAt(token, operandTokens, "unexpected end of expression")
+
+	default:
+		return nil, p.errorAt(token, operandTokens, "unexpected %s", describeToken(token.Text))
+	}
+}
+
+// parseList parses a list literal: '[' (expression (',' expression)*)? ']'
+// The opening bracket has already been consumed
+func (p *parseState) parseList() (ExprNode, error) {
+	elements := make([]ExprNode, 0)
+	if p.peek().Kind == TokenRightBracket {
 		p.pos++
+		return &ListNode{Elements: elements}, nil
+	}
 
-		return expr, nil
+	for {
+		element, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		token := p.next()
+		switch token.Kind {
+		case TokenComma:
+		case TokenRightBracket:
+			return &ListNode{Elements: elements}, nil
+		default:
+			return nil, p.errorAt(token, []string{"','", "']'"},
+				"expected ',' or ']' in list but found %s", describeToken(token.Text))
+		}
 	}
+}
 
-	// Try to parse as number
-	value, err := strconv.ParseFloat(token, 64)
+// lambdaParameters reports whether the tokens after an opening parenthesis form the parameter list
+// of a lambda, '(' (identifier (',' identifier)*)? ')' '->', and returns the parameters if so
+// Nothing is consumed
+func (p *parseState) lambdaParameters() ([]Token, bool) {
+	params := make([]Token, 0)
+	i := p.pos
+	if p.tokens[i].Kind != TokenRightParen {
+		for {
+			if p.tokens[i].Kind != TokenIdentifier {
+				return nil, false
+			}
+			params = append(params, p.tokens[i])
+			i++
+			if p.tokens[i].Kind != TokenComma {
+				break
+			}
+			i++
+		}
+		if p.tokens[i].Kind != TokenRightParen {
+			return nil, false
+		}
+	}
+
+	if p.tokens[i+1].Text != "->" {
+		return nil, false
+	}
+	p.pos = i + 1
+	return params, true
+}
+
+// parseLambda parses the body of a lambda whose parameters have been consumed: '->' expression
+// The body extends as far as possible, so x -> x + 1 is x -> (x + 1)
+func (p *parseState) parseLambda(params []Token) (ExprNode, error) {
+	p.pos++ // consume '->'
+
+	names := make([]string, len(params))
+	for i, param := range params {
+		if isReservedWord(param.Text) {
+			return nil, p.errorAt(param, nil, "invalid parameter name: %s", param.Text)
+		}
+		for _, name := range names[:i] {
+			if name == param.Text {
+				return nil, p.errorAt(param, nil, "duplicate parameter: %s", param.Text)
+			}
+		}
+		names[i] = param.Text
+	}
+
+	body, err := p.parseExpression()
 	if err != nil {
-		return nil, fmt.Errorf("invalid number: %s", token)
+		return nil, err
 	}
 
-	return &ValueNode{Value: value}, nil
+	return &LambdaNode{Params: names, Body: body}, nil
 }
 
-// tokenize splits the expression into tokens
-func tokenize(expression string) []string {
-	var tokens []string
-	var current strings.Builder
+// parseUnitAnnotation parses the unit following a number or a conversion operator, if there is one:
+// unit (('*' | '/') unit | '^' '-'? integer)*
+// Compound units are written without spaces, so 10 m/s is a speed while 10 m / s divides by a variable s
+func (p *parseState) parseUnitAnnotation() (*Unit, error) {
+	first := p.peek()
+	if first.Kind != TokenIdentifier || isReservedWord(first.Text) || isConversionWord(first) {
+		return nil, nil
+	}
+	if _, ok := p.binaryOperator(first); ok || p.tokens[p.pos+1].Kind == TokenLeftParen {
+		return nil, nil
+	}
+	if !isUnitName(first.Text) {
+		return nil, p.errorAt(first, nil, "unknown unit: %s", first.Text)
+	}
+	if p.registry.numberSystem() != nil {
+		return nil, p.errorAt(first, nil, "units are only supported in float mode")
+	}
 
-	for i := 0; i < len(expression); i++ {
-		c := rune(expression[i])
+	next, end := p.pos+1, first.Offset+len(first.Text)
+	adjacent := func(i int) bool { return p.tokens[i].Offset == end }
+	for p.tokens[next].Kind == TokenOperator && adjacent(next) {
+		operator := p.tokens[next]
+		end += len(operator.Text)
 
-		switch {
-		case unicode.IsSpace(c):
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
+		i := next + 1
+		switch operator.Text {
+		case "*", "/":
+			if p.tokens[i].Kind != TokenIdentifier || !adjacent(i) || !isUnitName(p.tokens[i].Text) {
+				return p.unitBetween(first, next)
+			}
+		case "^":
+			if p.tokens[i].Text == "-" && adjacent(i) {
+				end++
+				i++
 			}
-		case c == '(' || c == ')' || c == '+' || c == '-' || c == '*' || c == '/':
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
+			if p.tokens[i].Kind != TokenNumber || !adjacent(i) {
+				return p.unitBetween(first, next)
+			}
+		default:
+			return p.unitBetween(first, next)
+		}
+
+		end += len(p.tokens[i].Text)
+		next = i + 1
+	}
+	return p.unitBetween(first, next)
+}
+
+// unitBetween parses the unit spelled by the tokens from first up to the token at index end
+// and consumes them
+func (p *parseState) unitBetween(first Token, end int) (*Unit, error) {
+	last := p.tokens[end-1]
+	unit, err := parseUnit(p.input[first.Offset : last.Offset+len(last.Text)])
+	if err != nil {
+		return nil, p.errorAt(first, nil, "%v", err)
+	}
+
+	p.pos = end
+	return &unit, nil
+}
+
+// isConversionWord reports whether token is one of the unit conversion operators to and in
+func isConversionWord(token Token) bool {
+	return token.Kind == TokenIdentifier && (token.Text == "to" || token.Text == "in")
+}
+
+// parseCall parses a function call: name '(' (expression (',' expression)*)? ')'
+// The function name has already been consumed
+func (p *parseState) parseCall(name Token) (ExprNode, error) {
+	switch name.Text {
+	case "if":
+		return p.parseIf(name)
+	case "now":
+		return p.parseNow(name)
+	}
+
+	function, ok := p.registry.function(name.Text)
+	if !ok && !iterationForms[name.Text] {
+		return nil, p.errorAt(name, nil, "unknown function: %s", name.Text)
+	}
+
+	args, err := p.parseArguments(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// sum(k^2, k, 1, 10) binds k, while sum(list) is an ordinary call
+	if iterationForms[name.Text] {
+		if len(args) == 4 {
+			if variable, isVariable := args[1].(*VariableNode); isVariable {
+				return p.iteration(name.Text, args[0], variable.Name, args[2], args[3], function), nil
 			}
-			tokens = append(tokens, string(c))
+		}
+		if !ok {
+			return nil, p.errorAt(name, nil, "%s expects (expression, variable, from, to)", name.Text)
+		}
+	}
+
+	return &FunctionCallNode{Name: name.Text, Args: args, function: function}, nil
+}
+
+// iteration creates the node for sum, prod or integrate of body over variable
+// function is the ordinary function of the same name, if there is one
+func (p *parseState) iteration(form string, body ExprNode, variable string, from, to ExprNode, function *builtinFunction) ExprNode {
+	symbol := "+"
+	if form == "prod" {
+		symbol = "*"
+	}
+	op, _ := p.registry.operator(symbol)
+	return &IterationNode{Form: form, Body: body, Variable: variable, From: from, To: to, op: op, numbers: p.registry.numberSystem(), function: function}
+}
+
+// parseIf parses if(condition, then, else) into a ConditionalNode, so that
+// like the ?: operator only the selected branch is evaluated
+func (p *parseState) parseIf(name Token) (ExprNode, error) {
+	args, err := p.parseArguments(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != 3 {
+		return nil, p.errorAt(name, nil, "if expects 3 arguments, got %d", len(args))
+	}
+
+	return &ConditionalNode{Condition: args[0], Then: args[1], Else: args[2]}, nil
+}
+
+// parseNow parses now() into a NowNode, which reads the time from the environment
+// so that it can be pinned for reproducible results
+func (p *parseState) parseNow(name Token) (ExprNode, error) {
+	args, err := p.parseArguments(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != 0 {
+		return nil, p.errorAt(name, nil, "now expects 0 arguments, got %d", len(args))
+	}
+
+	return &NowNode{}, nil
+}
+
+// parseArguments parses a parenthesised, comma separated argument list
+func (p *parseState) parseArguments(name Token) ([]ExprNode, error) {
+	p.pos++ // consume '('
+
+	args := make([]ExprNode, 0)
+	if p.peek().Kind == TokenRightParen {
+		p.pos++
+		return args, nil
+	}
+
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		token := p.next()
+		switch token.Kind {
+		case TokenComma:
+		case TokenRightParen:
+			return args, nil
 		default:
-			current.WriteRune(c)
+			return nil, p.errorAt(token, []string{"','", "')'"},
+				"expected ',' or ')' after argument to %s but found %s", name.Text, describeToken(token.Text))
 		}
 	}
+}
 
-	if current.Len() > 0 {
-		tokens = append(tokens, current.String())
+// binaryOperator returns the registered binary operator spelled by token, if any
+// Word operators such as "mod" are lexed as identifiers, so both kinds are checked
+func (p *parseState) binaryOperator(token Token) (*Operator, bool) {
+	if token.Kind != TokenOperator && token.Kind != TokenIdentifier {
+		return nil, false
 	}
+	return p.registry.operator(token.Text)
+}
+
+// peek returns the next token without consuming it
+func (p *parseState) peek() Token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token
+// The trailing TokenEOF is never consumed, so it is returned repeatedly at the end of the input
+func (p *parseState) next() Token {
+	token := p.tokens[p.pos]
+	if token.Kind != TokenEOF {
+		p.pos++
+	}
+	return token
+}
 
-	return tokens
+// errorAt creates a ParseError pointing at token
+func (p *parseState) errorAt(token Token, expected []string, format string, args ...interface{}) *ParseError {
+	return newParseError(p.input, token.Offset, token.Text, expected, fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnaryOperators(t *testing.T) {
+	env := NewEnvironment(map[string]Value{"x": Number(3), "done": Bool(true)})
+
+	tests := []struct {
+		expression string
+		want       Value
+	}{
+		{"-3 + 4", Number(1)},
+		{"2 * -5", Number(-10)},
+		{"-(1 + 2)", Number(-3)},
+		{"+3", Number(3)},
+		{"--x", Number(3)},
+		{"- -x", Number(3)},
+		{"-+-x", Number(3)},
+		{"-2^2", Number(-4)},
+		{"(-2)^2", Number(4)},
+		{"2^-1", Number(0.5)},
+		{"-x^2", Number(-9)},
+		{"-2 * 3", Number(-6)},
+		{"6 / -2 * 3", Number(-9)},
+		{"!true", Bool(false)},
+		{"!!done", Bool(true)},
+		{"!(1 > 2)", Bool(true)},
+		{"!done || true", Bool(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			got, err := program.Evaluate(env)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnaryOperatorErrors(t *testing.T) {
+	env := NewEnvironment(map[string]Value{"x": Number(3), "name": String("ada")})
+
+	tests := []string{
+		"!x",
+		"!3",
+		"!name",
+		"-true",
+		"+(1 < 2)",
+		"-name",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			program, err := Compile(expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", expression, err)
+			}
+			_, err = program.Evaluate(env)
+			var typeErr *TypeError
+			if !errors.As(err, &typeErr) {
+				t.Errorf("Evaluate(%q) error = %v, want a *TypeError", expression, err)
+			}
+		})
+	}
+}
+
+func TestUnaryOperatorSyntaxErrors(t *testing.T) {
+	tests := []string{
+		"-",
+		"3 -",
+		"!",
+		"2 * ",
+		"-(1 + 2",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Compile(expression)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Errorf("Compile(%q) error = %v, want a *ParseError", expression, err)
+			}
+		})
+	}
+}
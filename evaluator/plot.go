@@ -0,0 +1,226 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Default and maximum sample counts of Plot
+const (
+	DefaultPlotSamples = 200
+	MaxPlotSamples     = 10000
+)
+
+// Limits of the refinement Plot applies between samples
+const (
+	maxPlotDepth       = 8    // how many times an interval may be halved when sampling adaptively
+	plotFlatness       = 2e-3 // the deviation from a straight line, as a fraction of the y range, that adaptive sampling resolves
+	plotJumpThreshold  = 5e-2 // the change between samples, as a fraction of the y range, checked for a discontinuity
+	maxJumpBisections  = 40   // the halvings used to tell a discontinuity from a steep but continuous stretch
+	plotEvaluationRate = 40   // the evaluations allowed per requested sample, including refinement
+)
+
+// PlotOptions configures Plot
+type PlotOptions struct {
+	From     float64
+	To       float64
+	Samples  int  // the number of evenly spaced samples, DefaultPlotSamples when zero
+	Adaptive bool // adds samples where the curve bends sharply between the evenly spaced ones
+}
+
+// Point is a sample of a plotted expression
+// Y is nil where the expression is undefined and where the curve breaks at a discontinuity
+type Point struct {
+	X float64  `json:"x"`
+	Y *float64 `json:"y"`
+}
+
+// Plot samples the program as a function of variable over [From, To], with the other variables taken from env
+// Points where evaluation fails or gives a non-finite number have no Y, and a point without Y is inserted
+// where the curve jumps, so that a chart draws nothing across a discontinuity such as that of tan at pi / 2
+// All the evaluations share one budget of MaxIterationSteps, each taking a step besides those of its sums,
+// products and integrals; running out of it fails the plot
+func Plot(program *Program, variable string, env *Environment, options PlotOptions) ([]Point, error) {
+	if !isIdentifier(variable) {
+		return nil, fmt.Errorf("invalid variable name: %q", variable)
+	}
+	from, to := options.From, options.To
+	if math.IsNaN(from) || math.IsInf(from, 0) || math.IsNaN(to) || math.IsInf(to, 0) || from >= to {
+		return nil, fmt.Errorf("range must be a finite interval [from, to] with from < to")
+	}
+	samples := options.Samples
+	switch {
+	case samples == 0:
+		samples = DefaultPlotSamples
+	case samples < 2 || samples > MaxPlotSamples:
+		return nil, fmt.Errorf("samples must be between 2 and %d", MaxPlotSamples)
+	}
+
+	env = env.prepared()
+	p := &plotter{program: program, variable: variable, env: env, steps: env.steps, budget: samples * plotEvaluationRate}
+	points := make([]Point, samples)
+	for i := range points {
+		x := from + (to-from)*float64(i)/float64(samples-1)
+		y, err := p.at(x)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = Point{X: x, Y: y}
+	}
+	if !p.defined {
+		if p.err != nil {
+			return nil, p.err
+		}
+		return nil, fmt.Errorf("the expression is not finite anywhere in [%g, %g]", from, to)
+	}
+	p.spread = spread(points)
+
+	if options.Adaptive {
+		refined := []Point{points[0]}
+		for i := 1; i < len(points); i++ {
+			if err := p.refine(points[i-1], points[i], 0, &refined); err != nil {
+				return nil, err
+			}
+			refined = append(refined, points[i])
+		}
+		points = refined
+	}
+	return p.breakJumps(points)
+}
+
+// plotter holds the state of a single Plot call
+type plotter struct {
+	program  *Program
+	variable string
+	env      *Environment
+	steps    *int    // the steps left to the plot, shared by all of its evaluations
+	cost     int     // the most steps a single evaluation has taken
+	budget   int     // the evaluations left for refinement
+	spread   float64 // the range of the sampled values, which sets the scale of the refinement
+
+	defined bool  // whether any sample had a value
+	err     error // the first error evaluating a sample
+}
+
+// at evaluates the expression at x, giving nil where it is undefined
+// Only a result that is not a number and running out of steps fail the whole plot
+func (p *plotter) at(x float64) (*float64, error) {
+	if *p.steps <= 0 {
+		return nil, fmt.Errorf("plot exceeds the limit of %d steps", MaxIterationSteps)
+	}
+	p.budget--
+	*p.steps--
+	before := *p.steps
+	value, err := p.program.Evaluate(p.env.extend(map[string]Value{p.variable: Number(x)}))
+	if used := before - *p.steps; used > p.cost {
+		p.cost = used
+	}
+	if err != nil {
+		if *p.steps <= 0 {
+			return nil, err
+		}
+		if p.err == nil {
+			p.err = err
+		}
+		return nil, nil
+	}
+	y, err := asNumber(value, "plot")
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		return nil, nil
+	}
+	p.defined = true
+	return &y, nil
+}
+
+// spare reports whether there are evaluations left for refinement, and steps for another evaluation
+// as costly as the most costly so far, so that refining the curve does not run the plot out of steps
+func (p *plotter) spare() bool {
+	return p.budget > 0 && *p.steps > p.cost
+}
+
+// refine appends samples between a and b where the curve bends away from the straight line
+// between them or where it becomes undefined, halving the interval up to maxPlotDepth times
+func (p *plotter) refine(a, b Point, depth int, points *[]Point) error {
+	if depth >= maxPlotDepth || !p.spare() {
+		return nil
+	}
+	m := Point{X: a.X + (b.X-a.X)/2}
+	var err error
+	if m.Y, err = p.at(m.X); err != nil {
+		return err
+	}
+
+	switch {
+	case a.Y == nil && b.Y == nil && m.Y == nil:
+		return nil
+	case a.Y != nil && b.Y != nil && m.Y != nil:
+		if math.Abs(*m.Y-(*a.Y+*b.Y)/2) <= plotFlatness*p.spread {
+			return nil
+		}
+	}
+
+	if err := p.refine(a, m, depth+1, points); err != nil {
+		return err
+	}
+	*points = append(*points, m)
+	return p.refine(m, b, depth+1, points)
+}
+
+// breakJumps inserts a point without a value wherever the curve jumps between neighbouring samples
+func (p *plotter) breakJumps(points []Point) ([]Point, error) {
+	broken := []Point{points[0]}
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if a.Y != nil && b.Y != nil && math.Abs(*b.Y-*a.Y) > plotJumpThreshold*p.spread && p.spare() {
+			x, jump, err := p.findJump(a, b)
+			if err != nil {
+				return nil, err
+			}
+			if jump {
+				broken = append(broken, Point{X: x})
+			}
+		}
+		broken = append(broken, b)
+	}
+	return broken, nil
+}
+
+// findJump reports whether the curve is discontinuous between a and b, and where
+// It keeps halving the interval, following the half in which the value changes most: across a jump
+// the change stays large however small the interval, while across a steep slope it vanishes
+func (p *plotter) findJump(a, b Point) (float64, bool, error) {
+	change := math.Abs(*b.Y - *a.Y)
+	for i := 0; i < maxJumpBisections && b.X-a.X > machineEpsilon*math.Max(1, math.Abs(a.X)) && *p.steps > p.cost; i++ {
+		m := Point{X: a.X + (b.X-a.X)/2}
+		var err error
+		if m.Y, err = p.at(m.X); err != nil {
+			return 0, false, err
+		}
+		if m.Y == nil {
+			return m.X, true, nil
+		}
+		if math.Abs(*m.Y-*a.Y) > math.Abs(*b.Y-*m.Y) {
+			b = m
+		} else {
+			a = m
+		}
+	}
+	return a.X + (b.X-a.X)/2, math.Abs(*b.Y-*a.Y) > change/2, nil
+}
+
+// spread returns the range of the defined values among points, or 1 when they are all equal
+func spread(points []Point) float64 {
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		if point.Y != nil {
+			lowest, highest = math.Min(lowest, *point.Y), math.Max(highest, *point.Y)
+		}
+	}
+	if highest > lowest {
+		return highest - lowest
+	}
+	return 1
+}
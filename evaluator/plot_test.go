@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPlotBudget checks that the evaluations of a plot share one budget of steps,
+// and that running out of it fails the plot rather than leaving points without a value
+func TestPlotBudget(t *testing.T) {
+	tests := []struct {
+		expression string
+		options    PlotOptions
+		exceeds    bool
+	}{
+		{"sum(1 / k, k, 1, 10000) * x", PlotOptions{From: 0, To: 1, Samples: 200}, true},
+		{"sum(1 / k, k, 1, 1e7) * x", PlotOptions{From: 0, To: 1, Samples: 2}, true},
+		{"sum(1 / k, k, 1, 1000) * x", PlotOptions{From: 0, To: 1, Samples: 200}, false},
+		{"sum(1 / k, k, 1, 1000) * tan(x)", PlotOptions{From: -3, To: 3, Samples: 200, Adaptive: true}, false},
+		{"tan(x)", PlotOptions{From: -3, To: 3, Samples: MaxPlotSamples, Adaptive: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			points, err := Plot(program, "x", nil, tt.options)
+			if tt.exceeds {
+				if err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+					t.Errorf("Plot(%q) error = %v, want exceeding the limit", tt.expression, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plot(%q) failed: %v", tt.expression, err)
+			}
+			if len(points) < tt.options.Samples {
+				t.Errorf("Plot(%q) gave %d points, want at least %d", tt.expression, len(points), tt.options.Samples)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package evaluator
+
+// Program is a compiled expression that can be evaluated many times
+// A Program is immutable, so it is safe to evaluate concurrently from many goroutines
+type Program struct {
+	source  string
+	root    ExprNode
+	code    *bytecode
+	numbers *numberSystem // converts bound variables in the exact numeric modes
+}
+
+// Compile parses an expression using the built-in functions and operators
+func Compile(expression string) (*Program, error) {
+	return CompileWithRegistry(expression, defaultRegistry)
+}
+
+// CompileWithRegistry parses an expression, resolving functions and operators against registry
+// Constant sub-expressions are evaluated once here rather than on every evaluation; Simplify is not
+// applied, since its rules assume numeric variables and would turn x / x with x = 0, or v - v with
+// v a vector, into 1 and 0
+// Later registrations do not affect the returned Program
+func CompileWithRegistry(expression string, registry *FunctionRegistry) (*Program, error) {
+	root, err := NewParserWithRegistry(registry).Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	program := NewProgram(expression, foldConstants(root))
+	program.numbers = registry.numberSystem()
+	return program, nil
+}
+
+// NewProgram creates a program from an already parsed expression tree
+// The tree is compiled to bytecode for the VM; trees containing node types
+// the VM does not support are evaluated by walking the tree instead
+func NewProgram(source string, root ExprNode) *Program {
+	code, err := compileBytecode(root)
+	if err != nil {
+		code = nil
+	}
+
+	return &Program{
+		source: source,
+		root:   root,
+		code:   code,
+	}
+}
+
+// Source returns the expression the program was compiled from
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Evaluate evaluates the program against the variable bindings in env
+// All the sums, products and integrals it computes share one budget of MaxIterationSteps
+func (p *Program) Evaluate(env *Environment) (Value, error) {
+	env, err := p.numbers.bind(env)
+	if err != nil {
+		return nil, err
+	}
+	env = env.prepared()
+
+	if p.code != nil {
+		return p.code.run(env)
+	}
+	return p.root.Evaluate(env)
+}
+
+// EvaluateTree evaluates the program by walking its expression tree, bypassing the VM
+// It produces exactly the same results as Evaluate and is mainly useful for comparison
+func (p *Program) EvaluateTree(env *Environment) (Value, error) {
+	env, err := p.numbers.bind(env)
+	if err != nil {
+		return nil, err
+	}
+	env = env.prepared()
+
+	return p.root.Evaluate(env)
+}
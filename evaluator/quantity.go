@@ -0,0 +1,283 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Quantity is a number tagged with a unit of measurement, such as 5 km or 9.81 m/s^2
+type Quantity struct {
+	magnitude float64
+	unit      Unit
+}
+
+// newQuantity creates a quantity of magnitude in unit
+// Units whose dimensions cancel out, as in km/m, yield a plain Number
+func newQuantity(magnitude float64, unit Unit) Value {
+	if unit.dimensionless() {
+		return Number(magnitude * unit.scale)
+	}
+	return Quantity{magnitude: magnitude, unit: unit}
+}
+
+// quantityLiteral creates the value of a number written with a unit
+// A single unit of time makes a Duration, so 15 min means the same as 15min and can be added to a date
+func quantityLiteral(magnitude float64, unit Unit) (Value, error) {
+	if unit.dims == (dimensions{0, 0, 1, 0, 0, 0, 0}) && len(unit.factors) == 1 && unit.factors[0].power == 1 {
+		return durationOf(magnitude * unit.scale * float64(time.Second))
+	}
+	return newQuantity(magnitude, unit), nil
+}
+
+// Kind implements the Value interface for Quantity
+func (q Quantity) Kind() Kind {
+	return KindQuantity
+}
+
+// String implements the Value interface for Quantity, e.g. 5.3 km
+func (q Quantity) String() string {
+	return Number(q.magnitude).String() + " " + q.unit.String()
+}
+
+// MarshalJSON encodes the quantity as {"magnitude": 5.3, "unit": "km"}
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Magnitude Number `json:"magnitude"`
+		Unit      string `json:"unit"`
+	}{
+		Magnitude: Number(q.magnitude),
+		Unit:      q.unit.String(),
+	})
+}
+
+// si returns the magnitude of the quantity in SI base units
+func (q Quantity) si() float64 {
+	if q.unit.relative {
+		return q.celsius() + iceKelvin
+	}
+	return q.magnitude * q.unit.scale
+}
+
+// celsius returns a temperature in degrees Celsius
+// Conversions between temperature scales go through Celsius, whose zero they share exactly
+func (q Quantity) celsius() float64 {
+	if q.unit.relative {
+		return (q.magnitude - q.unit.ice) * q.unit.scale
+	}
+	return q.magnitude*q.unit.scale - iceKelvin
+}
+
+// in returns the magnitude of the quantity expressed in unit, which must be compatible
+func (q Quantity) in(unit Unit) float64 {
+	switch {
+	case q.unit.scale == unit.scale && q.unit.relative == unit.relative && q.unit.ice == unit.ice:
+		return q.magnitude
+	case unit.relative:
+		return q.celsius()/unit.scale + unit.ice
+	}
+	return q.si() / unit.scale
+}
+
+// quantityPair converts the operands of an operator to quantities when at least one is a Quantity
+// A Number becomes a quantity without a unit
+func quantityPair(left, right Value) (Quantity, Quantity, bool) {
+	_, lok := left.(Quantity)
+	_, rok := right.(Quantity)
+	if !lok && !rok {
+		return Quantity{}, Quantity{}, false
+	}
+
+	l, lok := toQuantity(left)
+	r, rok := toQuantity(right)
+	return l, r, lok && rok
+}
+
+// toQuantity converts a Quantity, Number or Duration to a Quantity
+// A Duration is expressed in the largest of h, min and s that measures it exactly, so 10 km / 2 h is 5 km/h
+func toQuantity(v Value) (Quantity, bool) {
+	switch q := v.(type) {
+	case Quantity:
+		return q, true
+	case Number:
+		return Quantity{magnitude: float64(q), unit: Unit{scale: 1}}, true
+	case Duration:
+		name := "s"
+		for _, larger := range []string{"h", "min"} {
+			if time.Duration(q)%time.Duration(units[larger].scale*float64(time.Second)) == 0 {
+				name = larger
+				break
+			}
+		}
+		unit, _ := lookupUnit(name)
+		return Quantity{magnitude: time.Duration(q).Seconds() / unit.scale, unit: unit}, true
+	default:
+		return Quantity{}, false
+	}
+}
+
+// quantityOperators holds the arithmetic operators that accept quantities, by symbol
+// They are used by the built-in operators whenever an operand is a Quantity
+var quantityOperators = map[string]func(l, r Quantity) (Value, error){
+	"+": func(l, r Quantity) (Value, error) {
+		if err := checkCompatible("+", l, r); err != nil {
+			return nil, err
+		}
+		return newQuantity(l.magnitude+r.in(l.unit), l.unit), nil
+	},
+	"-": func(l, r Quantity) (Value, error) {
+		if err := checkCompatible("-", l, r); err != nil {
+			return nil, err
+		}
+		return newQuantity(l.magnitude-r.in(l.unit), l.unit), nil
+	},
+	"*": func(l, r Quantity) (Value, error) {
+		unit, rescale := l.unit.alike(r.unit)
+		return newQuantity(l.magnitude*r.magnitude*rescale, l.unit.multiply(unit)), nil
+	},
+	"/": func(l, r Quantity) (Value, error) {
+		if r.magnitude == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		unit, rescale := l.unit.alike(r.unit)
+		return newQuantity(l.magnitude/(r.magnitude*rescale), l.unit.multiply(unit.pow(-1))), nil
+	},
+	"//": func(l, r Quantity) (Value, error) {
+		if err := checkCompatible("//", l, r); err != nil {
+			return nil, err
+		}
+		if r.magnitude == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return Number(math.Floor(l.si() / r.si())), nil
+	},
+	"%": func(l, r Quantity) (Value, error) {
+		if err := checkCompatible("%", l, r); err != nil {
+			return nil, err
+		}
+		if r.magnitude == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		return newQuantity(floorMod(l.magnitude, r.in(l.unit)), l.unit), nil
+	},
+	"^":  quantityPower,
+	"**": quantityPower,
+}
+
+// quantityPower raises a quantity to a plain number, which must be an integer
+// or the reciprocal of one that evenly divides every power of the unit, as in (9 m^2)^0.5
+func quantityPower(base, exponent Quantity) (Value, error) {
+	if len(exponent.unit.factors) > 0 {
+		return nil, fmt.Errorf("exponent must be a plain number, got %s", exponent.unit)
+	}
+
+	n := exponent.magnitude
+	magnitude, err := power(base.magnitude, n)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+		return newQuantity(magnitude, base.unit.pow(int(n))), nil
+	}
+	if root := math.Round(1 / n); n != 0 && 1/root == n {
+		if unit, ok := base.unit.root(int(root)); ok {
+			return newQuantity(magnitude, unit), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot raise %s to the power %s", base.unit, Number(n))
+}
+
+// checkCompatible rejects an operator applied to quantities of different dimensions, such as 3 m + 2 s
+func checkCompatible(symbol string, l, r Quantity) error {
+	if l.unit.compatible(r.unit) {
+		return nil
+	}
+	return fmt.Errorf("incompatible units for operator %s: %s and %s", symbol, l.unit.describe(), r.unit.describe())
+}
+
+// checkRelative rejects arithmetic on readings of a temperature scale such as 20 degC, which is ambiguous:
+// 20 degC + 5 degC is 25 degC if 5 degC is a difference, but 298.15 + 278.15 K if both are temperatures
+func checkRelative(symbol string, l, r Quantity) error {
+	for _, q := range []Quantity{l, r} {
+		if q.unit.relative {
+			return fmt.Errorf("operator %s cannot be applied to temperatures in %s; convert them to K first", symbol, q.unit)
+		}
+	}
+	return nil
+}
+
+// convertUnit expresses value in unit, as in 5 km to mi
+func convertUnit(value Value, unit Unit) (Value, error) {
+	q, ok := toQuantity(value)
+	if !ok {
+		return nil, newTypeError("unit conversion expects a number, got %s", value.Kind())
+	}
+	if !q.unit.compatible(unit) {
+		return nil, fmt.Errorf("cannot convert %s to %s", q.unit.describe(), unit)
+	}
+	return Quantity{magnitude: q.in(unit), unit: unit}, nil
+}
+
+// commonUnit expresses values in the unit of the first when any of them is a Quantity or Duration, reporting false
+// when none is
+// The values must all measure the same thing, so max(1 m, 90 cm) compares lengths while max(1 m, 2) is an error;
+// label names the values in errors, as in argument 2 or element 2
+func commonUnit(values []Value, label string) ([]float64, Unit, bool, error) {
+	found := false
+	for _, value := range values {
+		switch value.(type) {
+		case Quantity, Duration:
+			found = true
+		}
+	}
+	if !found {
+		return nil, Unit{}, false, nil
+	}
+
+	first, _ := toQuantity(values[0])
+	magnitudes := make([]float64, len(values))
+	for i, value := range values {
+		q, ok := toQuantity(value)
+		if !ok {
+			return nil, Unit{}, true, newTypeError("%s %d expects a quantity, got %s", label, i+1, value.Kind())
+		}
+		if !q.unit.compatible(first.unit) {
+			return nil, Unit{}, true, fmt.Errorf("incompatible units: %s and %s", first.unit.describe(), q.unit.describe())
+		}
+		magnitudes[i] = q.in(first.unit)
+	}
+	return magnitudes, first.unit, true, nil
+}
+
+// acrossUnits extends a numeric function whose result is in the unit of its arguments, such as max,
+// to quantities of the same dimensions, giving the result in the unit of the first argument
+func acrossUnits(function builtinFunction) builtinFunction {
+	numeric := function
+	function.valueFn = func(args []Value) (Value, error) {
+		magnitudes, unit, ok, err := commonUnit(args, "argument")
+		if !ok {
+			return numeric.invoke(args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result, err := numeric.fn(magnitudes)
+		if err != nil {
+			return nil, err
+		}
+		return inUnit(result, unit, args)
+	}
+	return function
+}
+
+// inUnit creates the result of a function over values expressed in unit, which over durations alone is a duration
+func inUnit(result float64, unit Unit, values []Value) (Value, error) {
+	for _, value := range values {
+		if _, ok := value.(Duration); !ok {
+			return newQuantity(result, unit), nil
+		}
+	}
+	return durationOf(result * unit.scale * float64(time.Second))
+}
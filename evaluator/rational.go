@@ -0,0 +1,351 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// maxRationalBits bounds the combined bit length of the numerator and denominator
+// of a rational result, so an expression such as 3^1000000 fails instead of exhausting memory
+const maxRationalBits = 1 << 16
+
+// approximationDigits is the number of significant digits in the decimal approximation of a rational
+const approximationDigits = 20
+
+// Rational is an exact fraction, produced in rational mode
+// Rationals are immutable; every operation returns a new value
+type Rational struct {
+	r *big.Rat
+}
+
+// Kind implements the Value interface for Rational
+func (q Rational) Kind() Kind {
+	return KindRational
+}
+
+// String implements the Value interface for Rational
+// The fraction is in lowest terms, and whole numbers have no denominator
+func (q Rational) String() string {
+	return q.rat().RatString()
+}
+
+// MarshalJSON encodes the rational as its exact fraction and a decimal approximation,
+// e.g. {"fraction": "1/3", "approximation": "0.33333333333333333333"}
+func (q Rational) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Fraction      string `json:"fraction"`
+		Approximation string `json:"approximation"`
+	}{
+		Fraction:      q.String(),
+		Approximation: q.approximation(),
+	})
+}
+
+// rat returns the fraction, treating the zero Rational as 0
+func (q Rational) rat() *big.Rat {
+	if q.r == nil {
+		return new(big.Rat)
+	}
+	return q.r
+}
+
+// float returns the nearest float64 to q
+func (q Rational) float() float64 {
+	f, _ := q.rat().Float64()
+	return f
+}
+
+// neg returns -q
+func (q Rational) neg() Rational {
+	return Rational{r: new(big.Rat).Neg(q.rat())}
+}
+
+// approximation returns q as a decimal with approximationDigits significant digits
+func (q Rational) approximation() string {
+	return new(big.Float).SetPrec(128).SetRat(q.rat()).Text('g', approximationDigits)
+}
+
+// newRational wraps r as a Value, rejecting fractions beyond maxRationalBits
+func newRational(r *big.Rat) (Value, error) {
+	if r.Num().BitLen()+r.Denom().BitLen() > maxRationalBits {
+		return nil, fmt.Errorf("rational result too large")
+	}
+	return Rational{r: r}, nil
+}
+
+// parseRational parses a number literal as the exact fraction it denotes, so 0.1 is 1/10
+func parseRational(text string) (Rational, error) {
+	d, err := parseDecimal(text)
+	if err != nil {
+		return Rational{}, err
+	}
+
+	r := new(big.Rat).SetInt(d.coef())
+	scale := new(big.Rat).SetInt(pow10(abs(d.exponent)))
+	if d.exponent < 0 {
+		r.Quo(r, scale)
+	} else {
+		r.Mul(r, scale)
+	}
+	return Rational{r: r}, nil
+}
+
+// abs returns the magnitude of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// exactPair converts the operands of a comparison to fractions when at least one is a Rational or Integer
+// Numbers are converted exactly, so comparisons with them are exact too
+func exactPair(left, right Value) (*big.Rat, *big.Rat, bool) {
+	if !isExact(left) && !isExact(right) {
+		return nil, nil, false
+	}
+
+	l, lok := exactRat(left)
+	r, rok := exactRat(right)
+	return l, r, lok && rok
+}
+
+// isExact reports whether v is a Rational or an Integer
+func isExact(v Value) bool {
+	switch v.(type) {
+	case Rational, Integer:
+		return true
+	default:
+		return false
+	}
+}
+
+// exactRat returns the exact fraction equal to a Rational, Integer or finite Number
+func exactRat(v Value) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case Rational:
+		return n.rat(), true
+	case Integer:
+		return new(big.Rat).SetInt(n.int()), true
+	case Number:
+		r := new(big.Rat).SetFloat64(float64(n))
+		return r, r != nil
+	default:
+		return nil, false
+	}
+}
+
+// rationalNumberSystem parses literals as exact fractions and converts bound numbers
+// through their shortest decimal form, so a variable bound to 0.1 is 1/10
+// Functions computed in floating point return numbers, which make any arithmetic
+// they take part in inexact
+func rationalNumberSystem() *numberSystem {
+	return &numberSystem{
+		literal: func(text string) (Value, error) {
+			return parseRational(text)
+		},
+		convert: func(value Value) (Value, error) {
+			if n, ok := value.(Number); ok {
+				d, err := decimalFromFloat(float64(n))
+				if err != nil {
+					return nil, err
+				}
+				return parseRational(d.String())
+			}
+			return value, nil
+		},
+	}
+}
+
+// rationalOperators returns the arithmetic operators of rational mode
+// Comparisons use the built-in operators, which compare rationals exactly
+func rationalOperators() []*Operator {
+	floats := make(map[string]*Operator)
+	for _, op := range builtinOperators() {
+		floats[op.Symbol] = op
+	}
+
+	return []*Operator{
+		rationalOperator(floats["+"], exact((*big.Rat).Add)),
+		rationalOperator(floats["-"], exact((*big.Rat).Sub)),
+		rationalOperator(floats["*"], exact((*big.Rat).Mul)),
+		rationalOperator(floats["/"], func(a, b *big.Rat) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return newRational(new(big.Rat).Quo(a, b))
+		}),
+		rationalOperator(floats["//"], func(a, b *big.Rat) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return newRational(ratFloor(new(big.Rat).Quo(a, b)))
+		}),
+		rationalOperator(floats["%"], func(a, b *big.Rat) (Value, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("modulo by zero")
+			}
+			quotient := ratFloor(new(big.Rat).Quo(a, b))
+			return newRational(new(big.Rat).Sub(a, quotient.Mul(quotient, b)))
+		}),
+		rationalOperator(floats["^"], ratPower),
+		rationalOperator(floats["**"], ratPower),
+	}
+}
+
+// rationalOperator creates an operator computed exactly when both operands are rationals
+// and like the float operator otherwise
+func rationalOperator(float *Operator, fn func(a, b *big.Rat) (Value, error)) *Operator {
+	symbol := float.Symbol
+	return &Operator{
+		Symbol:        symbol,
+		Precedence:    float.Precedence,
+		Associativity: float.Associativity,
+		ValueFn: func(left, right Value) (Value, error) {
+			l, lok := left.(Rational)
+			r, rok := right.(Rational)
+			if lok && rok {
+				return fn(l.rat(), r.rat())
+			}
+
+			lf, lerr := asNumber(left, "")
+			rf, rerr := asNumber(right, "")
+			if lerr != nil || rerr != nil {
+				return nil, operandTypeError(symbol, left, right)
+			}
+			return float.apply(Number(lf), Number(rf))
+		},
+	}
+}
+
+// exact adapts a big.Rat method computing a result that is always defined
+func exact(method func(z, x, y *big.Rat) *big.Rat) func(a, b *big.Rat) (Value, error) {
+	return func(a, b *big.Rat) (Value, error) {
+		return newRational(method(new(big.Rat), a, b))
+	}
+}
+
+// ratPower raises base to exponent, exactly for integer exponents and in floating point otherwise
+func ratPower(base, exponent *big.Rat) (Value, error) {
+	if !exponent.IsInt() || !exponent.Num().IsInt64() {
+		f, _ := base.Float64()
+		e, _ := exponent.Float64()
+		result, err := power(f, e)
+		if err != nil {
+			return nil, err
+		}
+		return Number(result), nil
+	}
+
+	n := exponent.Num().Int64()
+	if base.Sign() == 0 && n < 0 {
+		return nil, fmt.Errorf("zero raised to a negative power")
+	}
+
+	// Reject results too large to represent before computing them
+	if bits := base.Num().BitLen() + base.Denom().BitLen() - 2; bits > 0 && abs64(n) > uint64(maxRationalBits/bits) {
+		return nil, fmt.Errorf("rational result too large")
+	}
+
+	m := new(big.Int).SetUint64(abs64(n))
+	num := new(big.Int).Exp(base.Num(), m, nil)
+	den := new(big.Int).Exp(base.Denom(), m, nil)
+	if n < 0 {
+		num, den = den, num
+	}
+	return newRational(new(big.Rat).SetFrac(num, den))
+}
+
+// ratFloor returns the largest integer not greater than r
+func ratFloor(r *big.Rat) *big.Rat {
+	return new(big.Rat).SetInt(floorQuo(r.Num(), r.Denom()))
+}
+
+// ratRound rounds r half away from zero to the given number of decimal digits
+func ratRound(r *big.Rat, digits int) *big.Rat {
+	scale := new(big.Rat).SetInt(pow10(abs(digits)))
+	if digits < 0 {
+		scale.Inv(scale)
+	}
+
+	half := big.NewRat(1, 2)
+	scaled := new(big.Rat).Mul(new(big.Rat).Abs(r), scale)
+	rounded := ratFloor(scaled.Add(scaled, half))
+	if r.Sign() < 0 {
+		rounded.Neg(rounded)
+	}
+	return rounded.Quo(rounded, scale)
+}
+
+// rationalFunctions returns the numeric functions rational mode computes exactly
+func rationalFunctions() map[string]builtinFunction {
+	return map[string]builtinFunction{
+		"abs": rationalFunction("abs", func(args []*big.Rat) (Value, error) {
+			return newRational(new(big.Rat).Abs(args[0]))
+		}),
+		"floor": rationalFunction("floor", func(args []*big.Rat) (Value, error) {
+			return newRational(ratFloor(args[0]))
+		}),
+		"ceil": rationalFunction("ceil", func(args []*big.Rat) (Value, error) {
+			ceil := ratFloor(new(big.Rat).Neg(args[0]))
+			return newRational(ceil.Neg(ceil))
+		}),
+		"round": rationalFunction("round", func(args []*big.Rat) (Value, error) {
+			digits := 0
+			if len(args) == 2 {
+				if !args[1].IsInt() || args[1].Num().CmpAbs(big.NewInt(maxDecimalExponent)) > 0 {
+					return nil, fmt.Errorf("digits must be an integer")
+				}
+				digits = int(args[1].Num().Int64())
+			}
+			return newRational(ratRound(args[0], digits))
+		}),
+		"sqrt": rationalFunction("sqrt", func(args []*big.Rat) (Value, error) {
+			r := args[0]
+			if r.Sign() < 0 {
+				return nil, fmt.Errorf("argument must be non-negative")
+			}
+
+			num, den := new(big.Int).Sqrt(r.Num()), new(big.Int).Sqrt(r.Denom())
+			if new(big.Int).Mul(num, num).Cmp(r.Num()) == 0 && new(big.Int).Mul(den, den).Cmp(r.Denom()) == 0 {
+				return newRational(new(big.Rat).SetFrac(num, den))
+			}
+			f, _ := r.Float64()
+			return Number(math.Sqrt(f)), nil
+		}),
+		"min": rationalFunction("min", ratExtremum(-1)),
+		"max": rationalFunction("max", ratExtremum(1)),
+	}
+}
+
+// rationalFunction creates a function computed exactly when every argument is a rational
+// and by the float built-in of the same name otherwise
+func rationalFunction(name string, fn func(args []*big.Rat) (Value, error)) builtinFunction {
+	float := builtins[name]
+	return builtinFunction{minArgs: float.minArgs, maxArgs: float.maxArgs, valueFn: func(args []Value) (Value, error) {
+		rats := make([]*big.Rat, len(args))
+		for i, arg := range args {
+			q, ok := arg.(Rational)
+			if !ok {
+				return float.invoke(args)
+			}
+			rats[i] = q.rat()
+		}
+		return fn(rats)
+	}}
+}
+
+// ratExtremum creates min (sign -1) or max (sign 1) over rationals
+func ratExtremum(sign int) func(args []*big.Rat) (Value, error) {
+	return func(args []*big.Rat) (Value, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) == sign {
+				result = arg
+			}
+		}
+		return Rational{r: result}, nil
+	}
+}
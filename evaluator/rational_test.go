@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRationalArithmetic(t *testing.T) {
+	registry, err := NewNumericRegistry(NumericOptions{Mode: ModeRational})
+	if err != nil {
+		t.Fatalf("NewNumericRegistry failed: %v", err)
+	}
+	env := NewEnvironment(map[string]Value{"a": Number(1), "b": Number(0.25)})
+
+	checkOutcomes(t, registry, env, []outcome{
+		// Results are kept in lowest terms, with the sign on the numerator
+		{expression: "1/3 + 1/6", want: "1/2"},
+		{expression: "2/4", want: "1/2"},
+		{expression: "6/3", want: "2"},
+		{expression: "3/-6", want: "-1/2"},
+		{expression: "0/5", want: "0"},
+		{expression: "0.1 + 0.2", want: "3/10"},
+		{expression: "1e-3", want: "1/1000"},
+		{expression: "a / b", want: "4"},
+		{expression: "(1/2)^-2", want: "4"},
+		{expression: "-7 // 2", want: "-4"},
+		{expression: "7 % 3/2", want: "1/2"},
+		{expression: "sqrt(4/9)", want: "2/3"},
+		{expression: "1/3 == 2/6", want: "true"},
+		{expression: "1/3 < 0.34", want: "true"},
+		// Results with no exact value fall back to floating point
+		{expression: "(1/2)^0.5", want: "0.7071067811865476"},
+		{expression: "1/0", err: "division by zero"},
+		{expression: "1 // 0", err: "division by zero"},
+		{expression: "1 % 0", err: "modulo by zero"},
+		{expression: "0^-1", err: "zero raised to a negative power"},
+	})
+
+	value, err := evaluateIn(t, registry, "-2/4", nil)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	want := `{"fraction":"-1/2","approximation":"-0.5"}`
+	if encoded, err := json.Marshal(value); err != nil || string(encoded) != want {
+		t.Errorf("json.Marshal(-2/4) = %s, %v, want %s", encoded, err, want)
+	}
+}
@@ -0,0 +1,304 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Variadic is the arity for functions that accept any number of arguments
+const Variadic = -1
+
+// Precedence levels of the built-in operators
+// Custom operators are placed relative to these levels
+// The conditional operator ?: binds more loosely than all of them
+const (
+	PrecedenceConversion     = 1  // to in
+	PrecedenceOr             = 2  // ||
+	PrecedenceAnd            = 3  // &&
+	PrecedenceEquality       = 6  // == !=
+	PrecedenceComparison     = 8  // < <= > >=
+	PrecedenceAdditive       = 10 // + -
+	PrecedenceMultiplicative = 20 // * / // %
+	PrecedenceUnary          = 30 // prefix - + !
+	PrecedenceExponent       = 40 // ^ **
+)
+
+// Function is the signature of a numeric function callable from expressions
+type Function func(args []float64) (float64, error)
+
+// ValueFunction is the signature of a function that accepts and returns any kind of value
+type ValueFunction func(args []Value) (Value, error)
+
+// OperatorFunc is the signature of a numeric binary operator implementation
+type OperatorFunc func(left, right float64) (float64, error)
+
+// ValueOperatorFunc is the signature of a binary operator over any kind of value
+type ValueOperatorFunc func(left, right Value) (Value, error)
+
+// Associativity determines how operators of equal precedence are grouped
+type Associativity int
+
+const (
+	// LeftAssociative groups a - b - c as (a - b) - c
+	LeftAssociative Associativity = iota
+	// RightAssociative groups a ^ b ^ c as a ^ (b ^ c)
+	RightAssociative
+)
+
+// Operator describes a binary operator known to the parser
+// Exactly one of Fn and ValueFn is set; Fn operators only accept numbers
+type Operator struct {
+	Symbol        string
+	Precedence    int
+	Associativity Associativity
+	Fn            OperatorFunc
+	ValueFn       ValueOperatorFunc
+
+	builtin bool // set for the built-in operators, including those a numeric mode replaces them with
+	inline  bool // set for the built-in float operators, which the VM executes inline
+}
+
+// apply applies the operator to its operands
+func (o *Operator) apply(left, right Value) (Value, error) {
+	if o.ValueFn != nil {
+		return o.ValueFn(left, right)
+	}
+
+	l, err := asNumber(left, "operator "+o.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	r, err := asNumber(right, "operator "+o.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := o.Fn(l, r)
+	if err != nil {
+		return nil, err
+	}
+	return Number(result), nil
+}
+
+// FunctionRegistry holds the functions and binary operators available to expressions
+// It is safe for concurrent use; expressions parsed before a registration keep
+// the definitions they were parsed with
+type FunctionRegistry struct {
+	mu        sync.RWMutex
+	functions map[string]*builtinFunction
+	operators map[string]*Operator
+	numbers   *numberSystem // nil in float mode
+	version   uint64        // incremented by every registration
+}
+
+// defaultRegistry backs parsers created without an explicit registry
+// It is never exposed, so it always holds exactly the built-ins
+var defaultRegistry = NewFunctionRegistry()
+
+// NewFunctionRegistry creates a registry pre-populated with the built-in functions and operators
+func NewFunctionRegistry() *FunctionRegistry {
+	r := &FunctionRegistry{
+		functions: make(map[string]*builtinFunction),
+		operators: make(map[string]*Operator),
+	}
+
+	for _, table := range builtinTables {
+		for name, function := range table {
+			function.name, function.builtin = name, true
+			r.functions[name] = &function
+		}
+	}
+
+	for _, op := range builtinOperators() {
+		r.operators[op.Symbol] = op
+	}
+
+	return r
+}
+
+// clone returns a copy of the registry that later registrations on either do not affect
+func (r *FunctionRegistry) clone() *FunctionRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c := &FunctionRegistry{
+		functions: make(map[string]*builtinFunction, len(r.functions)),
+		operators: make(map[string]*Operator, len(r.operators)),
+		numbers:   r.numbers,
+	}
+	for name, function := range r.functions {
+		c.functions[name] = function
+	}
+	for symbol, op := range r.operators {
+		c.operators[symbol] = op
+	}
+	return c
+}
+
+// Register adds a numeric function to the registry, replacing any function with the same name
+// arity is the exact number of arguments the function takes, or Variadic
+func (r *FunctionRegistry) Register(name string, arity int, fn Function) error {
+	if fn == nil {
+		return fmt.Errorf("function %s has no implementation", name)
+	}
+	return r.register(&builtinFunction{name: name, fn: fn}, arity)
+}
+
+// RegisterValue adds a function over any kind of value to the registry,
+// replacing any function with the same name
+func (r *FunctionRegistry) RegisterValue(name string, arity int, fn ValueFunction) error {
+	if fn == nil {
+		return fmt.Errorf("function %s has no implementation", name)
+	}
+	return r.register(&builtinFunction{name: name, valueFn: fn}, arity)
+}
+
+// register validates and stores a function with the given arity
+func (r *FunctionRegistry) register(function *builtinFunction, arity int) error {
+	name := function.name
+	if !isIdentifier(name) || isReservedWord(name) {
+		return fmt.Errorf("invalid function name: %q", name)
+	}
+	if arity < Variadic {
+		return fmt.Errorf("invalid arity for function %s: %d", name, arity)
+	}
+
+	function.minArgs, function.maxArgs = arity, arity
+	if arity == Variadic {
+		function.minArgs = 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.functions[name] = function
+	r.version++
+	return nil
+}
+
+// RegisterOperator adds a numeric binary operator to the registry, replacing any operator with the same symbol
+// The symbol is either a run of punctuation characters (e.g. "<>") or an identifier (e.g. "mod")
+func (r *FunctionRegistry) RegisterOperator(symbol string, precedence int, associativity Associativity, fn OperatorFunc) error {
+	if fn == nil {
+		return fmt.Errorf("operator %s has no implementation", symbol)
+	}
+	return r.registerOperator(&Operator{Symbol: symbol, Precedence: precedence, Associativity: associativity, Fn: fn})
+}
+
+// RegisterValueOperator adds a binary operator over any kind of value to the registry,
+// replacing any operator with the same symbol
+func (r *FunctionRegistry) RegisterValueOperator(symbol string, precedence int, associativity Associativity, fn ValueOperatorFunc) error {
+	if fn == nil {
+		return fmt.Errorf("operator %s has no implementation", symbol)
+	}
+	return r.registerOperator(&Operator{Symbol: symbol, Precedence: precedence, Associativity: associativity, ValueFn: fn})
+}
+
+// registerOperator validates and stores a binary operator
+func (r *FunctionRegistry) registerOperator(op *Operator) error {
+	symbol := op.Symbol
+	if !isOperatorSymbol(symbol) && !isIdentifier(symbol) {
+		return fmt.Errorf("invalid operator symbol: %q", symbol)
+	}
+	if isPrefixOperator(symbol) || isReservedSymbol(symbol) || isReservedWord(symbol) {
+		return fmt.Errorf("operator symbol is reserved: %q", symbol)
+	}
+	if op.Associativity != LeftAssociative && op.Associativity != RightAssociative {
+		return fmt.Errorf("invalid associativity for operator %s", symbol)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.operators[symbol] = op
+	r.version++
+	return nil
+}
+
+// Version returns a number that changes whenever a function or operator is registered,
+// so that programs compiled against an earlier version can be recognised as stale
+func (r *FunctionRegistry) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.version
+}
+
+// function returns the function registered under name
+func (r *FunctionRegistry) function(name string) (*builtinFunction, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	function, ok := r.functions[name]
+	return function, ok
+}
+
+// operator returns the binary operator registered under symbol
+func (r *FunctionRegistry) operator(symbol string) (*Operator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	op, ok := r.operators[symbol]
+	return op, ok
+}
+
+// symbols returns the punctuation symbols the tokenizer must recognise
+func (r *FunctionRegistry) symbols() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	symbols := []string{"-", "+", "!"}
+	symbols = append(symbols, reservedSymbols...)
+	for symbol := range r.operators {
+		if isOperatorSymbol(symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// reservedSymbols are the punctuation symbols with a fixed meaning in the grammar
+var reservedSymbols = []string{"(", ")", "[", "]", ",", "&&", "||", "?", ":", "->"}
+
+// reservedWords are the identifiers with a fixed meaning in the grammar
+var reservedWords = []string{"true", "false", "if", "now"}
+
+// isReservedSymbol reports whether symbol has a fixed meaning in the grammar
+func isReservedSymbol(symbol string) bool {
+	for _, reserved := range reservedSymbols {
+		if symbol == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// isReservedWord reports whether name has a fixed meaning in the grammar
+func isReservedWord(name string) bool {
+	for _, reserved := range reservedWords {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefixOperator reports whether symbol is one of the fixed prefix operators
+func isPrefixOperator(symbol string) bool {
+	return symbol == "-" || symbol == "+" || symbol == "!"
+}
+
+// isOperatorSymbol reports whether symbol consists only of operator characters
+func isOperatorSymbol(symbol string) bool {
+	symbol = strings.TrimPrefix(symbol, ".")
+	return symbol != "" && strings.IndexFunc(symbol, func(c rune) bool { return !isOperatorChar(c) }) < 0
+}
+
+// isOperatorChar reports whether c may appear in a punctuation operator
+// '.' and '_' are excluded because they belong to numbers and identifiers,
+// although an operator may start with '.' as the element-wise operators such as .* do
+func isOperatorChar(c rune) bool {
+	return c != '.' && c != '_' && (unicode.IsPunct(c) || unicode.IsSymbol(c))
+}
@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestCustomOperators checks that registered operators are parsed with their precedence and associativity
+func TestCustomOperators(t *testing.T) {
+	registry := NewFunctionRegistry()
+	difference := func(left, right float64) (float64, error) { return math.Abs(left - right), nil }
+	subtract := func(left, right float64) (float64, error) { return left - right, nil }
+
+	for _, op := range []struct {
+		symbol        string
+		precedence    int
+		associativity Associativity
+		fn            OperatorFunc
+	}{
+		{"<>", PrecedenceAdditive, LeftAssociative, difference},
+		{"<<>>", PrecedenceExponent + 1, LeftAssociative, difference},
+		{"-:", PrecedenceAdditive, LeftAssociative, subtract},
+		{":-", PrecedenceAdditive, RightAssociative, subtract},
+		{"mod", PrecedenceMultiplicative, LeftAssociative, func(left, right float64) (float64, error) { return math.Mod(left, right), nil }},
+	} {
+		if err := registry.RegisterOperator(op.symbol, op.precedence, op.associativity, op.fn); err != nil {
+			t.Fatalf("RegisterOperator(%q) failed: %v", op.symbol, err)
+		}
+	}
+	if err := registry.RegisterValueOperator("++", PrecedenceAdditive, LeftAssociative, func(left, right Value) (Value, error) {
+		return String(left.String() + right.String()), nil
+	}); err != nil {
+		t.Fatalf("RegisterValueOperator failed: %v", err)
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "2 * 3 <> 10", want: "4"},
+		{expression: "2 * 3 <<>> 10", want: "14"},
+		{expression: "2 ^ 3 <<>> 1", want: "4"},
+		{expression: "10 -: 3 -: 2", want: "5"},
+		{expression: "10 :- 3 :- 2", want: "9"},
+		{expression: "1 + 7 mod 4", want: "4"},
+		{expression: `"a" ++ 1 ++ true`, want: "a1true"},
+		{expression: `"a" <> 1`, err: "operator <> expects a number"},
+	})
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	registry := NewFunctionRegistry()
+	add := func(left, right float64) (float64, error) { return left + right, nil }
+	identity := func(args []float64) (float64, error) { return args[0], nil }
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"operator with letters and punctuation", registry.RegisterOperator("a+", 10, LeftAssociative, add), "invalid operator symbol"},
+		{"empty operator", registry.RegisterOperator("", 10, LeftAssociative, add), "invalid operator symbol"},
+		{"prefix operator", registry.RegisterOperator("!", 10, LeftAssociative, add), "reserved"},
+		{"reserved word operator", registry.RegisterOperator("true", 10, LeftAssociative, add), "reserved"},
+		{"invalid associativity", registry.RegisterOperator("<+>", 10, Associativity(7), add), "invalid associativity"},
+		{"operator without implementation", registry.RegisterOperator("<+>", 10, LeftAssociative, nil), "no implementation"},
+		{"function name", registry.Register("2x", 1, identity), "invalid function name"},
+		{"reserved function name", registry.Register("true", 1, identity), "invalid function name"},
+		{"arity", registry.Register("f", -2, identity), "invalid arity"},
+		{"function without implementation", registry.Register("f", 1, nil), "no implementation"},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want an error containing %q", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+// TestCustomFunctions checks arity, variadic functions, overriding a built-in and that
+// programs keep the definitions they were compiled with
+func TestCustomFunctions(t *testing.T) {
+	registry := NewFunctionRegistry()
+	version := registry.Version()
+	if err := registry.Register("double", 1, func(args []float64) (float64, error) { return args[0] * 2, nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register("count", Variadic, func(args []float64) (float64, error) { return float64(len(args)), nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registry.Version() == version {
+		t.Errorf("Version did not change on registration")
+	}
+
+	checkOutcomes(t, registry, nil, []outcome{
+		{expression: "double(21)", want: "42"},
+		{expression: "double(1, 2)", err: "double: expected 1 argument(s), got 2"},
+		{expression: "count()", want: "0"},
+		{expression: "count(1, 2, 3)", want: "3"},
+		{expression: `double("a")`, err: "expects a number"},
+	})
+
+	program, err := CompileWithRegistry("sqrt(16)", registry)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if err := registry.Register("sqrt", 1, func(args []float64) (float64, error) { return -1, nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	checkOutcomes(t, registry, nil, []outcome{{expression: "sqrt(16)", want: "-1"}})
+	if value, err := program.Evaluate(nil); err != nil || value != Number(4) {
+		t.Errorf("program compiled before the override = %v, %v, want 4", value, err)
+	}
+}
@@ -0,0 +1,668 @@
+package evaluator
+
+import (
+	"math"
+	"sort"
+)
+
+// Simplify returns an equivalent expression tree in a simpler, canonical form
+// Constant sub-trees are folded, identities such as x * 1, x + 0 and x^1 and annihilators such as x * 0
+// are removed, like terms and factors are collected, so x + 2 * x is 3 * x and x * x is x^2,
+// and the operands of sums and products are put in a fixed order, so a + b and b + a simplify alike
+// Variables are assumed to hold numbers and divisors to be non-zero, so x * 0 simplifies to 0 and x / x to 1
+// The tree passed in is not modified
+func Simplify(node ExprNode) ExprNode {
+	if folded, ok := fold(node, true); ok {
+		return folded
+	}
+
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		simplified := *n
+		simplified.Left = Simplify(n.Left)
+		simplified.Right = Simplify(n.Right)
+		return simplifyBinary(&simplified)
+
+	case *UnaryOpNode:
+		return simplifyUnary(n.Operator, Simplify(n.Operand))
+
+	case *LogicalOpNode:
+		return &LogicalOpNode{Left: Simplify(n.Left), Operator: n.Operator, Right: Simplify(n.Right)}
+
+	case *ConditionalNode:
+		condition := Simplify(n.Condition)
+		if c, ok := condition.(*ValueNode); ok {
+			if b, ok := c.Value.(Bool); ok {
+				if b {
+					return Simplify(n.Then)
+				}
+				return Simplify(n.Else)
+			}
+		}
+		return &ConditionalNode{Condition: condition, Then: Simplify(n.Then), Else: Simplify(n.Else)}
+
+	case *ConversionNode:
+		simplified := *n
+		simplified.Operand = Simplify(n.Operand)
+		return &simplified
+
+	case *FunctionCallNode:
+		simplified := *n
+		simplified.Args = simplifyAll(n.Args)
+		return &simplified
+
+	case *ListNode:
+		return &ListNode{Elements: simplifyAll(n.Elements)}
+
+	case *IndexNode:
+		return &IndexNode{Target: Simplify(n.Target), Index: Simplify(n.Index)}
+
+	case *LambdaNode:
+		return &LambdaNode{Params: n.Params, Body: Simplify(n.Body)}
+
+	case *IterationNode:
+		simplified := *n
+		simplified.Body, simplified.From, simplified.To = Simplify(n.Body), Simplify(n.From), Simplify(n.To)
+		return &simplified
+	}
+	return node
+}
+
+// simplifyAll simplifies each of nodes
+func simplifyAll(nodes []ExprNode) []ExprNode {
+	simplified := make([]ExprNode, len(nodes))
+	for i, node := range nodes {
+		simplified[i] = Simplify(node)
+	}
+	return simplified
+}
+
+// foldConstants replaces every constant sub-tree with its value, leaving the rest of the tree as it is
+// Unlike Simplify it makes no assumptions about the values of variables, so the folded tree evaluates
+// to exactly the same value or error as the original for every set of variables
+func foldConstants(node ExprNode) ExprNode {
+	if folded, ok := fold(node, false); ok {
+		return folded
+	}
+
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		folded := *n
+		folded.Left = foldConstants(n.Left)
+		folded.Right = foldConstants(n.Right)
+		return &folded
+
+	case *UnaryOpNode:
+		return &UnaryOpNode{Operator: n.Operator, Operand: foldConstants(n.Operand)}
+
+	case *LogicalOpNode:
+		return &LogicalOpNode{Left: foldConstants(n.Left), Operator: n.Operator, Right: foldConstants(n.Right)}
+
+	case *ConditionalNode:
+		return &ConditionalNode{Condition: foldConstants(n.Condition), Then: foldConstants(n.Then), Else: foldConstants(n.Else)}
+
+	case *ConversionNode:
+		folded := *n
+		folded.Operand = foldConstants(n.Operand)
+		return &folded
+
+	case *FunctionCallNode:
+		folded := *n
+		folded.Args = foldAll(n.Args)
+		return &folded
+
+	case *ListNode:
+		return &ListNode{Elements: foldAll(n.Elements)}
+
+	case *IndexNode:
+		return &IndexNode{Target: foldConstants(n.Target), Index: foldConstants(n.Index)}
+
+	case *LambdaNode:
+		return &LambdaNode{Params: n.Params, Body: foldConstants(n.Body)}
+
+	case *IterationNode:
+		folded := *n
+		folded.Body, folded.From, folded.To = foldConstants(n.Body), foldConstants(n.From), foldConstants(n.To)
+		return &folded
+	}
+	return node
+}
+
+// foldAll folds the constant sub-trees of each of nodes
+func foldAll(nodes []ExprNode) []ExprNode {
+	folded := make([]ExprNode, len(nodes))
+	for i, node := range nodes {
+		folded[i] = foldConstants(node)
+	}
+	return folded
+}
+
+// fold evaluates node when it is constant and evaluates without error
+// When literal is set the value must also read well as a literal, so 2 * 3 folds
+// but 1 / 3 and ln(10) stay as they are rather than becoming long decimals
+func fold(node ExprNode, literal bool) (ExprNode, bool) {
+	if _, ok := node.(*ValueNode); ok || !isConstant(node) {
+		return nil, false
+	}
+	value, err := node.Evaluate(&Environment{})
+	if err != nil {
+		return nil, false
+	}
+	if literal && !isLiteral(value, isArithmetic(node)) {
+		return nil, false
+	}
+	return &ValueNode{Value: value}, true
+}
+
+// isConstant reports whether node always evaluates to the same value:
+// it refers to no variables and applies only built-in functions and operators
+func isConstant(node ExprNode) bool {
+	switch n := node.(type) {
+	case *ValueNode:
+		return true
+	case *BinaryOpNode:
+		op, err := n.resolve()
+		return err == nil && op.builtin && isConstant(n.Left) && isConstant(n.Right)
+	case *UnaryOpNode:
+		return isConstant(n.Operand)
+	case *LogicalOpNode:
+		return isConstant(n.Left) && isConstant(n.Right)
+	case *ConditionalNode:
+		return isConstant(n.Condition) && isConstant(n.Then) && isConstant(n.Else)
+	case *ConversionNode:
+		return isConstant(n.Operand)
+	case *FunctionCallNode:
+		function, err := n.resolve()
+		return err == nil && function.builtin && allConstant(n.Args)
+	case *ListNode:
+		return allConstant(n.Elements)
+	case *IndexNode:
+		return isConstant(n.Target) && isConstant(n.Index)
+	}
+	return false
+}
+
+// allConstant reports whether every one of nodes is constant
+func allConstant(nodes []ExprNode) bool {
+	for _, node := range nodes {
+		if !isConstant(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// isArithmetic reports whether node only adds, subtracts, multiplies and negates,
+// so its value is no less readable than the literals it is made of
+func isArithmetic(node ExprNode) bool {
+	switch n := node.(type) {
+	case *ValueNode:
+		return true
+	case *UnaryOpNode:
+		return isArithmetic(n.Operand)
+	case *BinaryOpNode:
+		return (n.Operator == "+" || n.Operator == "-" || n.Operator == "*") && isArithmetic(n.Left) && isArithmetic(n.Right)
+	case *ListNode:
+		for _, element := range n.Elements {
+			if !isArithmetic(element) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isLiteral reports whether value can be written as a readable literal
+// Numbers must be finite, and whole unless they result from exact arithmetic
+func isLiteral(value Value, exact bool) bool {
+	switch v := value.(type) {
+	case Number:
+		f := float64(v)
+		return !math.IsInf(f, 0) && !math.IsNaN(f) && (exact || f == math.Trunc(f))
+	case Bool, String, Duration, Quantity:
+		return true
+	case List:
+		for _, element := range v {
+			if !isLiteral(element, exact) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// simplifyBinary rewrites a binary operation whose operands are already simplified
+// Only the built-in arithmetic operators are rewritten
+func simplifyBinary(n *BinaryOpNode) ExprNode {
+	op, err := n.resolve()
+	if err != nil || !op.builtin {
+		return n
+	}
+
+	switch n.Operator {
+	case "+", "-":
+		return collectSum(n)
+
+	case "*", "/":
+		return collectProduct(n)
+
+	case "^", "**":
+		l, lok := numberOf(n.Left)
+		r, rok := numberOf(n.Right)
+		switch {
+		case rok && r == 0, lok && l == 1:
+			return constant(1)
+		case rok && r == 1:
+			return n.Left
+		case lok && l == 0 && isPositive(n.Right):
+			return constant(0)
+		case rok:
+			return collectProduct(n)
+		}
+	}
+	return n
+}
+
+// isPositive reports whether node is a positive number
+func isPositive(node ExprNode) bool {
+	x, ok := numberOf(node)
+	return ok && x > 0
+}
+
+// simplifyUnary applies a prefix operator to an already simplified operand
+func simplifyUnary(operator string, operand ExprNode) ExprNode {
+	switch operator {
+	case "+":
+		if _, ok := numberOf(operand); ok {
+			return operand
+		}
+	case "-":
+		if x, ok := numberOf(operand); ok {
+			return constant(-x)
+		}
+		if n, ok := operand.(*BinaryOpNode); ok && (n.Operator == "+" || n.Operator == "-") {
+			return collectSum(negate(operand))
+		}
+		return collectProduct(negate(operand))
+	}
+	return &UnaryOpNode{Operator: operator, Operand: operand}
+}
+
+// factor is a base raised to a numeric exponent within a product
+type factor struct {
+	base     ExprNode
+	exponent float64
+	key      string // the formatted base, which identifies like factors
+}
+
+// product is a numeric coefficient times a list of factors,
+// the form in which products, quotients and the terms of sums are collected
+type product struct {
+	coefficient float64
+	factors     []factor
+}
+
+// collectProduct rewrites a product or quotient with its coefficients multiplied together,
+// like factors combined and the remaining factors in a fixed order
+func collectProduct(node ExprNode) ExprNode {
+	p := product{coefficient: 1}
+	if !p.multiply(node, 1) {
+		return node
+	}
+	return p.node()
+}
+
+// multiply multiplies the product by node raised to exponent, descending into products,
+// quotients, negations and numeric powers
+// It reports false when the rules do not apply: for division by a constant zero, for non-numeric constants
+// and when the coefficient overflows
+func (p *product) multiply(node ExprNode, exponent float64) bool {
+	switch n := node.(type) {
+	case *ValueNode:
+		// Strings, lists and other values do not multiply and add like numbers
+		c, ok := n.Value.(Number)
+		if !ok || (c == 0 && exponent < 0) {
+			return false
+		}
+		p.coefficient *= math.Pow(float64(c), exponent)
+		return isFinite(p.coefficient)
+
+	case *ListNode:
+		return false
+
+	case *UnaryOpNode:
+		if n.Operator == "-" {
+			p.coefficient *= math.Pow(-1, exponent)
+			return p.multiply(n.Operand, exponent)
+		}
+
+	case *BinaryOpNode:
+		if !isBuiltin(n) {
+			break
+		}
+		switch n.Operator {
+		case "*":
+			return p.multiply(n.Left, exponent) && p.multiply(n.Right, exponent)
+		case "/":
+			return p.multiply(n.Left, exponent) && p.multiply(n.Right, -exponent)
+		case "^", "**":
+			k, ok := numberOf(n.Right)
+			if !ok {
+				break
+			}
+			if k == math.Trunc(k) {
+				return p.multiply(n.Left, exponent*k)
+			}
+			// (x^0.5)^2 is not x when x is negative, so a fractional power
+			// is raised further only as a whole unless its base is positive
+			if b, ok := numberOf(n.Left); exponent != 1 && !(ok && b > 0) {
+				break
+			}
+			p.add(n.Left, exponent*k)
+			return true
+		}
+	}
+
+	p.add(node, exponent)
+	return true
+}
+
+// add multiplies the product by base raised to exponent, combining it with a like factor
+func (p *product) add(base ExprNode, exponent float64) {
+	key := Format(base)
+	for i := range p.factors {
+		if p.factors[i].key == key {
+			p.factors[i].exponent += exponent
+			return
+		}
+	}
+	p.factors = append(p.factors, factor{base: base, exponent: exponent, key: key})
+}
+
+// normalize drops factors raised to the power zero and puts the rest in order:
+// variables first, then powers, calls and other expressions, each alphabetically
+func (p *product) normalize() {
+	factors := p.factors[:0]
+	for _, f := range p.factors {
+		if f.exponent != 0 {
+			factors = append(factors, f)
+		}
+	}
+	sort.SliceStable(factors, func(i, j int) bool {
+		if ri, rj := factorRank(factors[i].base), factorRank(factors[j].base); ri != rj {
+			return ri < rj
+		}
+		return factors[i].key < factors[j].key
+	})
+	p.factors = factors
+}
+
+// factorRank orders the kinds of factor within a product
+func factorRank(base ExprNode) int {
+	switch b := base.(type) {
+	case *ValueNode:
+		return 0
+	case *VariableNode:
+		return 1
+	case *BinaryOpNode:
+		if b.Operator == "^" || b.Operator == "**" {
+			return 2
+		}
+	case *FunctionCallNode:
+		return 3
+	}
+	return 4
+}
+
+// degree returns the total exponent of the variables in the product, used to order the terms of a sum
+func (p *product) degree() float64 {
+	degree := 0.0
+	for _, f := range p.variables() {
+		degree += f.exponent
+	}
+	return degree
+}
+
+// precedes orders terms of the same degree by their variables alphabetically,
+// so x^2 comes before x * y and x * y before y^2; it reports false when their variables are the same
+func (p *product) precedes(q *product) (before, ok bool) {
+	a, b := p.variables(), q.variables()
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i].key != b[i].key:
+			return a[i].key < b[i].key, true
+		case a[i].exponent != b[i].exponent:
+			return a[i].exponent > b[i].exponent, true
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b), true
+	}
+	return false, false
+}
+
+// variables returns the factors of the product that are variables, in alphabetical order
+func (p *product) variables() []factor {
+	var variables []factor
+	for _, f := range p.factors {
+		if _, ok := f.base.(*VariableNode); ok {
+			variables = append(variables, f)
+		}
+	}
+	return variables
+}
+
+// key identifies the terms of a sum that differ only in their coefficients
+func (p *product) key() string {
+	return Format((&product{coefficient: 1, factors: p.factors}).node())
+}
+
+// node rebuilds the product as coefficient * numerator / denominator,
+// writing a coefficient such as 0.5 as a small fraction: x / 2
+func (p *product) node() ExprNode {
+	p.normalize()
+	if p.coefficient == 0 {
+		return constant(0)
+	}
+
+	var numerator, denominator []ExprNode
+	for _, f := range p.factors {
+		if f.exponent > 0 {
+			numerator = append(numerator, raise(f.base, f.exponent))
+		} else {
+			denominator = append(denominator, raise(f.base, -f.exponent))
+		}
+	}
+
+	num, den := fraction(math.Abs(p.coefficient))
+	sign := math.Copysign(1, p.coefficient)
+	if num != 1 || len(numerator) == 0 {
+		numerator = append([]ExprNode{constant(sign * num)}, numerator...)
+		sign = 1
+	}
+	if den != 1 {
+		denominator = append([]ExprNode{constant(den)}, denominator...)
+	}
+
+	result := chain(numerator, "*")
+	if len(denominator) > 0 {
+		result = binary(result, "/", chain(denominator, "*"))
+	}
+	if sign < 0 {
+		return negate(result)
+	}
+	return result
+}
+
+// raise creates base^exponent, or just base when the exponent is 1
+func raise(base ExprNode, exponent float64) ExprNode {
+	if exponent == 1 {
+		return base
+	}
+	return binary(base, "^", constant(exponent))
+}
+
+// chain combines nodes with a left-associative operator
+func chain(nodes []ExprNode, operator string) ExprNode {
+	result := nodes[0]
+	for _, node := range nodes[1:] {
+		result = binary(result, operator, node)
+	}
+	return result
+}
+
+// fraction writes x as num / den in lowest terms when it is exactly such a fraction with a small denominator,
+// so 0.75 becomes 3 / 4; any other x, such as 1.0000000000001, is kept as it is
+func fraction(x float64) (num, den float64) {
+	if x == math.Trunc(x) || math.IsInf(x, 0) {
+		return x, 1
+	}
+	for den := 2.0; den <= 12; den++ {
+		num := math.Round(x * den)
+		if num/den == x {
+			g := gcd(num, den)
+			return num / g, den / g
+		}
+	}
+	return x, 1
+}
+
+// gcd returns the greatest common divisor of two whole numbers
+func gcd(a, b float64) float64 {
+	for b != 0 {
+		a, b = b, math.Mod(a, b)
+	}
+	return a
+}
+
+// collectSum rewrites a sum or difference with like terms combined,
+// terms that cancel removed and the rest ordered by descending degree, with the constant last
+func collectSum(node ExprNode) ExprNode {
+	var terms []*product
+	var keys []string
+	if !addTerms(node, 1, &terms, &keys) {
+		return node
+	}
+
+	var kept []*product
+	var degrees []float64
+	for _, term := range terms {
+		if term.coefficient != 0 {
+			kept = append(kept, term)
+			degrees = append(degrees, term.degree())
+		}
+	}
+	if len(kept) == 0 {
+		return constant(0)
+	}
+
+	order := make([]int, len(kept))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := kept[order[i]], kept[order[j]]
+		if (len(a.factors) == 0) != (len(b.factors) == 0) {
+			return len(b.factors) == 0
+		}
+		if degrees[order[i]] != degrees[order[j]] {
+			return degrees[order[i]] > degrees[order[j]]
+		}
+		if before, ok := a.precedes(b); ok {
+			return before
+		}
+		return a.key() < b.key()
+	})
+
+	result := kept[order[0]].node()
+	for _, i := range order[1:] {
+		term := kept[i]
+		if term.coefficient < 0 {
+			positive := product{coefficient: -term.coefficient, factors: term.factors}
+			result = binary(result, "-", positive.node())
+		} else {
+			result = binary(result, "+", term.node())
+		}
+	}
+	return result
+}
+
+// addTerms adds node times sign to the terms of a sum, descending into sums, differences and negations
+// A term is combined with an earlier one that has the same factors
+func addTerms(node ExprNode, sign float64, terms *[]*product, keys *[]string) bool {
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		if isBuiltin(n) && (n.Operator == "+" || n.Operator == "-") {
+			if !addTerms(n.Left, sign, terms, keys) {
+				return false
+			}
+			if n.Operator == "-" {
+				sign = -sign
+			}
+			return addTerms(n.Right, sign, terms, keys)
+		}
+	case *UnaryOpNode:
+		if n.Operator == "-" {
+			return addTerms(n.Operand, -sign, terms, keys)
+		}
+	}
+
+	term := &product{coefficient: sign}
+	if !term.multiply(node, 1) {
+		return false
+	}
+	term.normalize()
+	key := term.key()
+	for i, k := range *keys {
+		if k == key {
+			(*terms)[i].coefficient += term.coefficient
+			return isFinite((*terms)[i].coefficient)
+		}
+	}
+	*terms = append(*terms, term)
+	*keys = append(*keys, key)
+	return true
+}
+
+// isBuiltin reports whether n applies one of the built-in operators, which the rewriting rules assume
+func isBuiltin(n *BinaryOpNode) bool {
+	op, err := n.resolve()
+	return err == nil && op.builtin
+}
+
+// numberOf returns the number a constant node holds
+func numberOf(node ExprNode) (float64, bool) {
+	if v, ok := node.(*ValueNode); ok {
+		if n, ok := v.Value.(Number); ok {
+			return float64(n), true
+		}
+	}
+	return 0, false
+}
+
+// isFinite reports whether x is neither infinite nor NaN, the only coefficients the rewriting rules produce
+func isFinite(x float64) bool {
+	return !math.IsInf(x, 0) && !math.IsNaN(x)
+}
+
+// constant creates a node holding the number x
+func constant(x float64) ExprNode {
+	return &ValueNode{Value: Number(x)}
+}
+
+// binary creates a node applying a built-in binary operator
+func binary(left ExprNode, operator string, right ExprNode) ExprNode {
+	return &BinaryOpNode{Left: left, Operator: operator, Right: right}
+}
+
+// negate creates -operand
+func negate(operand ExprNode) ExprNode {
+	return &UnaryOpNode{Operator: "-", Operand: operand}
+}
+
+// call creates a call to the named built-in function
+func call(name string, args ...ExprNode) ExprNode {
+	return &FunctionCallNode{Name: name, Args: args}
+}
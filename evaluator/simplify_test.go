@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"x * 0.75", "3 * x / 4"},
+		{"x / 3 + x / 3", "2 * x / 3"},
+		{"x / 6 * 4", "2 * x / 3"},
+		{"x * 1.0000000000001", "1.0000000000001 * x"},
+		{"(x^0.5)^2", "(x^0.5)^2"},
+		{"(x^2)^0.5", "(x^2)^0.5"},
+		{"(x^2)^3", "x^6"},
+		{"1e200 * x * 1e200", "1e+200 * x * 1e+200"},
+		{"1e308 * x + 1e308 * x", "1e+308 * x + 1e+308 * x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			root, err := NewParser().Parse(tt.expression)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expression, err)
+			}
+			if got := Format(Simplify(root)); got != tt.want {
+				t.Errorf("Simplify(%q) = %s, want %s", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+// randomExpression builds a random arithmetic expression in x and y of the given depth
+func randomExpression(r *rand.Rand, depth int) string {
+	if depth == 0 {
+		leaves := []string{"x", "y", "2", "3", "0.5", "0.75"}
+		return leaves[r.Intn(len(leaves))]
+	}
+	left, right := randomExpression(r, depth-1), randomExpression(r, depth-1)
+	switch r.Intn(7) {
+	case 0:
+		return fmt.Sprintf("(%s + %s)", left, right)
+	case 1:
+		return fmt.Sprintf("(%s - %s)", left, right)
+	case 2, 3:
+		return fmt.Sprintf("(%s * %s)", left, right)
+	case 4:
+		return fmt.Sprintf("(%s / %s)", left, right)
+	case 5:
+		exponents := []string{"2", "3", "-1", "0.5", "1.5"}
+		return fmt.Sprintf("(%s ^ %s)", left, exponents[r.Intn(len(exponents))])
+	}
+	return fmt.Sprintf("-%s", left)
+}
+
+// TestSimplifyPreservesValue checks on random expressions and points that wherever the original
+// expression evaluates to a finite number, the simplified expression, formatted and parsed again, agrees
+func TestSimplifyPreservesValue(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		expression := randomExpression(r, 1+r.Intn(3))
+		root, err := NewParser().Parse(expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", expression, err)
+		}
+		formatted := Format(Simplify(root))
+		simplified, err := NewParser().Parse(formatted)
+		if err != nil {
+			t.Fatalf("Simplify(%q) = %s, which does not parse: %v", expression, formatted, err)
+		}
+
+		for j := 0; j < 5; j++ {
+			env := NewEnvironment(map[string]Value{
+				"x": Number(math.Round((r.Float64()*8-4)*4) / 4),
+				"y": Number(math.Round((r.Float64()*8-4)*4) / 4),
+			})
+			want, err := root.Evaluate(env)
+			if err != nil {
+				continue
+			}
+			w, ok := want.(Number)
+			if !ok || math.IsInf(float64(w), 0) || math.IsNaN(float64(w)) {
+				continue
+			}
+
+			got, err := simplified.Evaluate(env)
+			if err != nil {
+				t.Errorf("Simplify(%q) = %s fails at %v: %v", expression, formatted, env.variables, err)
+				continue
+			}
+			g, ok := got.(Number)
+			if !ok || math.Abs(float64(g-w)) > 1e-6*math.Max(1, math.Abs(float64(w))) {
+				t.Errorf("Simplify(%q) = %s gives %v at %v, want %v", expression, formatted, got, env.variables, want)
+			}
+		}
+	}
+}
+
+// TestCompileDoesNotSimplify checks that compiled programs keep the meaning of the original expression
+// for variables that the rules of Simplify do not hold for
+func TestCompileDoesNotSimplify(t *testing.T) {
+	env := NewEnvironment(map[string]Value{
+		"x": Number(0),
+		"d": Duration(time.Hour),
+		"s": String("a"),
+		"v": List{Number(1), Number(2)},
+	})
+	checkOutcomes(t, nil, env, []outcome{
+		{expression: "x / x", err: "division by zero"},
+		{expression: "d - d", want: "PT0S"},
+		{expression: "v * 0", want: "[0, 0]"},
+		{expression: "v - v", want: "[0, 0]"},
+		{expression: "s + 0", err: "operator + cannot be applied to string and number"},
+		{expression: "(1 + 2) * v", want: "[3, 6]"},
+	})
+}
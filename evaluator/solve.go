@@ -0,0 +1,412 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Root-finding methods accepted by Solve
+const (
+	MethodBisection = "bisection" // halves a bracket around a sign change; slow but certain
+	MethodBrent     = "brent"     // combines bisection with secant and inverse quadratic steps
+	MethodNewton    = "newton"    // follows the derivative from a starting point; fast near a simple root
+)
+
+// Default and maximum limits of Solve
+const (
+	DefaultSolveTolerance     = 1e-10
+	DefaultSolveMaxIterations = 100
+	MaxSolveIterations        = 10000
+)
+
+// Reasons a SolveError gives for failing to find a root
+const (
+	SolveNoSignChange   = "no_sign_change"  // the expression has the same sign at both ends of the bracket
+	SolveNotConverged   = "not_converged"   // the iteration limit was reached before the tolerance was met
+	SolveDomainError    = "domain_error"    // the expression is not finite or not defined at a point the method visited
+	SolveZeroDerivative = "zero_derivative" // Newton's method reached a point where the derivative is zero
+	SolveNotANumber     = "not_a_number"    // the expression evaluated to something other than a number, such as a string
+	SolveOutOfSteps     = "out_of_steps"    // the evaluations of the solve used up its budget of MaxIterationSteps
+)
+
+// SolveOptions configures Solve
+type SolveOptions struct {
+	Method        string    // bisection, brent or newton; brent when a bracket is given, newton otherwise
+	Bracket       []float64 // [lower, upper], an interval in which the expression changes sign
+	Initial       *float64  // the starting point of Newton's method, the middle of the bracket by default
+	Tolerance     float64   // the accepted error in the root, DefaultSolveTolerance when zero
+	MaxIterations int       // the iteration limit, DefaultSolveMaxIterations when zero
+}
+
+// Solution describes a root found by Solve and how it was found
+type Solution struct {
+	Method        string  `json:"method"`
+	Root          float64 `json:"root"`
+	Residual      float64 `json:"residual"`             // The value of the expression at the root
+	ErrorEstimate float64 `json:"errorEstimate"`        // A bound on the distance to the exact root: half the final bracket, or Newton's last step
+	Iterations    int     `json:"iterations"`           // The number of iterations of the method
+	Evaluations   int     `json:"evaluations"`          // The number of times the expression was evaluated
+	Derivative    string  `json:"derivative,omitempty"` // The symbolic derivative Newton's method used, if one was known
+}
+
+// SolveError reports why Solve failed to find a root
+type SolveError struct {
+	Reason     string   `json:"reason"` // One of the Solve* reasons
+	Message    string   `json:"message"`
+	Method     string   `json:"method"`
+	Iterations int      `json:"iterations"`
+	Estimate   *float64 `json:"estimate,omitempty"` // The last estimate of the root, if the method got that far
+}
+
+// Error implements the error interface for SolveError
+func (e *SolveError) Error() string {
+	return fmt.Sprintf("%s method failed: %s", e.Method, e.Message)
+}
+
+// Solve finds a value of variable at which the program evaluates to zero,
+// with the other variables taken from env
+// Every failure once the options are valid is reported as *SolveError: a failure of the method itself, such as
+// a bracket without a sign change or running out of iterations, as well as an error evaluating the expression
+// or its derivative at a point the method visited, or a result that is not a number
+// All the evaluations share one budget of MaxIterationSteps, each taking a step besides those of its sums,
+// products and integrals; running out of it fails the solve
+func Solve(program *Program, variable string, env *Environment, options SolveOptions) (Solution, error) {
+	s := &solver{program: program, variable: variable, env: env.prepared(), tolerance: options.Tolerance, limit: options.MaxIterations}
+	if err := s.configure(options); err != nil {
+		return Solution{}, err
+	}
+
+	switch s.method {
+	case MethodBisection:
+		return s.bisection(options.Bracket[0], options.Bracket[1])
+	case MethodBrent:
+		return s.brent(options.Bracket[0], options.Bracket[1])
+	default:
+		if options.Initial != nil {
+			return s.newton(*options.Initial)
+		}
+		return s.newton(options.Bracket[0]/2 + options.Bracket[1]/2)
+	}
+}
+
+// solver holds the state of a single Solve call
+type solver struct {
+	program   *Program
+	variable  string
+	env       *Environment
+	method    string
+	tolerance float64
+	limit     int
+
+	iterations  int
+	evaluations int
+	estimate    *float64
+	derivative  string // the symbolic derivative Newton's method uses, if one is known
+}
+
+// configure validates the options and fills in their defaults
+func (s *solver) configure(options SolveOptions) error {
+	if !isIdentifier(s.variable) {
+		return fmt.Errorf("invalid variable name: %q", s.variable)
+	}
+
+	switch {
+	case math.IsNaN(s.tolerance) || s.tolerance < 0:
+		return fmt.Errorf("tolerance must be a positive number")
+	case s.tolerance == 0:
+		s.tolerance = DefaultSolveTolerance
+	}
+	switch {
+	case s.limit < 0 || s.limit > MaxSolveIterations:
+		return fmt.Errorf("maxIterations must be between 1 and %d", MaxSolveIterations)
+	case s.limit == 0:
+		s.limit = DefaultSolveMaxIterations
+	}
+
+	if options.Bracket != nil {
+		if len(options.Bracket) != 2 {
+			return fmt.Errorf("bracket must have exactly two ends, got %d", len(options.Bracket))
+		}
+		lower, upper := options.Bracket[0], options.Bracket[1]
+		if math.IsNaN(lower) || math.IsInf(lower, 0) || math.IsNaN(upper) || math.IsInf(upper, 0) || lower >= upper {
+			return fmt.Errorf("bracket must be a finite interval [lower, upper] with lower < upper")
+		}
+	}
+	if options.Initial != nil && (math.IsNaN(*options.Initial) || math.IsInf(*options.Initial, 0)) {
+		return fmt.Errorf("initial must be a finite number")
+	}
+
+	s.method = options.Method
+	if s.method == "" {
+		s.method = MethodBrent
+		if options.Bracket == nil {
+			s.method = MethodNewton
+		}
+	}
+	switch s.method {
+	case MethodBisection, MethodBrent:
+		if options.Bracket == nil {
+			return fmt.Errorf("the %s method requires a bracket [lower, upper]", s.method)
+		}
+	case MethodNewton:
+		if options.Initial == nil && options.Bracket == nil {
+			return fmt.Errorf("the newton method requires an initial value or a bracket")
+		}
+	default:
+		return fmt.Errorf("unknown method: %s (expected bisection, brent or newton)", s.method)
+	}
+	return nil
+}
+
+// f evaluates the expression at x
+// An expression that fails there, as ln(x) does for x <= 0 and 1 / x for x = 0, is a domain error of the method
+func (s *solver) f(x float64) (float64, error) {
+	s.evaluations++
+	return s.at(s.program, "the expression", x)
+}
+
+// at evaluates program, the expression or its derivative, at x, taking a step of the budget of the solve
+func (s *solver) at(program *Program, what string, x float64) (float64, error) {
+	if *s.env.steps <= 0 {
+		return 0, s.outOfSteps()
+	}
+	*s.env.steps--
+	value, err := program.Evaluate(s.env.extend(map[string]Value{s.variable: Number(x)}))
+	if err != nil {
+		if *s.env.steps <= 0 {
+			return 0, s.outOfSteps()
+		}
+		return 0, s.fail(SolveDomainError, "%s fails at %s = %g: %v", what, s.variable, x, err)
+	}
+	y, ok := value.(Number)
+	if !ok {
+		return 0, s.fail(SolveNotANumber, "%s must evaluate to a number, got %s at %s = %g", what, value.Kind(), s.variable, x)
+	}
+	if math.IsNaN(float64(y)) || math.IsInf(float64(y), 0) {
+		return 0, s.fail(SolveDomainError, "%s is not finite at %s = %g", what, s.variable, x)
+	}
+	return float64(y), nil
+}
+
+// fail creates a SolveError describing the current state of the method
+func (s *solver) fail(reason, format string, args ...interface{}) *SolveError {
+	return &SolveError{
+		Reason:     reason,
+		Message:    fmt.Sprintf(format, args...),
+		Method:     s.method,
+		Iterations: s.iterations,
+		Estimate:   s.estimate,
+	}
+}
+
+// notConverged reports running out of iterations
+func (s *solver) notConverged() *SolveError {
+	return s.fail(SolveNotConverged, "no root within tolerance %g after %d iterations", s.tolerance, s.iterations)
+}
+
+// outOfSteps reports using up the budget of steps
+func (s *solver) outOfSteps() *SolveError {
+	return s.fail(SolveOutOfSteps, "the solve exceeds the limit of %d steps", MaxIterationSteps)
+}
+
+// solution describes a root found by the method
+func (s *solver) solution(root, residual, errorEstimate float64) Solution {
+	return Solution{
+		Method:        s.method,
+		Root:          root,
+		Residual:      residual,
+		ErrorEstimate: errorEstimate,
+		Iterations:    s.iterations,
+		Evaluations:   s.evaluations,
+		Derivative:    s.derivative,
+	}
+}
+
+// endpoints evaluates the expression at both ends of a bracket and checks that its sign changes
+func (s *solver) endpoints(a, b float64) (fa, fb float64, err error) {
+	if fa, err = s.f(a); err != nil {
+		return 0, 0, err
+	}
+	if fb, err = s.f(b); err != nil {
+		return 0, 0, err
+	}
+	if fa*fb > 0 {
+		return 0, 0, s.fail(SolveNoSignChange, "the expression has the same sign at %s = %g and %s = %g, so the bracket may not contain a root",
+			s.variable, a, s.variable, b)
+	}
+	return fa, fb, nil
+}
+
+// bisection halves the bracket [a, b], keeping the half in which the sign changes
+func (s *solver) bisection(a, b float64) (Solution, error) {
+	fa, fb, err := s.endpoints(a, b)
+	if err != nil {
+		return Solution{}, err
+	}
+	switch {
+	case fa == 0:
+		return s.solution(a, 0, 0), nil
+	case fb == 0:
+		return s.solution(b, 0, 0), nil
+	}
+
+	for s.iterations < s.limit {
+		s.iterations++
+		m := a + (b-a)/2
+		s.estimate = &m
+		fm, err := s.f(m)
+		if err != nil {
+			return Solution{}, err
+		}
+		if fm == 0 || (b-a)/2 <= s.tolerance {
+			return s.solution(m, fm, (b-a)/2), nil
+		}
+		if math.Signbit(fm) == math.Signbit(fa) {
+			a, fa = m, fm
+		} else {
+			b = m
+		}
+	}
+	return Solution{}, s.notConverged()
+}
+
+// brent finds a root in the bracket [a, b] by Brent's method, which takes inverse quadratic
+// interpolation or secant steps when they make good progress and falls back to bisection otherwise
+func (s *solver) brent(a, b float64) (Solution, error) {
+	fa, fb, err := s.endpoints(a, b)
+	if err != nil {
+		return Solution{}, err
+	}
+
+	// b is the best estimate so far, c the other end of a bracket [b, c] and a the previous estimate
+	c, fc := b, fb
+	var d, e float64
+	for s.iterations < s.limit {
+		s.iterations++
+		if (fb > 0 && fc > 0) || (fb < 0 && fc < 0) {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+
+		estimate := b
+		s.estimate = &estimate
+		tol := 2*machineEpsilon*math.Abs(b) + s.tolerance/2
+		half := (c - b) / 2
+		if math.Abs(half) <= tol || fb == 0 {
+			return s.solution(b, fb, math.Abs(half)), nil
+		}
+
+		if math.Abs(e) >= tol && math.Abs(fa) > math.Abs(fb) {
+			// Try interpolation: secant when only two points are known, inverse quadratic otherwise
+			var p, q float64
+			r := fb / fa
+			if a == c {
+				p = 2 * half * r
+				q = 1 - r
+			} else {
+				t, u := fa/fc, fb/fc
+				p = r * (2*half*t*(t-u) - (b-a)*(u-1))
+				q = (t - 1) * (u - 1) * (r - 1)
+			}
+			if p > 0 {
+				q = -q
+			}
+			p = math.Abs(p)
+			// Accept the interpolation only if it stays well inside the bracket and converges quickly enough
+			if 2*p < math.Min(3*half*q-math.Abs(tol*q), math.Abs(e*q)) {
+				e = d
+				d = p / q
+			} else {
+				d = half
+				e = d
+			}
+		} else {
+			d = half
+			e = d
+		}
+
+		a, fa = b, fb
+		if math.Abs(d) > tol {
+			b += d
+		} else {
+			b += math.Copysign(tol, half)
+		}
+		if fb, err = s.f(b); err != nil {
+			return Solution{}, err
+		}
+	}
+	return Solution{}, s.notConverged()
+}
+
+// newton follows the tangent of the expression from x until the step is within tolerance
+// The derivative is symbolic when the expression can be differentiated, and a central difference otherwise
+func (s *solver) newton(x float64) (Solution, error) {
+	derivative := s.differentiate()
+
+	for s.iterations < s.limit {
+		s.iterations++
+		estimate := x
+		s.estimate = &estimate
+
+		fx, err := s.f(x)
+		if err != nil {
+			return Solution{}, err
+		}
+		if fx == 0 {
+			return s.solution(x, 0, 0), nil
+		}
+		dfx, err := derivative(x)
+		if err != nil {
+			return Solution{}, err
+		}
+		if dfx == 0 {
+			return Solution{}, s.fail(SolveZeroDerivative, "the derivative is zero at %s = %g; try another initial value", s.variable, x)
+		}
+
+		step := fx / dfx
+		x -= step
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Solution{}, s.fail(SolveDomainError, "the method diverged from %s = %g", s.variable, estimate)
+		}
+		if math.Abs(step) <= s.tolerance {
+			fx, err := s.f(x)
+			if err != nil {
+				return Solution{}, err
+			}
+			return s.solution(x, fx, math.Abs(step)), nil
+		}
+	}
+	return Solution{}, s.notConverged()
+}
+
+// differentiate returns the derivative of the expression as a function,
+// recording its text when it is symbolic
+func (s *solver) differentiate() func(x float64) (float64, error) {
+	if d, err := Differentiate(s.program.root, s.variable, s.env); err == nil {
+		program := NewProgram(Format(d), d)
+		s.derivative = program.Source()
+		return func(x float64) (float64, error) {
+			return s.at(program, "the derivative", x)
+		}
+	}
+
+	// A central difference with a step near the cube root of machine epsilon balances
+	// truncation against rounding error
+	return func(x float64) (float64, error) {
+		h := 6e-6 * math.Max(1, math.Abs(x))
+		above, err := s.f(x + h)
+		if err != nil {
+			return 0, err
+		}
+		below, err := s.f(x - h)
+		if err != nil {
+			return 0, err
+		}
+		return (above - below) / (2 * h), nil
+	}
+}
@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// solve compiles expression and solves it for x with options
+func solve(t *testing.T, expression string, options SolveOptions) (Solution, error) {
+	t.Helper()
+	program, err := Compile(expression)
+	if err != nil {
+		t.Fatalf("Compile(%q) failed: %v", expression, err)
+	}
+	return Solve(program, "x", NewEnvironment(nil), options)
+}
+
+// TestSolveConverges checks that every method finds the square root of 2 within tolerance
+func TestSolveConverges(t *testing.T) {
+	one := 1.0
+	for _, options := range []SolveOptions{
+		{Method: MethodBisection, Bracket: []float64{0, 2}},
+		{Method: MethodBrent, Bracket: []float64{0, 2}},
+		{Method: MethodNewton, Bracket: []float64{0, 2}},
+		{Method: MethodNewton, Initial: &one, Tolerance: 1e-12},
+		{Bracket: []float64{0, 2}},
+	} {
+		solution, err := solve(t, "x^2 - 2", options)
+		if err != nil {
+			t.Errorf("Solve(x^2 - 2, %+v) failed: %v", options, err)
+			continue
+		}
+		tolerance := options.Tolerance
+		if tolerance == 0 {
+			tolerance = DefaultSolveTolerance
+		}
+		if math.Abs(solution.Root-math.Sqrt2) > tolerance {
+			t.Errorf("Solve(x^2 - 2, %+v) = %v, want %v within %g", options, solution.Root, math.Sqrt2, tolerance)
+		}
+		if solution.Iterations == 0 || solution.Evaluations < solution.Iterations {
+			t.Errorf("Solve(x^2 - 2, %+v) took %d iterations and %d evaluations", options, solution.Iterations, solution.Evaluations)
+		}
+	}
+}
+
+// TestSolveIterationLimit checks that running out of iterations reports the iterations taken and the last estimate
+func TestSolveIterationLimit(t *testing.T) {
+	_, err := solve(t, "x^2 - 2", SolveOptions{Method: MethodBisection, Bracket: []float64{0, 2}, MaxIterations: 5})
+	var solveErr *SolveError
+	if !errors.As(err, &solveErr) || solveErr.Reason != SolveNotConverged {
+		t.Fatalf("Solve error = %v, want a %s", err, SolveNotConverged)
+	}
+	if solveErr.Iterations != 5 || solveErr.Estimate == nil || math.Abs(*solveErr.Estimate-math.Sqrt2) > 0.1 {
+		t.Errorf("SolveError = %+v, want 5 iterations and an estimate near %v", solveErr, math.Sqrt2)
+	}
+
+	if _, err := solve(t, "x^2 - 2", SolveOptions{Bracket: []float64{0, 2}, MaxIterations: MaxSolveIterations + 1}); err == nil {
+		t.Errorf("Solve with more than %d iterations succeeded", MaxSolveIterations)
+	}
+}
+
+// TestNewtonUsesDerivative checks that Newton's method reports the symbolic derivative when there is one,
+// and falls back to a numeric one for functions without a known derivative
+func TestNewtonUsesDerivative(t *testing.T) {
+	one := 1.0
+	solution, err := solve(t, "x^3 - 2 * x - 5", SolveOptions{Method: MethodNewton, Initial: &one})
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if solution.Derivative != "3 * x^2 - 2" {
+		t.Errorf("Derivative = %q, want %q", solution.Derivative, "3 * x^2 - 2")
+	}
+	// With the exact derivative, Newton's method takes one evaluation of the expression per iteration and a final one
+	if solution.Evaluations != solution.Iterations+1 {
+		t.Errorf("Evaluations = %d after %d iterations, want %d", solution.Evaluations, solution.Iterations, solution.Iterations+1)
+	}
+
+	registry := NewFunctionRegistry()
+	if err := registry.Register("cube", 1, func(args []float64) (float64, error) { return args[0] * args[0] * args[0], nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	program, err := CompileWithRegistry("cube(x) - 2 * x - 5", registry)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	numeric, err := Solve(program, "x", nil, SolveOptions{Method: MethodNewton, Initial: &one})
+	if err != nil {
+		t.Fatalf("Solve with a custom function failed: %v", err)
+	}
+	if numeric.Derivative != "" || math.Abs(numeric.Root-solution.Root) > 1e-9 {
+		t.Errorf("Solve with a custom function = %v with derivative %q, want %v without one", numeric.Root, numeric.Derivative, solution.Root)
+	}
+	if numeric.Evaluations != 3*numeric.Iterations+1 {
+		t.Errorf("Evaluations = %d after %d iterations, want %d with a central difference", numeric.Evaluations, numeric.Iterations, 3*numeric.Iterations+1)
+	}
+}
+
+// TestSolveFailures checks that results other than numbers and running out of steps are reported as SolveErrors
+func TestSolveFailures(t *testing.T) {
+	tests := []struct {
+		expression string
+		options    SolveOptions
+		reason     string
+	}{
+		{`x > 1 ? "a" : "b"`, SolveOptions{Method: MethodBisection, Bracket: []float64{0, 2}}, SolveNotANumber},
+		{"[x, x]", SolveOptions{Method: MethodBrent, Bracket: []float64{0, 2}}, SolveNotANumber},
+		{"sum(x, k, 1, 400000) - 1", SolveOptions{Method: MethodBisection, Bracket: []float64{-1, 1}}, SolveOutOfSteps},
+		{"sum(x, k, 1, 99999) - 1", SolveOptions{Method: MethodBisection, Bracket: []float64{-1, 1}}, SolveOutOfSteps},
+	}
+
+	for _, tt := range tests {
+		_, err := solve(t, tt.expression, tt.options)
+		var solveErr *SolveError
+		if !errors.As(err, &solveErr) || solveErr.Reason != tt.reason {
+			t.Errorf("Solve(%q) error = %v, want a %s", tt.expression, err, tt.reason)
+		}
+	}
+}
+
+// TestSolveDomainErrors checks that every failure to evaluate the expression at a point the method visits
+// is reported as a domain error, not only errors raised by functions
+func TestSolveDomainErrors(t *testing.T) {
+	zero := 0.0
+	tests := []struct {
+		expression string
+		options    SolveOptions
+	}{
+		{"ln(x)", SolveOptions{Method: MethodBisection, Bracket: []float64{-1, 2}}},
+		{"1 / x - 1", SolveOptions{Method: MethodBisection, Bracket: []float64{0, 2}}},
+		{"x % 0", SolveOptions{Method: MethodBrent, Bracket: []float64{-1, 2}}},
+		{"[1, 2][x]", SolveOptions{Method: MethodBisection, Bracket: []float64{0.5, 2}}},
+		{"1 / x - 1", SolveOptions{Method: MethodNewton, Initial: &zero}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			_, err = Solve(program, "x", NewEnvironment(nil), tt.options)
+			var solveErr *SolveError
+			if !errors.As(err, &solveErr) || solveErr.Reason != SolveDomainError {
+				t.Errorf("Solve(%q) error = %v, want a %s", tt.expression, err, SolveDomainError)
+			}
+		})
+	}
+}
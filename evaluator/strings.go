@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringBuiltins holds the string functions available to every expression
+// Positions and lengths count characters, not bytes
+var stringBuiltins = map[string]builtinFunction{
+	"len": stringFunction(1, 1, func(args []Value) (Value, error) {
+		s, err := asString(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		return Number(utf8.RuneCountInString(s)), nil
+	}),
+	"upper":      stringMap(strings.ToUpper),
+	"lower":      stringMap(strings.ToLower),
+	"trim":       stringMap(strings.TrimSpace),
+	"substr":     stringFunction(2, 3, substr),
+	"contains":   stringTest(strings.Contains),
+	"startsWith": stringTest(strings.HasPrefix),
+	"endsWith":   stringTest(strings.HasSuffix),
+	"replace": stringFunction(3, 3, func(args []Value) (Value, error) {
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return String(strings.ReplaceAll(strs[0], strs[1], strs[2])), nil
+	}),
+	"concat": stringFunction(0, Variadic, func(args []Value) (Value, error) {
+		var builder strings.Builder
+		for _, arg := range args {
+			builder.WriteString(arg.String())
+		}
+		return String(builder.String()), nil
+	}),
+	"toString": stringFunction(1, 1, func(args []Value) (Value, error) {
+		return String(args[0].String()), nil
+	}),
+	"toNumber": stringFunction(1, 1, func(args []Value) (Value, error) {
+		s, err := asString(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to a number", s)
+		}
+		return Number(n), nil
+	}),
+}
+
+// stringFunction creates a builtinFunction over values taking minArgs to maxArgs arguments
+func stringFunction(minArgs, maxArgs int, fn ValueFunction) builtinFunction {
+	return builtinFunction{minArgs: minArgs, maxArgs: maxArgs, valueFn: fn}
+}
+
+// stringMap adapts a string-to-string function
+func stringMap(fn func(s string) string) builtinFunction {
+	return stringFunction(1, 1, func(args []Value) (Value, error) {
+		s, err := asString(args[0], "argument 1")
+		if err != nil {
+			return nil, err
+		}
+		return String(fn(s)), nil
+	})
+}
+
+// stringTest adapts a predicate over two strings
+func stringTest(fn func(s, substr string) bool) builtinFunction {
+	return stringFunction(2, 2, func(args []Value) (Value, error) {
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return Bool(fn(strs[0], strs[1])), nil
+	})
+}
+
+// stringArgs converts every argument to a string
+func stringArgs(args []Value) ([]string, error) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, err := asString(arg, fmt.Sprintf("argument %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+// substr returns the characters of s from start, up to length characters or to the end
+func substr(args []Value) (Value, error) {
+	s, err := asString(args[0], "argument 1")
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+
+	start, err := asIndex(args[1], "argument 2")
+	if err != nil {
+		return nil, err
+	}
+	if start > len(runes) {
+		return nil, fmt.Errorf("start %d is beyond the end of the string", start)
+	}
+
+	end := len(runes)
+	if len(args) == 3 {
+		length, err := asIndex(args[2], "argument 3")
+		if err != nil {
+			return nil, err
+		}
+		// Compared with the characters left rather than added to start, which could overflow
+		if length < end-start {
+			end = start + length
+		}
+	}
+
+	return String(runes[start:end]), nil
+}
+
+// asIndex returns v as a non-negative integer
+func asIndex(v Value, context string) (int, error) {
+	n, err := asNumber(v, context)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n != float64(int(n)) {
+		return 0, fmt.Errorf("%s must be a non-negative integer", context)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,39 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStringFunctions(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: `len("héllo")`, want: "5"},
+		{expression: `substr("héllo", 1, 3)`, want: "éll"},
+		{expression: `substr("hello", 5)`, want: ""},
+		{expression: `substr("hello", 1, 9223372036854774784)`, want: "ello"},
+		{expression: `upper("abc")`, want: "ABC"},
+		{expression: `"a" + "b"`, err: "operator + cannot be applied to string and string"},
+		{expression: `replace("aaa", "a", "b")`, want: "bbb"},
+		{expression: `concat("a", 1, true)`, want: "a1true"},
+		{expression: `toNumber("1e3")`, want: "1000"},
+		{expression: `toNumber("x")`, err: `cannot convert "x" to a number`},
+		{expression: `substr("hello", 6)`, err: "start 6 is beyond the end of the string"},
+		{expression: `substr("hello", -1)`, err: "argument 2 must be a non-negative integer"},
+		{expression: `substr("hello", 1e30, 1)`, err: "argument 2 must be a non-negative integer"},
+	})
+}
+
+// TestSubstrLargeArguments checks that start and length near the integer limits are clamped rather than
+// overflowing, both when evaluating and when the call is folded at compile time
+func TestSubstrLargeArguments(t *testing.T) {
+	s := strings.Repeat("a", 2000)
+	env := NewEnvironment(map[string]Value{"s": String(s)})
+
+	checkOutcomes(t, nil, env, []outcome{
+		{expression: "substr(s, 1500, 9223372036854774784)", want: s[1500:]},
+		{expression: "substr(s, 0, 9223372036854774784)", want: s},
+		{expression: fmt.Sprintf("substr(%q, 1500, 9223372036854774784)", s), want: s[1500:]},
+		{expression: "substr(s, 9223372036854774784, 9223372036854774784)", err: "beyond the end of the string"},
+	})
+}
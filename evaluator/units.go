@@ -0,0 +1,350 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dimensions holds the exponents of the SI base quantities:
+// length, mass, time, electric current, temperature, amount of substance and luminous intensity
+type dimensions [7]int
+
+// Unit is a unit of measurement such as km, m/s^2 or kg*m^2
+// A Unit is immutable
+type Unit struct {
+	factors []unitFactor // named units and their powers, in the order they were written
+	scale   float64      // size of the unit in SI base units
+	dims    dimensions
+
+	// A temperature scale, degC or degF, has its zero away from absolute zero; ice is its reading at 0 degC
+	relative bool
+	ice      float64
+}
+
+// unitFactor is one named unit raised to a nonzero power within a Unit
+type unitFactor struct {
+	name  string
+	power int
+}
+
+// unitDefinition describes a named unit in the units table
+// Prefixable units also accept the SI prefixes, as in km or mA
+type unitDefinition struct {
+	scale      float64
+	dims       dimensions
+	prefixable bool
+}
+
+// units holds the named units known to the parser
+var units = map[string]unitDefinition{
+	// SI base units; kg is the gram with the kilo prefix
+	"m":   {1, dimensions{1, 0, 0, 0, 0, 0, 0}, true},
+	"g":   {1e-3, dimensions{0, 1, 0, 0, 0, 0, 0}, true},
+	"s":   {1, dimensions{0, 0, 1, 0, 0, 0, 0}, true},
+	"A":   {1, dimensions{0, 0, 0, 1, 0, 0, 0}, true},
+	"K":   {1, dimensions{0, 0, 0, 0, 1, 0, 0}, true},
+	"mol": {1, dimensions{0, 0, 0, 0, 0, 1, 0}, true},
+	"cd":  {1, dimensions{0, 0, 0, 0, 0, 0, 1}, true},
+
+	// SI derived units
+	"Hz":  {1, dimensions{0, 0, -1, 0, 0, 0, 0}, true},
+	"N":   {1, dimensions{1, 1, -2, 0, 0, 0, 0}, true},
+	"Pa":  {1, dimensions{-1, 1, -2, 0, 0, 0, 0}, true},
+	"J":   {1, dimensions{2, 1, -2, 0, 0, 0, 0}, true},
+	"W":   {1, dimensions{2, 1, -3, 0, 0, 0, 0}, true},
+	"C":   {1, dimensions{0, 0, 1, 1, 0, 0, 0}, true},
+	"V":   {1, dimensions{2, 1, -3, -1, 0, 0, 0}, true},
+	"ohm": {1, dimensions{2, 1, -3, -2, 0, 0, 0}, true},
+	"S":   {1, dimensions{-2, -1, 3, 2, 0, 0, 0}, true},
+	"F":   {1, dimensions{-2, -1, 4, 2, 0, 0, 0}, true},
+	"Wb":  {1, dimensions{2, 1, -2, -1, 0, 0, 0}, true},
+	"T":   {1, dimensions{0, 1, -2, -1, 0, 0, 0}, true},
+	"H":   {1, dimensions{2, 1, -2, -2, 0, 0, 0}, true},
+
+	// Units accepted for use with the SI
+	"min": {60, dimensions{0, 0, 1, 0, 0, 0, 0}, false},
+	"h":   {3600, dimensions{0, 0, 1, 0, 0, 0, 0}, false},
+	"day": {86400, dimensions{0, 0, 1, 0, 0, 0, 0}, false},
+	"L":   {1e-3, dimensions{3, 0, 0, 0, 0, 0, 0}, true},
+	"l":   {1e-3, dimensions{3, 0, 0, 0, 0, 0, 0}, true},
+	"ha":  {1e4, dimensions{2, 0, 0, 0, 0, 0, 0}, false},
+	"bar": {1e5, dimensions{-1, 1, -2, 0, 0, 0, 0}, true},
+	"atm": {101325, dimensions{-1, 1, -2, 0, 0, 0, 0}, false},
+	"eV":  {1.602176634e-19, dimensions{2, 1, -2, 0, 0, 0, 0}, true},
+	"Wh":  {3600, dimensions{2, 1, -2, 0, 0, 0, 0}, true},
+	"cal": {4.184, dimensions{2, 1, -2, 0, 0, 0, 0}, true},
+
+	// Imperial and US customary units; the inch is spelled out because "in" converts units
+	"inch": {0.0254, dimensions{1, 0, 0, 0, 0, 0, 0}, false},
+	"ft":   {0.3048, dimensions{1, 0, 0, 0, 0, 0, 0}, false},
+	"yd":   {0.9144, dimensions{1, 0, 0, 0, 0, 0, 0}, false},
+	"mi":   {1609.344, dimensions{1, 0, 0, 0, 0, 0, 0}, false},
+	"acre": {4046.8564224, dimensions{2, 0, 0, 0, 0, 0, 0}, false},
+	"gal":  {3.785411784e-3, dimensions{3, 0, 0, 0, 0, 0, 0}, false},
+	"oz":   {0.028349523125, dimensions{0, 1, 0, 0, 0, 0, 0}, false},
+	"lb":   {0.45359237, dimensions{0, 1, 0, 0, 0, 0, 0}, false},
+	"lbf":  {4.4482216152605, dimensions{1, 1, -2, 0, 0, 0, 0}, false},
+	"psi":  {6894.757293168361, dimensions{-1, 1, -2, 0, 0, 0, 0}, false},
+	"mph":  {0.44704, dimensions{1, 0, -1, 0, 0, 0, 0}, false},
+}
+
+// temperatureScales holds the temperature units whose zero is not absolute zero, with the size of a degree
+// in kelvin and their reading at the freezing point of water
+// A reading such as 20 degC can be converted and compared, but not combined with other units or used in
+// arithmetic, where it would be unclear whether it means a temperature or a temperature difference
+var temperatureScales = map[string]struct{ scale, ice float64 }{
+	"degC": {1, 0},
+	"degF": {5.0 / 9, 32},
+}
+
+// iceKelvin is the freezing point of water, 0 degC, in kelvin
+const iceKelvin = 273.15
+
+// prefixes holds the SI prefixes accepted by prefixable units
+var prefixes = map[string]float64{
+	"Y": 1e24, "Z": 1e21, "E": 1e18, "P": 1e15, "T": 1e12, "G": 1e9, "M": 1e6, "k": 1e3, "h": 1e2, "da": 1e1,
+	"d": 1e-1, "c": 1e-2, "m": 1e-3, "u": 1e-6, "µ": 1e-6, "n": 1e-9, "p": 1e-12, "f": 1e-15, "a": 1e-18, "z": 1e-21, "y": 1e-24,
+}
+
+// isUnitName reports whether name is a unit from the units table, with or without an SI prefix
+func isUnitName(name string) bool {
+	_, ok := lookupUnit(name)
+	return ok
+}
+
+// lookupUnit returns the unit spelled by name
+// Names in the table win over prefixed names, so min is a minute rather than a milli-inch
+func lookupUnit(name string) (Unit, bool) {
+	if definition, ok := units[name]; ok {
+		return definition.unit(name, 1), true
+	}
+	if scale, ok := temperatureScales[name]; ok {
+		return Unit{
+			factors:  []unitFactor{{name: name, power: 1}},
+			scale:    scale.scale,
+			dims:     dimensions{0, 0, 0, 0, 1, 0, 0},
+			relative: true,
+			ice:      scale.ice,
+		}, true
+	}
+	for prefix, scale := range prefixes {
+		definition, ok := units[strings.TrimPrefix(name, prefix)]
+		if ok && definition.prefixable && strings.HasPrefix(name, prefix) {
+			return definition.unit(name, scale), true
+		}
+	}
+	return Unit{}, false
+}
+
+// unit creates the Unit for a table entry written as name
+func (d unitDefinition) unit(name string, prefix float64) Unit {
+	return Unit{
+		factors: []unitFactor{{name: name, power: 1}},
+		scale:   d.scale * prefix,
+		dims:    d.dims,
+	}
+}
+
+// parseUnit parses a compound unit such as km, m/s^2 or kg*m^2*s^-2
+// Each '/' divides by the single unit that follows it, so J/kg/K is J/(kg*K)
+func parseUnit(text string) (Unit, error) {
+	result := Unit{scale: 1}
+	sign := 1
+	for len(text) > 0 {
+		end := strings.IndexAny(text, "*/")
+		if end < 0 {
+			end = len(text)
+		}
+
+		name, power := text[:end], 1
+		if caret := strings.IndexByte(name, '^'); caret >= 0 {
+			exponent, err := strconv.Atoi(name[caret+1:])
+			if err != nil || exponent == 0 {
+				return Unit{}, fmt.Errorf("invalid unit exponent: %s", name)
+			}
+			name, power = name[:caret], exponent
+		}
+
+		unit, ok := lookupUnit(name)
+		if !ok {
+			return Unit{}, fmt.Errorf("unknown unit: %s", name)
+		}
+		if unit.relative {
+			if len(result.factors) > 0 || end < len(text) || power != 1 {
+				return Unit{}, fmt.Errorf("%s cannot be combined with other units; use K for temperature differences", name)
+			}
+			return unit, nil
+		}
+		result = result.multiply(unit.pow(sign * power))
+
+		if end == len(text) {
+			break
+		}
+		sign = 1
+		if text[end] == '/' {
+			sign = -1
+		}
+		text = text[end+1:]
+		if text == "" {
+			return Unit{}, fmt.Errorf("unit ends with an operator")
+		}
+	}
+	return result, nil
+}
+
+// String returns the canonical spelling of the unit: the factors with positive powers
+// joined by '*', followed by each factor with a negative power after a '/',
+// e.g. kg*m/s^2; a unit without positive powers is written with negative exponents, e.g. s^-1
+func (u Unit) String() string {
+	var numerator, denominator []string
+	for _, factor := range u.factors {
+		if factor.power > 0 {
+			numerator = append(numerator, factor.format(factor.power))
+		} else {
+			denominator = append(denominator, factor.format(-factor.power))
+		}
+	}
+
+	if len(numerator) == 0 {
+		for _, factor := range u.factors {
+			numerator = append(numerator, factor.format(factor.power))
+		}
+		return strings.Join(numerator, "*")
+	}
+	if len(denominator) == 0 {
+		return strings.Join(numerator, "*")
+	}
+	return strings.Join(numerator, "*") + "/" + strings.Join(denominator, "/")
+}
+
+// format writes the factor raised to power, omitting a power of 1
+func (f unitFactor) format(power int) string {
+	if power == 1 {
+		return f.name
+	}
+	return f.name + "^" + strconv.Itoa(power)
+}
+
+// dimensionless reports whether the unit's dimensions all cancel out
+func (u Unit) dimensionless() bool {
+	return u.dims == dimensions{}
+}
+
+// compatible reports whether quantities in u and v measure the same thing
+func (u Unit) compatible(v Unit) bool {
+	return u.dims == v.dims
+}
+
+// describe names the unit for error messages
+func (u Unit) describe() string {
+	if len(u.factors) == 0 {
+		return "a plain number"
+	}
+	return u.String()
+}
+
+// multiply returns the product of two units, combining powers of the same named unit
+func (u Unit) multiply(v Unit) Unit {
+	result := Unit{
+		factors: make([]unitFactor, 0, len(u.factors)+len(v.factors)),
+		scale:   u.scale * v.scale,
+	}
+	for i := range result.dims {
+		result.dims[i] = u.dims[i] + v.dims[i]
+	}
+
+	result.factors = append(result.factors, u.factors...)
+	for _, factor := range v.factors {
+		merged := false
+		for i := range result.factors {
+			if result.factors[i].name == factor.name {
+				result.factors[i].power += factor.power
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			result.factors = append(result.factors, factor)
+		}
+	}
+
+	// Drop factors that cancelled, as in m*s/s
+	kept := result.factors[:0]
+	for _, factor := range result.factors {
+		if factor.power != 0 {
+			kept = append(kept, factor)
+		}
+	}
+	result.factors = kept
+	return result
+}
+
+// alike rewrites each factor of v that measures the same thing as a differently named factor of u
+// in that factor's unit, so that km becomes m alongside m and the product m*km comes out as m^2
+// It returns the rewritten unit and the number by which a magnitude in v must be multiplied to match it
+func (u Unit) alike(v Unit) (Unit, float64) {
+	rescale := 1.0
+	result := Unit{factors: make([]unitFactor, len(v.factors)), dims: v.dims}
+	for i, factor := range v.factors {
+		result.factors[i] = factor
+		if u.hasFactor(factor.name) {
+			continue
+		}
+		named, _ := lookupUnit(factor.name)
+		for _, other := range u.factors {
+			like, _ := lookupUnit(other.name)
+			if like.dims == named.dims {
+				rescale *= math.Pow(named.scale/like.scale, float64(factor.power))
+				result.factors[i].name = other.name
+				break
+			}
+		}
+	}
+	result.scale = v.scale / rescale
+	return result, rescale
+}
+
+// hasFactor reports whether the unit has a factor with the given name
+func (u Unit) hasFactor(name string) bool {
+	for _, factor := range u.factors {
+		if factor.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pow returns the unit raised to an integer power
+func (u Unit) pow(n int) Unit {
+	result := Unit{
+		factors: make([]unitFactor, len(u.factors)),
+		scale:   math.Pow(u.scale, float64(n)),
+	}
+	for i, factor := range u.factors {
+		result.factors[i] = unitFactor{name: factor.name, power: factor.power * n}
+	}
+	for i, d := range u.dims {
+		result.dims[i] = d * n
+	}
+	return result
+}
+
+// root returns the nth root of the unit, or false when a power does not divide evenly, as in sqrt(m^3)
+func (u Unit) root(n int) (Unit, bool) {
+	result := Unit{
+		factors: make([]unitFactor, len(u.factors)),
+		scale:   math.Pow(u.scale, 1/float64(n)),
+	}
+	for i, factor := range u.factors {
+		if factor.power%n != 0 {
+			return Unit{}, false
+		}
+		result.factors[i] = unitFactor{name: factor.name, power: factor.power / n}
+	}
+	for i, d := range u.dims {
+		result.dims[i] = d / n
+	}
+	return result, true
+}
@@ -0,0 +1,160 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemperatureScales(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"20 degC to degF", "68 degF"},
+		{"98.6 degF in degC", "37 degC"},
+		{"-40 degC to degF", "-40 degF"},
+		{"37 degC to K", "310.15 K"},
+		{"310.15 K to degC", "37 degC"},
+		{"212 degF to K", "373.15 K"},
+		{"20 degC == 293.15 K", "true"},
+		{"20 degC < 70 degF", "true"},
+		{"(30 degC to K) - (20 degC to K)", "10 K"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			got, err := program.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Evaluate(%q) = %s, want %s", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTemperatureScaleErrors checks that readings on a scale with an offset zero are not used
+// where it is unclear whether they are temperatures or temperature differences
+func TestTemperatureScaleErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"20 degC + 5 degC", "operator + cannot be applied to temperatures in degC; convert them to K first"},
+		{"20 degC - 10 K", "operator - cannot be applied to temperatures in degC; convert them to K first"},
+		{"2 * 70 degF", "operator * cannot be applied to temperatures in degF; convert them to K first"},
+		{"10 J/degC", "degC cannot be combined with other units; use K for temperature differences"},
+		{"10 degC^2", "degC cannot be combined with other units; use K for temperature differences"},
+		{"5 kJ to degC", "cannot convert kJ to degC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err == nil {
+				_, err = program.Evaluate(nil)
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Evaluate(%q) error = %v, want %q", tt.expression, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationAndQuantityLiterals(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       Value
+	}{
+		{"15min", Duration(15 * time.Minute)},
+		{"1h30m", Duration(90 * time.Minute)},
+		{"2m30s", Duration(150 * time.Second)},
+		{"5ms", Duration(5 * time.Millisecond)},
+		{"15 min to s", Quantity{magnitude: 900, unit: mustParseUnit(t, "s")}},
+		{"3 m to inch", Quantity{magnitude: 3 / 0.0254, unit: mustParseUnit(t, "inch")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expression, err)
+			}
+			got, err := program.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.expression, err)
+			}
+			if got.Kind() != tt.want.Kind() || got.String() != tt.want.String() {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustParseUnit(t *testing.T, text string) Unit {
+	t.Helper()
+	unit, err := parseUnit(text)
+	if err != nil {
+		t.Fatalf("parseUnit(%q) failed: %v", text, err)
+	}
+	return unit
+}
+
+// TestLikeUnitsCombine checks that products and quotients express units of the same dimension
+// in the unit of the left operand rather than keeping both, as in m*km
+func TestLikeUnitsCombine(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "1 m * 1 km", want: "1000 m^2"},
+		{expression: "1 km * 1 m", want: "0.001 km^2"},
+		{expression: "3 ft * 2 m", want: "19.685039370078737 ft^2"},
+		{expression: "6 m^2 / 2 cm", want: "300 m"},
+		{expression: "2 km / 500 m", want: "4"},
+		{expression: "60 kg * 9.81 m/s^2", want: "588.6 kg*m/s^2"},
+		{expression: "1 m/s * 1 h", want: "3600 m"},
+		{expression: "10 km / 2 h", want: "5 km/h"},
+		{expression: "90 min * 4 km/h", want: "6 km"},
+	})
+}
+
+// TestTimeUnitsAreDurations checks that a number with a single unit of time is a duration whether
+// or not a space separates them, so it works with dates as well as with other quantities
+func TestTimeUnitsAreDurations(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "1 ms == 1ms", want: "true"},
+		{expression: "15 min + 15min", want: "PT30M"},
+		{expression: "1 day == 1d", want: "true"},
+		{expression: `date("2026-10-16") + 4 h`, want: "2026-10-16T04:00:00Z"},
+		{expression: "2 h to min", want: "120 min"},
+		{expression: "1 h > 1 ks", want: "true"},
+		{expression: "5 s * 2 m/s", want: "10 m"},
+		{expression: "1 h + 1 m", err: "incompatible units for operator +: h and m"},
+		{expression: "1e12 s", err: "duration out of range"},
+	})
+}
+
+// TestAggregatesOverQuantities checks that min, max and the list aggregates accept quantities
+// of one dimension and answer in the unit of the first
+func TestAggregatesOverQuantities(t *testing.T) {
+	checkOutcomes(t, nil, nil, []outcome{
+		{expression: "max(1 m, 2 m)", want: "2 m"},
+		{expression: "min(1 m, 90 cm)", want: "0.9 m"},
+		{expression: "max(20 degC, 70 degF)", want: "21.11111111111111 degC"},
+		{expression: "max(2 h, 30 min)", want: "PT2H"},
+		{expression: "sum([1 m, 2 m])", want: "3 m"},
+		{expression: "sum(1 m, 20 cm)", want: "1.2 m"},
+		{expression: "avg([1 kg, 500 g])", want: "0.75 kg"},
+		{expression: "median([1 m, 3 m, 2 m])", want: "2 m"},
+		{expression: "sum([1 h, 30 min])", want: "PT1H30M"},
+		{expression: "max(1 m, 2)", err: "incompatible units: m and a plain number"},
+		{expression: "max(1 m, 1 h)", err: "incompatible units: m and h"},
+		{expression: "sum([1 m, 2 kg])", err: "incompatible units: m and kg"},
+		{expression: `sum([1 m, "a"])`, err: "element 2 expects a quantity, got string"},
+		{expression: "sum([20 degC, 5 degC])", err: "temperatures in degC cannot be aggregated; convert them to K first"},
+	})
+}
@@ -0,0 +1,222 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Kind identifies the type of a Value
+type Kind int
+
+const (
+	// KindNumber is a 64-bit floating point number
+	KindNumber Kind = iota
+	// KindBool is a boolean
+	KindBool
+	// KindString is a string of Unicode characters
+	KindString
+	// KindDecimal is an exact base-10 number, produced in decimal mode
+	KindDecimal
+	// KindRational is an exact fraction, produced in rational mode
+	KindRational
+	// KindInteger is an arbitrary-precision integer, produced in bigint mode
+	KindInteger
+	// KindComplex is a complex number, produced in complex mode
+	KindComplex
+	// KindQuantity is a number with a unit of measurement, such as 5 km
+	KindQuantity
+	// KindDate is an instant in time, such as date("2026-10-16")
+	KindDate
+	// KindDuration is a length of time, such as 30d
+	KindDuration
+	// KindList is a list of values, such as [1, 2, 3]
+	KindList
+	// KindFunction is a lambda, such as x -> x * 2
+	KindFunction
+)
+
+// String returns the name of the kind as reported to API clients
+func (k Kind) String() string {
+	switch k {
+	case KindNumber:
+		return "number"
+	case KindBool:
+		return "boolean"
+	case KindString:
+		return "string"
+	case KindDecimal:
+		return "decimal"
+	case KindRational:
+		return "rational"
+	case KindInteger:
+		return "integer"
+	case KindComplex:
+		return "complex"
+	case KindQuantity:
+		return "quantity"
+	case KindDate:
+		return "date"
+	case KindDuration:
+		return "duration"
+	case KindList:
+		return "list"
+	case KindFunction:
+		return "function"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Value is the result of evaluating an expression
+// Every Value marshals to JSON in the form returned by the API
+type Value interface {
+	Kind() Kind
+	String() string
+}
+
+// Number is a numeric Value
+type Number float64
+
+// Bool is a boolean Value
+type Bool bool
+
+// String is a string Value
+type String string
+
+// Kind implements the Value interface for Number
+func (n Number) Kind() Kind {
+	return KindNumber
+}
+
+// String implements the Value interface for Number
+func (n Number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+// MarshalJSON encodes the number as a JSON number, or as one of the strings "Infinity", "-Infinity"
+// and "NaN", which JSON has no numbers for
+func (n Number) MarshalJSON() ([]byte, error) {
+	switch x := float64(n); {
+	case math.IsInf(x, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(x, -1):
+		return []byte(`"-Infinity"`), nil
+	case math.IsNaN(x):
+		return []byte(`"NaN"`), nil
+	}
+	return json.Marshal(float64(n))
+}
+
+// Kind implements the Value interface for Bool
+func (b Bool) Kind() Kind {
+	return KindBool
+}
+
+// String implements the Value interface for Bool
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+// Kind implements the Value interface for String
+func (s String) Kind() Kind {
+	return KindString
+}
+
+// String implements the Value interface for String
+func (s String) String() string {
+	return string(s)
+}
+
+// TypeError reports an operation applied to values of the wrong kind, such as true + 1
+type TypeError struct {
+	Message string
+}
+
+// Error implements the error interface for TypeError
+func (e *TypeError) Error() string {
+	return "type error: " + e.Message
+}
+
+// newTypeError creates a TypeError with a formatted message
+func newTypeError(format string, args ...interface{}) *TypeError {
+	return &TypeError{Message: fmt.Sprintf(format, args...)}
+}
+
+// ValueOf converts a Go value, such as one decoded from JSON, into a Value
+// Arrays become lists, converting each element in turn, and a json.Number becomes the nearest Number
+func ValueOf(x interface{}) (Value, error) {
+	switch v := x.(type) {
+	case Value:
+		return v, nil
+	case float64:
+		return Number(v), nil
+	case float32:
+		return Number(v), nil
+	case int:
+		return Number(v), nil
+	case int64:
+		return Number(v), nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid number: %s", v)
+		}
+		return Number(f), nil
+	case bool:
+		return Bool(v), nil
+	case string:
+		return String(v), nil
+	case []interface{}:
+		list := make(List, len(v))
+		for i, element := range v {
+			value, err := ValueOf(element)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i+1, err)
+			}
+			list[i] = value
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", x)
+	}
+}
+
+// asNumber returns v as a float64 or a TypeError naming what needed a number
+// Decimals, rationals and integers are converted to the nearest float64;
+// complex numbers are accepted only when they have no imaginary part
+func asNumber(v Value, context string) (float64, error) {
+	switch n := v.(type) {
+	case Number:
+		return float64(n), nil
+	case Decimal:
+		return n.float(), nil
+	case Rational:
+		return n.float(), nil
+	case Integer:
+		return n.float(), nil
+	case Complex:
+		if imag(n) == 0 {
+			return real(n), nil
+		}
+		return 0, newTypeError("%s expects a real number, got %s", context, n)
+	}
+	return 0, newTypeError("%s expects a number, got %s", context, v.Kind())
+}
+
+// asBool returns v as a bool or a TypeError naming what needed a boolean
+func asBool(v Value, context string) (bool, error) {
+	if b, ok := v.(Bool); ok {
+		return bool(b), nil
+	}
+	return false, newTypeError("%s expects a boolean, got %s", context, v.Kind())
+}
+
+// asString returns v as a string or a TypeError naming what needed a string
+func asString(v Value, context string) (string, error) {
+	if s, ok := v.(String); ok {
+		return string(s), nil
+	}
+	return "", newTypeError("%s expects a string, got %s", context, v.Kind())
+}
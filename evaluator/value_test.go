@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNumberMarshalJSON(t *testing.T) {
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{Number(3.5), `3.5`},
+		{Number(-2), `-2`},
+		{Number(1e21), `1e+21`},
+		{Number(math.Inf(1)), `"Infinity"`},
+		{Number(math.Inf(-1)), `"-Infinity"`},
+		{Number(math.NaN()), `"NaN"`},
+		{List{Number(1), Number(math.Inf(1))}, `[1,"Infinity"]`},
+		{Complex(complex(math.Inf(1), 1)), `{"re":"Infinity","im":1}`},
+	}
+
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.value)
+		if err != nil {
+			t.Errorf("json.Marshal(%v) failed: %v", tt.value, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("json.Marshal(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package evaluator
+
+import (
+	"fmt"
+)
+
+// opcode identifies a VM instruction
+type opcode uint8
+
+const (
+	opConst      opcode = iota // push consts[arg]
+	opLoad                     // push the variable names[arg]
+	opNeg                      // negate the top of the stack
+	opPos                      // unary plus on the top of the stack
+	opNot                      // logical not of the top of the stack
+	opBinary                   // pop right and left, push operators[arg](left, right)
+	opCall                     // pop argc arguments, push functions[arg](args...)
+	opAdd                      // built-in '+', falling back to operators[arg] for non-numbers
+	opSub                      // built-in '-', likewise
+	opMul                      // built-in '*', likewise
+	opDiv                      // built-in '/', likewise
+	opAnd                      // if the top is false jump to arg, otherwise pop it
+	opOr                       // if the top is true jump to arg, otherwise pop it
+	opCheckBool                // fail unless the top is a boolean; argc is the opAnd or opOr it follows
+	opJumpUnless               // pop the condition and jump to arg if it is false
+	opJump                     // jump to arg
+	opConvert                  // convert the top of the stack to units[arg]
+	opNow                      // push the time now() returns
+	opList                     // pop argc elements, push them as a list
+	opIndex                    // pop the index and the list, push the element
+	opClosure                  // push lambdas[arg] closed over the environment
+	opIterate                  // push the value of iterations[arg], a sum, prod or integrate
+)
+
+// inlineOperators maps the built-in operators the VM executes without a function call
+var inlineOperators = map[string]opcode{
+	"+": opAdd,
+	"-": opSub,
+	"*": opMul,
+	"/": opDiv,
+}
+
+// smallStack is the stack size the VM can run in without allocating
+const smallStack = 32
+
+// instruction is a single VM instruction
+type instruction struct {
+	op   opcode
+	arg  int
+	argc int
+}
+
+// bytecode is an expression compiled for the stack VM
+// Operators, functions and units are resolved at compile time, so running the
+// bytecode involves no map lookups other than for variables
+type bytecode struct {
+	code       []instruction
+	consts     []Value
+	names      []string
+	operators  []*Operator
+	functions  []*builtinFunction
+	units      []Unit
+	lambdas    []*LambdaNode
+	iterations []*IterationNode
+	maxStack   int
+}
+
+// compiler translates an expression tree into bytecode
+type compiler struct {
+	out   *bytecode
+	depth int
+	names map[string]int
+}
+
+// compileBytecode compiles root into bytecode
+// It fails for node types the VM does not know, such as ExprNode
+// implementations from outside this package
+func compileBytecode(root ExprNode) (*bytecode, error) {
+	c := &compiler{out: &bytecode{}, names: make(map[string]int)}
+	if err := c.compile(root); err != nil {
+		return nil, err
+	}
+	return c.out, nil
+}
+
+// compile emits the instructions for node in post-order, so operands are
+// evaluated left to right exactly as the tree walker does
+func (c *compiler) compile(node ExprNode) error {
+	switch n := node.(type) {
+	case *ValueNode:
+		c.out.consts = append(c.out.consts, n.Value)
+		c.emit(instruction{op: opConst, arg: len(c.out.consts) - 1}, 1)
+
+	case *VariableNode:
+		// Each distinct name gets one slot, so a variable used many times is looked up once per run
+		slot, ok := c.names[n.Name]
+		if !ok {
+			slot = len(c.out.names)
+			c.names[n.Name] = slot
+			c.out.names = append(c.out.names, n.Name)
+		}
+		c.emit(instruction{op: opLoad, arg: slot}, 1)
+
+	case *UnaryOpNode:
+		if err := c.compile(n.Operand); err != nil {
+			return err
+		}
+		switch n.Operator {
+		case "-":
+			c.emit(instruction{op: opNeg}, 0)
+		case "+":
+			c.emit(instruction{op: opPos}, 0)
+		case "!":
+			c.emit(instruction{op: opNot}, 0)
+		default:
+			return fmt.Errorf("unknown unary operator: %s", n.Operator)
+		}
+
+	case *BinaryOpNode:
+		op, err := n.resolve()
+		if err != nil {
+			return err
+		}
+		if err := c.compile(n.Left); err != nil {
+			return err
+		}
+		if err := c.compile(n.Right); err != nil {
+			return err
+		}
+		code := opBinary
+		if inline, ok := inlineOperators[op.Symbol]; ok && op.inline {
+			code = inline
+		}
+		c.out.operators = append(c.out.operators, op)
+		c.emit(instruction{op: code, arg: len(c.out.operators) - 1}, -1)
+
+	case *FunctionCallNode:
+		function, err := n.resolve()
+		if err != nil {
+			return err
+		}
+		for _, arg := range n.Args {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		c.out.functions = append(c.out.functions, function)
+		c.emit(instruction{op: opCall, arg: len(c.out.functions) - 1, argc: len(n.Args)}, 1-len(n.Args))
+
+	case *ConversionNode:
+		unit, err := n.resolve()
+		if err != nil {
+			return err
+		}
+		if err := c.compile(n.Operand); err != nil {
+			return err
+		}
+		c.out.units = append(c.out.units, unit)
+		c.emit(instruction{op: opConvert, arg: len(c.out.units) - 1}, 0)
+
+	case *NowNode:
+		c.emit(instruction{op: opNow}, 1)
+
+	case *ListNode:
+		for _, element := range n.Elements {
+			if err := c.compile(element); err != nil {
+				return err
+			}
+		}
+		c.emit(instruction{op: opList, argc: len(n.Elements)}, 1-len(n.Elements))
+
+	case *IndexNode:
+		if err := c.compile(n.Target); err != nil {
+			return err
+		}
+		if err := c.compile(n.Index); err != nil {
+			return err
+		}
+		c.emit(instruction{op: opIndex}, -1)
+
+	case *LambdaNode:
+		// The body runs by walking the tree each time the lambda is called
+		c.out.lambdas = append(c.out.lambdas, n)
+		c.emit(instruction{op: opClosure, arg: len(c.out.lambdas) - 1}, 1)
+
+	case *IterationNode:
+		// Like a lambda body, the form runs by walking the tree, evaluating its body once per step
+		c.out.iterations = append(c.out.iterations, n)
+		c.emit(instruction{op: opIterate, arg: len(c.out.iterations) - 1}, 1)
+
+	case *LogicalOpNode:
+		code := opAnd
+		if n.Operator == "||" {
+			code = opOr
+		} else if n.Operator != "&&" {
+			return fmt.Errorf("unknown logical operator: %s", n.Operator)
+		}
+		if err := c.compile(n.Left); err != nil {
+			return err
+		}
+		jump := c.emit(instruction{op: code}, -1)
+		if err := c.compile(n.Right); err != nil {
+			return err
+		}
+		c.emit(instruction{op: opCheckBool, argc: int(code)}, 0)
+		c.patch(jump)
+
+	case *ConditionalNode:
+		if err := c.compile(n.Condition); err != nil {
+			return err
+		}
+		jumpElse := c.emit(instruction{op: opJumpUnless}, -1)
+		if err := c.compile(n.Then); err != nil {
+			return err
+		}
+		// Only one branch runs, so the else branch starts at the depth the then branch started at
+		jumpEnd := c.emit(instruction{op: opJump}, -1)
+		c.patch(jumpElse)
+		if err := c.compile(n.Else); err != nil {
+			return err
+		}
+		c.patch(jumpEnd)
+
+	default:
+		return fmt.Errorf("cannot compile %T to bytecode", node)
+	}
+
+	return nil
+}
+
+// emit appends an instruction, tracks the stack depth it leaves behind
+// and returns the instruction's index
+func (c *compiler) emit(in instruction, stackEffect int) int {
+	c.out.code = append(c.out.code, in)
+	c.depth += stackEffect
+	if c.depth > c.out.maxStack {
+		c.out.maxStack = c.depth
+	}
+	return len(c.out.code) - 1
+}
+
+// patch points the jump at index to the next instruction to be emitted
+func (c *compiler) patch(index int) {
+	c.out.code[index].arg = len(c.out.code)
+}
+
+// logicalOperator returns the symbol of the opAnd or opOr opcode
+func logicalOperator(code opcode) string {
+	if code == opOr {
+		return "||"
+	}
+	return "&&"
+}
+
+// run executes the bytecode against env
+// Results and errors are identical to evaluating the original tree
+func (b *bytecode) run(env *Environment) (Value, error) {
+	var buffer [smallStack]Value
+	stack := buffer[:0]
+	if b.maxStack > smallStack {
+		stack = make([]Value, 0, b.maxStack)
+	}
+
+	var variableBuffer [smallStack]Value
+	variables := variableBuffer[:]
+	if len(b.names) > smallStack {
+		variables = make([]Value, len(b.names))
+	}
+
+	for pc := 0; pc < len(b.code); pc++ {
+		in := b.code[pc]
+
+		switch in.op {
+		case opConst:
+			stack = append(stack, b.consts[in.arg])
+
+		case opLoad:
+			if variables[in.arg] == nil {
+				value, err := lookupVariable(env, b.names[in.arg])
+				if err != nil {
+					return nil, err
+				}
+				variables[in.arg] = value
+			}
+			stack = append(stack, variables[in.arg])
+
+		case opNeg, opPos, opNot:
+			top := len(stack) - 1
+			if n, ok := stack[top].(Number); ok && in.op != opNot {
+				if in.op == opNeg {
+					stack[top] = -n
+				}
+				break
+			}
+			value, err := applyUnary(unaryOperator(in.op), stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = value
+
+		case opAdd, opSub, opMul, opDiv:
+			top := len(stack) - 1
+			l, lok := stack[top-1].(Number)
+			r, rok := stack[top].(Number)
+			if !lok || !rok {
+				value, err := b.operators[in.arg].apply(stack[top-1], stack[top])
+				if err != nil {
+					return nil, err
+				}
+				stack = stack[:top]
+				stack[top-1] = value
+				break
+			}
+			switch in.op {
+			case opAdd:
+				stack[top-1] = l + r
+			case opSub:
+				stack[top-1] = l - r
+			case opMul:
+				stack[top-1] = l * r
+			case opDiv:
+				if r == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				stack[top-1] = l / r
+			}
+			stack = stack[:top]
+
+		case opBinary:
+			top := len(stack) - 1
+			value, err := b.operators[in.arg].apply(stack[top-1], stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack = stack[:top]
+			stack[top-1] = value
+
+		case opCall:
+			base := len(stack) - in.argc
+			// Functions may keep their arguments, so they get a copy rather than a view of the stack
+			args := make([]Value, in.argc)
+			copy(args, stack[base:])
+			value, err := b.functions[in.arg].call(args)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack[:base], value)
+
+		case opAnd, opOr:
+			decided, err := shortCircuits(logicalOperator(in.op), stack[len(stack)-1])
+			if err != nil {
+				return nil, err
+			}
+			if decided {
+				pc = in.arg - 1
+				break
+			}
+			stack = stack[:len(stack)-1]
+
+		case opCheckBool:
+			if _, err := asBool(stack[len(stack)-1], "operator "+logicalOperator(opcode(in.argc))); err != nil {
+				return nil, err
+			}
+
+		case opJumpUnless:
+			top := len(stack) - 1
+			condition, err := asBool(stack[top], "condition")
+			if err != nil {
+				return nil, err
+			}
+			stack = stack[:top]
+			if !condition {
+				pc = in.arg - 1
+			}
+
+		case opJump:
+			pc = in.arg - 1
+
+		case opConvert:
+			top := len(stack) - 1
+			value, err := convertUnit(stack[top], b.units[in.arg])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = value
+
+		case opNow:
+			stack = append(stack, Date{t: env.Now()})
+
+		case opList:
+			base := len(stack) - in.argc
+			list := make(List, in.argc)
+			copy(list, stack[base:])
+			stack = append(stack[:base], list)
+
+		case opIndex:
+			top := len(stack) - 1
+			value, err := index(stack[top-1], stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack = stack[:top]
+			stack[top-1] = value
+
+		case opClosure:
+			lambda := b.lambdas[in.arg]
+			stack = append(stack, Lambda{params: lambda.Params, body: lambda.Body, env: env})
+
+		case opIterate:
+			value, err := b.iterations[in.arg].Evaluate(env)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, value)
+		}
+	}
+
+	return stack[0], nil
+}
+
+// unaryOperator returns the symbol of a unary opcode
+func unaryOperator(code opcode) string {
+	switch code {
+	case opNeg:
+		return "-"
+	case opPos:
+		return "+"
+	default:
+		return "!"
+	}
+}
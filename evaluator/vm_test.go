@@ -0,0 +1,225 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// operandPool returns the values bound to the operands of the differential test:
+// numbers including edge cases, and one value of every other kind
+func operandPool(t *testing.T) []Value {
+	pool := []Value{Number(0), Number(1), Number(-2.5), Number(3), Number(0.5), Number(math.NaN()), Bool(true), String("abc")}
+	for _, expression := range []string{
+		"[1, 2, 3]",
+		"[[1, 2], [3, 4]]",
+		"5 km",
+		`date("2024-01-31T10:00:00Z")`,
+		`duration("P1DT2H")`,
+		"x -> x * 2",
+	} {
+		program, err := Compile(expression)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expression, err)
+		}
+		value, err := program.Evaluate(nil)
+		if err != nil {
+			t.Fatalf("Evaluate(%q) failed: %v", expression, err)
+		}
+		pool = append(pool, value)
+	}
+	return pool
+}
+
+// sameResult reports whether two evaluations agree: bit-identical numbers, otherwise equal
+// kinds and renderings, or the same error
+func sameResult(treeValue Value, treeErr error, vmValue Value, vmErr error) bool {
+	if treeErr != nil || vmErr != nil {
+		return treeErr != nil && vmErr != nil && treeErr.Error() == vmErr.Error()
+	}
+	if l, ok := treeValue.(Number); ok {
+		r, ok := vmValue.(Number)
+		return ok && (math.Float64bits(float64(l)) == math.Float64bits(float64(r)) || l != l && r != r)
+	}
+	return treeValue.Kind() == vmValue.Kind() && treeValue.String() == vmValue.String()
+}
+
+// differential evaluates expression over every assignment of pool values to the operands a, b and c
+// it uses, both on the VM and by walking the tree, and reports any difference
+func differential(t *testing.T, registry *FunctionRegistry, expression string, operands int, pool []Value) {
+	t.Helper()
+
+	program, err := CompileWithRegistry(expression, registry)
+	if err != nil {
+		t.Fatalf("Compile(%q) failed: %v", expression, err)
+	}
+	if program.code == nil {
+		t.Fatalf("Compile(%q) did not produce bytecode", expression)
+	}
+
+	names := []string{"a", "b", "c"}[:operands]
+	assignment := make([]int, operands)
+	for {
+		bindings := make(map[string]Value, operands)
+		for i, name := range names {
+			bindings[name] = pool[assignment[i]]
+		}
+		env := NewEnvironment(bindings).WithNow(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+		treeValue, treeErr := program.EvaluateTree(env)
+		vmValue, vmErr := program.Evaluate(env)
+		if !sameResult(treeValue, treeErr, vmValue, vmErr) {
+			t.Errorf("%s with %v: tree gives %v (%v), VM gives %v (%v)", expression, bindings, treeValue, treeErr, vmValue, vmErr)
+		}
+
+		// Advance to the next assignment, like an odometer
+		i := 0
+		for ; i < operands; i++ {
+			assignment[i]++
+			if assignment[i] < len(pool) {
+				break
+			}
+			assignment[i] = 0
+		}
+		if i == operands {
+			return
+		}
+	}
+}
+
+// TestVMMatchesTree checks that the VM gives exactly the results and errors of the tree walker
+// for every built-in operator and function, in every numeric mode
+func TestVMMatchesTree(t *testing.T) {
+	pool := operandPool(t)
+
+	registries := map[NumericMode]*FunctionRegistry{ModeFloat: defaultRegistry}
+	for _, mode := range []NumericMode{ModeDecimal, ModeRational, ModeBigInt, ModeComplex} {
+		registry, err := NewNumericRegistry(NumericOptions{Mode: mode})
+		if err != nil {
+			t.Fatalf("NewNumericRegistry(%s) failed: %v", mode, err)
+		}
+		registries[mode] = registry
+	}
+
+	for mode, registry := range registries {
+		t.Run(string(mode), func(t *testing.T) {
+			t.Run("operators", func(t *testing.T) {
+				var symbols []string
+				for symbol := range registry.operators {
+					symbols = append(symbols, symbol)
+				}
+				sort.Strings(symbols)
+				for _, symbol := range append(symbols, "&&", "||") {
+					differential(t, registry, fmt.Sprintf("a %s b", symbol), 2, pool)
+				}
+			})
+
+			t.Run("forms", func(t *testing.T) {
+				for _, form := range []struct {
+					expression string
+					operands   int
+					floatOnly  bool
+				}{
+					{"-a", 1, false},
+					{"+a", 1, false},
+					{"!a", 1, false},
+					{"a ? b : c", 3, false},
+					{"if(a, b, c)", 3, false},
+					{"[a, b, c]", 3, false},
+					{"a[b]", 2, false},
+					{"a to m", 1, true},
+					{"a in h", 1, true},
+					{"map([a, b], x -> x * c)", 3, false},
+					{"reduce([1, 2, 3], (x, y) -> x * a + y)", 1, false},
+					{"sum(k * a, k, 1, 3)", 1, false},
+					{"prod(a + k, k, 1, 3)", 1, false},
+					{"integrate(a * t^2, t, 0, 1)", 1, false},
+					{"now() + a", 1, false},
+					{"(a + b) * (a - c) / b", 3, false},
+				} {
+					if form.floatOnly && mode != ModeFloat {
+						continue
+					}
+					differential(t, registry, form.expression, form.operands, pool)
+				}
+			})
+
+			t.Run("functions", func(t *testing.T) {
+				var names []string
+				for name := range registry.functions {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					function := registry.functions[name]
+					highest := function.maxArgs
+					if highest == Variadic || highest > 3 {
+						highest = 3
+					}
+					for arity := function.minArgs; arity <= highest; arity++ {
+						args := []string{"a", "b", "c"}[:arity]
+						differential(t, registry, fmt.Sprintf("%s(%s)", name, strings.Join(args, ", ")), arity, pool)
+					}
+				}
+			})
+		})
+	}
+}
+
+// deepExpression nests depth operations, each depending on the one before it
+func deepExpression(depth int) string {
+	expression := "x"
+	operators := []string{"+", "*", "-", "/"}
+	for i := 0; i < depth; i++ {
+		expression = fmt.Sprintf("(%s %s y)", expression, operators[i%len(operators)])
+	}
+	return expression
+}
+
+// wideExpression adds width independent terms
+func wideExpression(width int) string {
+	terms := make([]string, width)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("x * %d - y / %d", i+1, i+2)
+	}
+	return strings.Join(terms, " + ")
+}
+
+// benchmarkExpressions are the shapes the benchmarks compare the two evaluators on
+var benchmarkExpressions = []struct {
+	name       string
+	expression string
+}{
+	{"deep", deepExpression(64)},
+	{"wide", wideExpression(64)},
+	{"mixed", "x > y ? sqrt(x * x + y * y) : max(x, y) ^ 2 - x % 7"},
+}
+
+func benchmarkEvaluation(b *testing.B, evaluate func(p *Program, env *Environment) (Value, error)) {
+	env := NewEnvironment(map[string]Value{"x": Number(3.5), "y": Number(1.25)})
+	for _, bench := range benchmarkExpressions {
+		program, err := Compile(bench.expression)
+		if err != nil {
+			b.Fatalf("Compile(%q) failed: %v", bench.expression, err)
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := evaluate(program, env); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTree(b *testing.B) {
+	benchmarkEvaluation(b, (*Program).EvaluateTree)
+}
+
+func BenchmarkVM(b *testing.B) {
+	benchmarkEvaluation(b, (*Program).Evaluate)
+}
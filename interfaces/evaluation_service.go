@@ -3,21 +3,24 @@ package interfaces
 import (
 	"context"
 	"time"
+
+	"expression-eval-service/evaluator"
 )
 
 // Evaluation represents a single expression evaluation
 type Evaluation struct {
 	ID         string
 	Expression string
-	Result     float64
+	Result     evaluator.Value
+	ResultType string
 	Error      error
 	Timestamp  time.Time
 }
 
 // EvaluationService defines the interface for expression evaluation
 type EvaluationService interface {
-	// Evaluate evaluates an expression and stores the result
-	Evaluate(ctx context.Context, expression string) (Evaluation, error)
+	// Evaluate evaluates an expression against the given variables in the given numeric mode and stores the result
+	Evaluate(ctx context.Context, expression string, variables map[string]interface{}, options evaluator.NumericOptions) (Evaluation, error)
 
 	// GetHistory retrieves the evaluation history with pagination
 	GetHistory(ctx context.Context, page, pageSize int) ([]Evaluation, int, error)
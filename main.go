@@ -37,10 +37,13 @@ func main() {
 	errors.InitializeErrorMetrics(logger.Logger)
 
 	// Initialize services
-	evalService := services.NewEvaluationService(logger.Logger)
+	evalService := services.NewEvaluationService(logger.Logger,
+		services.WithCacheCapacity(cfg.Cache.Capacity),
+	)
 
 	// Initialize controllers
 	evaluateController := controllers.NewEvaluateController(evalService, logger.Logger)
+	adminController := controllers.NewAdminController(evalService, logger.Logger)
 
 	// Configure Gin router
 	router := gin.New()
@@ -52,8 +55,14 @@ func main() {
 	router.Use(middlewares.RateLimitMiddleware(float64(cfg.Security.RateLimit), float64(cfg.Security.RateLimit), logger.Logger))
 	router.Use(middlewares.CORSMiddleware(cfg.Security.AllowedOrigins))
 
-	// Setup routes
-	routes.SetupRoutes(router, evaluateController)
+	// Setup routes; the admin endpoints are only available with a token
+	var adminAuth gin.HandlerFunc
+	if cfg.Security.AdminToken != "" {
+		adminAuth = middlewares.AdminAuthMiddleware(cfg.Security.AdminToken, logger.Logger)
+	} else {
+		logger.Logger.Info("Admin endpoints disabled: ADMIN_TOKEN is not set")
+	}
+	routes.SetupRoutes(router, evaluateController, adminController, adminAuth)
 
 	// Configure HTTP server
 	srv := &http.Server{
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"expression-eval-service/errors"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminAuthMiddleware creates a middleware that only lets through requests carrying the admin token
+// as a bearer token in the Authorization header
+// An empty token rejects every request, so the admin endpoints are closed unless a token is configured
+func AdminAuthMiddleware(token string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			logger.Warn("Unauthorized admin request",
+				zap.String("ip", c.ClientIP()),
+				zap.String("path", c.Request.URL.Path),
+			)
+			errors.HandleError(c, 401, errors.ErrUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
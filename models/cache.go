@@ -0,0 +1,11 @@
+package models
+
+// CacheStats represents the state and counters of the compiled-expression cache
+type CacheStats struct {
+	Capacity  int      `json:"capacity"`
+	Size      int      `json:"size"`
+	Hits      int64    `json:"hits"`
+	Misses    int64    `json:"misses"`
+	Evictions int64    `json:"evictions"`
+	Entries   []string `json:"entries"` // Cached expressions, most recently used first
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"expression-eval-service/evaluator"
+)
+
+// Derivative represents the symbolic derivative of an expression with respect to one variable
+type Derivative struct {
+	Expression string                `json:"expression"`
+	Variable   string                `json:"variable"`
+	Derivative string                `json:"derivative,omitempty"` // The simplified derivative as an expression
+	AST        *evaluator.AST        `json:"ast,omitempty"`        // The simplified derivative as an expression tree
+	At         *float64              `json:"at,omitempty"`         // The point the derivative was evaluated at, if requested
+	Value      evaluator.Value       `json:"value,omitempty"`      // The value of the derivative at that point
+	ParseError *evaluator.ParseError `json:"parseError,omitempty"` // Location of the syntax error (if parsing failed)
+}
@@ -3,21 +3,31 @@ package models
 import (
 	"time"
 
+	"expression-eval-service/evaluator"
+
 	"github.com/google/uuid"
 )
 
 // BatchEvaluationRequest represents a request to evaluate multiple expressions
 type BatchEvaluationRequest struct {
-	Expressions []string `json:"expressions" binding:"required,min=1"`
+	Expressions []string               `json:"expressions" binding:"required,min=1"`
+	Variables   map[string]interface{} `json:"variables,omitempty"`
+	Now         *time.Time             `json:"now,omitempty"` // Pins the time now() returns, for reproducible results
+
+	evaluator.NumericOptions // numericMode, precision and rounding, shared by every expression
 }
 
 // Evaluation represents a single expression evaluation result
 type Evaluation struct {
-	ID         string    `json:"id"`
-	Expression string    `json:"expression"`
-	Result     float64   `json:"result,omitempty"`
-	Error      string    `json:"error,omitempty"`
-	Timestamp  time.Time `json:"timestamp"`
+	ID          string                 `json:"id"`
+	Expression  string                 `json:"expression"`
+	Variables   map[string]interface{} `json:"variables,omitempty"`
+	NumericMode evaluator.NumericMode  `json:"numericMode,omitempty"` // The numeric mode requested, if not the default
+	Result      evaluator.Value        `json:"result,omitempty"`      // The computed result (if successful)
+	ResultType  string                 `json:"resultType,omitempty"`  // The kind of the result, e.g. "number", "boolean" or "rational"
+	Error       string                 `json:"error,omitempty"`
+	ParseError  *evaluator.ParseError  `json:"parseError,omitempty"` // Location of the syntax error (if parsing failed)
+	Timestamp   time.Time              `json:"timestamp"`
 }
 
 // BatchEvaluationResponse represents the response for batch evaluation
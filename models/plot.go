@@ -0,0 +1,13 @@
+package models
+
+import (
+	"expression-eval-service/evaluator"
+)
+
+// Plot represents an expression in one variable sampled over a range, ready to chart
+type Plot struct {
+	Expression string                `json:"expression"`
+	Variable   string                `json:"variable"`
+	Points     []evaluator.Point     `json:"points"`               // The samples in order of x; y is null where the curve is undefined or breaks
+	ParseError *evaluator.ParseError `json:"parseError,omitempty"` // Location of the syntax error (if parsing failed)
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"expression-eval-service/evaluator"
+)
+
+// Simplification represents an expression rewritten into a simpler, equivalent form
+type Simplification struct {
+	Expression string                `json:"expression"`
+	Simplified string                `json:"simplified,omitempty"` // The simplified expression
+	AST        *evaluator.AST        `json:"ast,omitempty"`        // The simplified expression as an expression tree
+	ParseError *evaluator.ParseError `json:"parseError,omitempty"` // Location of the syntax error (if parsing failed)
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"expression-eval-service/evaluator"
+)
+
+// Solution represents a root of an equation in one variable, found numerically
+type Solution struct {
+	Expression string `json:"expression"`
+	Variable   string `json:"variable"`
+	evaluator.Solution
+	ParseError *evaluator.ParseError `json:"parseError,omitempty"` // Location of the syntax error (if parsing failed)
+	SolveError *evaluator.SolveError `json:"solveError,omitempty"` // Why no root was found (if the method failed)
+}
@@ -7,7 +7,8 @@ import (
 )
 
 // SetupRoutes configures the API routes
-func SetupRoutes(router *gin.Engine, evaluateController *controllers.EvaluateController) {
+// The admin endpoints are only mounted when adminAuth is given, and every request to them passes through it
+func SetupRoutes(router *gin.Engine, evaluateController *controllers.EvaluateController, adminController *controllers.AdminController, adminAuth gin.HandlerFunc) {
 	// API routes
 	api := router.Group("/api")
 	{
@@ -18,8 +19,25 @@ func SetupRoutes(router *gin.Engine, evaluateController *controllers.EvaluateCon
 			eval.POST("/single", evaluateController.Evaluate)
 			// Batch expression evaluation
 			eval.POST("/batch", evaluateController.EvaluateBatch)
+			// Symbolic differentiation
+			eval.POST("/derivative", evaluateController.Derivative)
+			// Expression simplification
+			eval.POST("/simplify", evaluateController.Simplify)
+			// Root finding
+			eval.POST("/solve", evaluateController.Solve)
+			// Plotting
+			eval.POST("/plot", evaluateController.Plot)
 			// History endpoint
 			eval.GET("/history", evaluateController.GetHistory)
 		}
+
+		// Admin endpoints
+		if adminAuth != nil {
+			admin := api.Group("/admin", adminAuth)
+			// Inspect the compiled-expression cache
+			admin.GET("/cache", adminController.GetCache)
+			// Flush the compiled-expression cache
+			admin.DELETE("/cache", adminController.FlushCache)
+		}
 	}
 }
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"expression-eval-service/evaluator"
+	"expression-eval-service/models"
+
+	"go.uber.org/zap"
+)
+
+// Differentiate returns the simplified symbolic derivative of an expression with respect to variable
+// When at is given, the derivative is also evaluated there, with any other variables bound from variables
+func (s *EvaluationService) Differentiate(ctx context.Context, expression, variable string, at *float64, variables map[string]interface{}) (models.Derivative, error) {
+	s.logger.Info("Starting differentiation of expression",
+		zap.String("expression", expression),
+		zap.String("variable", variable),
+	)
+
+	result := models.Derivative{Expression: expression, Variable: variable}
+
+	root, err := evaluator.NewParserWithRegistry(s.registry).Parse(expression)
+	if err != nil {
+		var parseErr *evaluator.ParseError
+		if errors.As(err, &parseErr) {
+			result.ParseError = parseErr
+		}
+		return result, err
+	}
+
+	// The other variables can hide constants, so they are bound while differentiating
+	env, err := evaluator.NewEnvironmentFromJSON(variables)
+	if err != nil {
+		return result, err
+	}
+	derivative, err := evaluator.Differentiate(root, variable, env)
+	if err != nil {
+		return result, err
+	}
+	result.Derivative = evaluator.Format(derivative)
+	result.AST = evaluator.ASTOf(derivative)
+
+	if at == nil {
+		return result, nil
+	}
+
+	// Bind the point on top of the other variables and evaluate the derivative there
+	bindings := make(map[string]interface{}, len(variables)+1)
+	for name, value := range variables {
+		bindings[name] = value
+	}
+	bindings[variable] = *at
+
+	env, err = evaluator.NewEnvironmentFromJSON(bindings)
+	if err != nil {
+		return result, err
+	}
+	value, err := evaluator.NewProgram(result.Derivative, derivative).Evaluate(env.WithNow(nowFrom(ctx)))
+	if err != nil {
+		return result, err
+	}
+	result.At = at
+	result.Value = value
+
+	s.logger.Info("Successfully differentiated expression",
+		zap.String("expression", expression),
+		zap.String("derivative", result.Derivative),
+	)
+
+	return result, nil
+}
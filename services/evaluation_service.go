@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -23,36 +24,89 @@ type Evaluation struct {
 // EvaluationService manages expression evaluations and their history
 // It provides thread-safe operations for evaluating expressions and retrieving history
 type EvaluationService struct {
-	history []models.Evaluation // In-memory storage for evaluation history
-	mu      sync.RWMutex        // Mutex for thread-safe access to history
-	logger  *zap.Logger         // Logger for tracking operations
-	parser  *evaluator.Parser   // Parser for parsing expressions
+	history  []models.Evaluation         // In-memory storage for evaluation history
+	mu       sync.RWMutex                // Mutex for thread-safe access to history
+	logger   *zap.Logger                 // Logger for tracking operations
+	registry *evaluator.FunctionRegistry // Functions and operators available to expressions
+	cache    *ExpressionCache            // Compiled expressions keyed by normalized source
 }
 
+// Option configures optional behaviour of an EvaluationService
+type Option func(*EvaluationService)
+
+// WithRegistry makes the service parse expressions against a custom function registry,
+// exposing the registry's functions and operators over the HTTP API
+func WithRegistry(registry *evaluator.FunctionRegistry) Option {
+	return func(s *EvaluationService) {
+		s.registry = registry
+	}
+}
+
+// WithCacheCapacity sets the number of compiled expressions the service keeps
+// A capacity of zero disables the cache
+func WithCacheCapacity(capacity int) Option {
+	return func(s *EvaluationService) {
+		s.cache = NewExpressionCache(capacity)
+	}
+}
+
+// nowKey is the context key under which WithNow stores the pinned time
+type nowKey struct{}
+
+// WithNow returns a copy of ctx that pins the time now() returns in expressions evaluated with it,
+// so that their results are reproducible
+// Without a pinned time, every expression in a request sees the time the request was evaluated
+func WithNow(ctx context.Context, now time.Time) context.Context {
+	return context.WithValue(ctx, nowKey{}, now)
+}
+
+// nowFrom returns the time pinned on ctx by WithNow, or the current time
+func nowFrom(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(nowKey{}).(time.Time); ok {
+		return now
+	}
+	return time.Now().UTC()
+}
+
+// DefaultCacheCapacity is the cache capacity used when WithCacheCapacity is not given
+const DefaultCacheCapacity = 1000
+
 // NewEvaluationService creates a new instance of EvaluationService
 // It initializes an empty history and sets up the logger
-func NewEvaluationService(logger *zap.Logger) *EvaluationService {
-	return &EvaluationService{
-		history: make([]models.Evaluation, 0),
-		logger:  logger,
-		parser:  evaluator.NewParser(),
+func NewEvaluationService(logger *zap.Logger, opts ...Option) *EvaluationService {
+	s := &EvaluationService{
+		history:  make([]models.Evaluation, 0),
+		logger:   logger,
+		registry: evaluator.NewFunctionRegistry(),
+		cache:    NewExpressionCache(DefaultCacheCapacity),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
-// Evaluate evaluates an expression and stores the result in history
+// Evaluate evaluates an expression against the given variable bindings in the numeric mode
+// selected by options and stores the result in history
 // It handles both successful evaluations and errors, storing both in history
-func (s *EvaluationService) Evaluate(ctx context.Context, expression string) (models.Evaluation, error) {
+func (s *EvaluationService) Evaluate(ctx context.Context, expression string, variables map[string]interface{}, options evaluator.NumericOptions) (models.Evaluation, error) {
 	s.logger.Info("Starting evaluation of expression",
 		zap.String("expression", expression),
+		zap.Int("variable_count", len(variables)),
+		zap.String("numeric_mode", string(options.Mode)),
 	)
 
 	// Create evaluation record
 	eval := models.NewEvaluation(expression)
+	eval.Variables = variables
+	eval.NumericMode = options.Mode
 
-	// Parse and evaluate expression
-	expr, err := s.parser.Parse(expression)
+	// Compile and evaluate expression
+	program, err := s.compile(expression, options)
 	if err != nil {
-		eval.Error = err.Error()
+		setError(&eval, err)
 		s.addToHistory(ctx, eval)
 		s.logger.Error("Failed to parse expression",
 			zap.String("expression", expression),
@@ -61,8 +115,19 @@ func (s *EvaluationService) Evaluate(ctx context.Context, expression string) (mo
 		return eval, err
 	}
 
-	// Evaluate the expression
-	result, err := expr.Evaluate()
+	// Bind the variables and evaluate the expression
+	env, err := evaluator.NewEnvironmentFromJSON(variables)
+	if err != nil {
+		eval.Error = err.Error()
+		s.addToHistory(ctx, eval)
+		s.logger.Error("Invalid variables",
+			zap.String("expression", expression),
+			zap.Error(err),
+		)
+		return eval, err
+	}
+
+	result, err := program.Evaluate(env.WithNow(nowFrom(ctx)))
 	if err != nil {
 		eval.Error = err.Error()
 		s.addToHistory(ctx, eval)
@@ -73,12 +138,12 @@ func (s *EvaluationService) Evaluate(ctx context.Context, expression string) (mo
 		return eval, err
 	}
 
-	eval.Result = result
+	setResult(&eval, result)
 	s.addToHistory(ctx, eval)
 
 	s.logger.Info("Successfully evaluated expression",
 		zap.String("expression", expression),
-		zap.Float64("result", result),
+		zap.Stringer("result", result),
 		zap.String("id", eval.ID),
 	)
 
@@ -86,10 +151,15 @@ func (s *EvaluationService) Evaluate(ctx context.Context, expression string) (mo
 }
 
 // EvaluateBatch evaluates multiple expressions concurrently
-func (s *EvaluationService) EvaluateBatch(ctx context.Context, expressions []string) models.BatchEvaluationResponse {
+// The same variable bindings, numeric mode and time for now() are shared by every expression in the batch
+func (s *EvaluationService) EvaluateBatch(ctx context.Context, expressions []string, variables map[string]interface{}, options evaluator.NumericOptions) models.BatchEvaluationResponse {
 	s.logger.Info("Starting batch evaluation",
-		zap.Int("expression_count", len(expressions)))
+		zap.Int("expression_count", len(expressions)),
+		zap.Int("variable_count", len(variables)))
 
+	// Invalid variables fail every expression that compiles
+	env, envErr := evaluator.NewEnvironmentFromJSON(variables)
+	env = env.WithNow(nowFrom(ctx))
 	results := make([]models.Evaluation, 0, len(expressions))
 	var wg sync.WaitGroup
 	resultChan := make(chan models.Evaluation, len(expressions))
@@ -101,18 +171,26 @@ func (s *EvaluationService) EvaluateBatch(ctx context.Context, expressions []str
 			defer wg.Done()
 
 			eval := models.NewEvaluation(expression)
-			ast, err := s.parser.Parse(expression)
+			eval.Variables = variables
+			eval.NumericMode = options.Mode
+			program, err := s.compile(expression, options)
 			if err != nil {
-				eval.Error = err.Error()
+				setError(&eval, err)
 				resultChan <- eval
 				return
 			}
 
-			result, err := ast.Evaluate()
+			if envErr != nil {
+				eval.Error = envErr.Error()
+				resultChan <- eval
+				return
+			}
+
+			result, err := program.Evaluate(env)
 			if err != nil {
 				eval.Error = err.Error()
 			} else {
-				eval.Result = result
+				setResult(&eval, result)
 			}
 			resultChan <- eval
 		}(i, expr)
@@ -174,6 +252,82 @@ func (s *EvaluationService) GetHistory(ctx context.Context, page, pageSize int)
 	return history, total, nil
 }
 
+// CacheStats returns the compiled-expression cache counters and contents
+func (s *EvaluationService) CacheStats(ctx context.Context) models.CacheStats {
+	return s.cache.Stats()
+}
+
+// FlushCache empties the compiled-expression cache and returns the number of entries removed
+func (s *EvaluationService) FlushCache(ctx context.Context) int {
+	removed := s.cache.Flush()
+	s.logger.Info("Flushed expression cache",
+		zap.Int("removed", removed),
+	)
+	return removed
+}
+
+// compile returns the program compiled for expression in the numeric mode selected by options,
+// consulting the cache first
+// Only successful compilations are cached, so invalid expressions are re-parsed
+// each time and still produce a fresh ParseError
+func (s *EvaluationService) compile(expression string, options evaluator.NumericOptions) (*evaluator.Program, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Registering a function or operator invalidates every cached program
+	version := s.registry.Version()
+	if removed := s.cache.Sync(version); removed > 0 {
+		s.logger.Info("Flushed expression cache after a registry change",
+			zap.Int("removed", removed),
+		)
+	}
+
+	key := cacheKey(expression, options)
+	if program, ok := s.cache.Get(key); ok {
+		return program, nil
+	}
+
+	registry, err := s.registryFor(options)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := evaluator.CompileWithRegistry(expression, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Add(key, program, version)
+	return program, nil
+}
+
+// registryFor returns the registry expressions are compiled against in the numeric mode selected by options
+// Float mode uses the service registry; the other modes layer their built-ins over a copy of it,
+// so custom functions and operators stay available in every mode
+func (s *EvaluationService) registryFor(options evaluator.NumericOptions) (*evaluator.FunctionRegistry, error) {
+	if options.Mode == "" || options.Mode == evaluator.ModeFloat {
+		return s.registry, nil
+	}
+	return s.registry.WithNumericMode(options)
+}
+
+// setResult records a successful result and its type on the evaluation
+func setResult(eval *models.Evaluation, result evaluator.Value) {
+	eval.Result = result
+	eval.ResultType = result.Kind().String()
+}
+
+// setError records err on the evaluation, including the error location for syntax errors
+func setError(eval *models.Evaluation, err error) {
+	eval.Error = err.Error()
+
+	var parseErr *evaluator.ParseError
+	if errors.As(err, &parseErr) {
+		eval.ParseError = parseErr
+	}
+}
+
 // addToHistory adds an evaluation to the history
 // It uses a mutex to ensure thread-safe access
 func (s *EvaluationService) addToHistory(ctx context.Context, eval models.Evaluation) {
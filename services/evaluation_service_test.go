@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"expression-eval-service/evaluator"
+
+	"go.uber.org/zap"
+)
+
+// TestConcurrentEvaluation hammers Evaluate and EvaluateBatch from many goroutines at once,
+// with a cache small enough to evict constantly; run it with go test -race ./services/
+func TestConcurrentEvaluation(t *testing.T) {
+	s := NewEvaluationService(zap.NewNop(), WithCacheCapacity(4))
+	ctx := context.Background()
+
+	expressions := []string{"x * 2 + 1", "sqrt(x) ^ 2", "x % 7", "max(x, 10) - min(x, 10)", "x > 50 ? x : -x", "sum([x, 1, 2])"}
+	want := func(expression string, x float64) evaluator.Value {
+		switch expression {
+		case "x * 2 + 1":
+			return evaluator.Number(x*2 + 1)
+		case "x % 7":
+			return evaluator.Number(float64(int(x) % 7))
+		case "max(x, 10) - min(x, 10)":
+			if x > 10 {
+				return evaluator.Number(x - 10)
+			}
+			return evaluator.Number(10 - x)
+		case "x > 50 ? x : -x":
+			if x > 50 {
+				return evaluator.Number(x)
+			}
+			return evaluator.Number(-x)
+		case "sum([x, 1, 2])":
+			return evaluator.Number(x + 3)
+		}
+		return nil
+	}
+
+	const workers, rounds = 16, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds*len(expressions))
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				x := float64(worker*rounds + round)
+				variables := map[string]interface{}{"x": x}
+
+				if worker%2 == 0 {
+					for _, expression := range expressions {
+						eval, err := s.Evaluate(ctx, expression, variables, evaluator.NumericOptions{})
+						if err != nil {
+							errs <- fmt.Errorf("Evaluate(%q, x=%v) failed: %v", expression, x, err)
+							continue
+						}
+						if expected := want(expression, x); expected != nil && eval.Result != expected {
+							errs <- fmt.Errorf("Evaluate(%q, x=%v) = %v, want %v", expression, x, eval.Result, expected)
+						}
+					}
+				} else {
+					response := s.EvaluateBatch(ctx, expressions, variables, evaluator.NumericOptions{})
+					if len(response.Results) != len(expressions) {
+						errs <- fmt.Errorf("EvaluateBatch returned %d results, want %d", len(response.Results), len(expressions))
+					}
+					for _, eval := range response.Results {
+						if eval.Error != "" {
+							errs <- fmt.Errorf("EvaluateBatch(%q, x=%v) failed: %s", eval.Expression, x, eval.Error)
+							continue
+						}
+						if expected := want(eval.Expression, x); expected != nil && eval.Result != expected {
+							errs <- fmt.Errorf("EvaluateBatch(%q, x=%v) = %v, want %v", eval.Expression, x, eval.Result, expected)
+						}
+					}
+				}
+
+				// Readers and the admin operations run alongside the evaluations
+				switch round % 10 {
+				case 3:
+					if _, _, err := s.GetHistory(ctx, 1, 20); err != nil {
+						errs <- err
+					}
+				case 6:
+					s.CacheStats(ctx)
+				case 9:
+					s.FlushCache(ctx)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if _, total, _ := s.GetHistory(ctx, 1, 1); total != workers*rounds*len(expressions) {
+		t.Errorf("history holds %d evaluations, want %d", total, workers*rounds*len(expressions))
+	}
+}
+
+// TestConcurrentNumericModes evaluates the same expression in every numeric mode at once,
+// so that programs compiled for one mode are never served for another
+func TestConcurrentNumericModes(t *testing.T) {
+	s := NewEvaluationService(zap.NewNop(), WithCacheCapacity(2))
+	ctx := context.Background()
+
+	modes := map[evaluator.NumericMode]string{
+		evaluator.ModeFloat:    "0.30000000000000004",
+		evaluator.ModeDecimal:  "0.3",
+		evaluator.ModeRational: "3/10",
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for mode, want := range modes {
+			wg.Add(1)
+			go func(mode evaluator.NumericMode, want string) {
+				defer wg.Done()
+				for round := 0; round < 50; round++ {
+					eval, err := s.Evaluate(ctx, "0.1 + 0.2", nil, evaluator.NumericOptions{Mode: mode})
+					if err != nil {
+						t.Errorf("Evaluate in %s mode failed: %v", mode, err)
+						return
+					}
+					if got := eval.Result.String(); got != want {
+						t.Errorf("Evaluate in %s mode = %s, want %s", mode, got, want)
+						return
+					}
+				}
+			}(mode, want)
+		}
+	}
+	wg.Wait()
+}
+
+// TestCustomFunctionsInNumericModes checks that functions and operators registered on the service
+// registry remain available when a request selects another numeric mode
+func TestCustomFunctionsInNumericModes(t *testing.T) {
+	registry := evaluator.NewFunctionRegistry()
+	if err := registry.Register("double", 1, func(args []float64) (float64, error) { return args[0] * 2, nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.RegisterValue("twice", 1, func(args []evaluator.Value) (evaluator.Value, error) { return evaluator.List{args[0], args[0]}, nil }); err != nil {
+		t.Fatalf("RegisterValue failed: %v", err)
+	}
+	s := NewEvaluationService(zap.NewNop(), WithRegistry(registry))
+	ctx := context.Background()
+
+	tests := []struct {
+		mode       evaluator.NumericMode
+		expression string
+		want       string
+	}{
+		{evaluator.ModeDecimal, "double(0.25) + 0.1", "0.6"},
+		{evaluator.ModeRational, "twice(1/3)", "[1/3, 1/3]"},
+		{evaluator.ModeBigInt, "twice(2^70)", "[1180591620717411303424, 1180591620717411303424]"},
+		{evaluator.ModeComplex, "double(3)", "6"},
+	}
+	for _, tt := range tests {
+		eval, err := s.Evaluate(ctx, tt.expression, nil, evaluator.NumericOptions{Mode: tt.mode})
+		if err != nil {
+			t.Errorf("Evaluate(%q) in %s mode failed: %v", tt.expression, tt.mode, err)
+			continue
+		}
+		if got := eval.Result.String(); got != tt.want {
+			t.Errorf("Evaluate(%q) in %s mode = %s, want %s", tt.expression, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDifferentiateRejectsInvalidVariable(t *testing.T) {
+	s := NewEvaluationService(zap.NewNop())
+	at := 2.0
+
+	for _, variable := range []string{"", "2x", "x y", "x+1", "f(x)"} {
+		if _, err := s.Differentiate(context.Background(), "x^2", variable, &at, nil); err == nil {
+			t.Errorf("Differentiate with variable %q succeeded, want an error", variable)
+		}
+	}
+
+	derivative, err := s.Differentiate(context.Background(), "x^2", "x", &at, nil)
+	if err != nil {
+		t.Fatalf("Differentiate failed: %v", err)
+	}
+	if derivative.Value != evaluator.Number(4) {
+		t.Errorf("Differentiate(x^2) at 2 = %v, want 4", derivative.Value)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"unicode"
+
+	"expression-eval-service/evaluator"
+	"expression-eval-service/models"
+)
+
+// ExpressionCache is a least-recently-used cache of compiled expressions
+// It is keyed on whitespace-normalized source and numeric mode and is safe for concurrent use
+// Its entries belong to one version of the function registry and are dropped when the version changes
+type ExpressionCache struct {
+	mu        sync.Mutex
+	capacity  int
+	version   uint64 // the registry version the cached programs were compiled against
+	entries   map[string]*list.Element
+	order     *list.List // Front is the most recently used entry
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// cacheEntry is the value stored in each element of the LRU list
+type cacheEntry struct {
+	key     string
+	program *evaluator.Program
+}
+
+// NewExpressionCache creates a cache holding at most capacity programs
+// A capacity of zero or less disables caching
+func NewExpressionCache(capacity int) *ExpressionCache {
+	return &ExpressionCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the program cached for key and marks it as recently used
+func (c *ExpressionCache) Get(key string) (*evaluator.Program, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).program, true
+}
+
+// Add stores a program compiled against the given registry version under key,
+// evicting the least recently used entry when full
+// A program compiled against a version other than the cache's is not stored
+func (c *ExpressionCache) Add(key string, program *evaluator.Program, version uint64) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).program = program
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, program: program})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Stats returns the cache counters and the cached expressions
+func (c *ExpressionCache) Stats() models.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]string, 0, c.order.Len())
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		entries = append(entries, element.Value.(*cacheEntry).key)
+	}
+
+	return models.CacheStats{
+		Capacity:  c.capacity,
+		Size:      c.order.Len(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   entries,
+	}
+}
+
+// Flush removes every entry from the cache and returns how many were removed
+// The hit, miss and eviction counters are left untouched
+func (c *ExpressionCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	return removed
+}
+
+// Sync flushes the cache when the registry version differs from the one its programs were compiled against,
+// so that programs never keep the definitions of functions and operators that have since been replaced
+// It returns the number of entries removed
+func (c *ExpressionCache) Sync(version uint64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version == c.version {
+		return 0
+	}
+	removed := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.version = version
+	return removed
+}
+
+// cacheKey returns the key a program is cached under: the normalized expression,
+// prefixed by the numeric options for the modes other than float
+func cacheKey(expression string, options evaluator.NumericOptions) string {
+	key := normalizeExpression(expression)
+	if mode := options.Key(); mode != "" {
+		key = "[" + mode + "] " + key
+	}
+	return key
+}
+
+// normalizeExpression collapses each run of whitespace to a single space and trims the ends,
+// so "1+2", " 1+2 " and "1+2\n" share a cache entry while "1 2" and "12" do not
+// Whitespace inside string literals is significant and kept as written
+func normalizeExpression(expression string) string {
+	var builder strings.Builder
+	inString, escaped, pendingSpace := false, false, false
+
+	for _, c := range expression {
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case unicode.IsSpace(c):
+			pendingSpace = builder.Len() > 0
+			continue
+		case c == '"':
+			inString = true
+		}
+
+		if pendingSpace {
+			builder.WriteByte(' ')
+			pendingSpace = false
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
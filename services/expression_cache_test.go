@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"expression-eval-service/evaluator"
+
+	"go.uber.org/zap"
+)
+
+func TestNormalizeExpression(t *testing.T) {
+	tests := map[string]string{
+		"1+2":            "1+2",
+		"  1 +\t2\n":     "1 + 2",
+		"1  2":           "1 2",
+		`upper("a  b")`:  `upper("a  b")`,
+		`"a \"  b"  + x`: `"a \"  b" + x`,
+	}
+	for input, want := range tests {
+		if got := normalizeExpression(input); got != want {
+			t.Errorf("normalizeExpression(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// TestCacheFlushedOnRegistration checks that a cached program does not keep
+// the definition of a function that has since been registered again
+func TestCacheFlushedOnRegistration(t *testing.T) {
+	registry := evaluator.NewFunctionRegistry()
+	s := NewEvaluationService(zap.NewNop(), WithRegistry(registry))
+	ctx := context.Background()
+
+	for _, want := range []float64{1, 2} {
+		version := want
+		if err := registry.Register("version", 0, func(args []float64) (float64, error) { return version, nil }); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		eval, err := s.Evaluate(ctx, "version() * x", map[string]interface{}{"x": 1.0}, evaluator.NumericOptions{})
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		if eval.Result != evaluator.Number(want) {
+			t.Errorf("version() * x = %v after registering version %v", eval.Result, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"expression-eval-service/evaluator"
+	"expression-eval-service/models"
+
+	"go.uber.org/zap"
+)
+
+// Plot samples an expression as a function of variable, with any other variables bound from variables
+func (s *EvaluationService) Plot(ctx context.Context, expression, variable string, variables map[string]interface{}, options evaluator.PlotOptions) (models.Plot, error) {
+	s.logger.Info("Starting plot of expression",
+		zap.String("expression", expression),
+		zap.String("variable", variable),
+	)
+
+	result := models.Plot{Expression: expression, Variable: variable}
+
+	program, err := s.compile(expression, evaluator.NumericOptions{})
+	if err != nil {
+		var parseErr *evaluator.ParseError
+		if errors.As(err, &parseErr) {
+			result.ParseError = parseErr
+		}
+		return result, err
+	}
+
+	env, err := evaluator.NewEnvironmentFromJSON(variables)
+	if err != nil {
+		return result, err
+	}
+
+	points, err := evaluator.Plot(program, variable, env.WithNow(nowFrom(ctx)), options)
+	if err != nil {
+		return result, err
+	}
+	result.Points = points
+
+	s.logger.Info("Successfully plotted expression",
+		zap.String("expression", expression),
+		zap.Int("points", len(points)),
+	)
+
+	return result, nil
+}
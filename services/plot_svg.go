@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+
+	"expression-eval-service/models"
+)
+
+// Dimensions of the rendered chart, in pixels
+const (
+	chartWidth        = 640
+	chartHeight       = 400
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 30
+	chartMarginBottom = 40
+	chartTicks        = 6 // the approximate number of ticks on each axis
+)
+
+// RenderPlotSVG draws a plot as an SVG line chart with axes, gridlines and tick labels
+// The curve is broken wherever a point has no value, and the y axis covers all but the most extreme
+// 2% of values at each end, so that a few huge values near an asymptote do not flatten the rest
+func RenderPlotSVG(plot models.Plot) []byte {
+	left, right := chartMarginLeft, chartWidth-chartMarginRight
+	top, bottom := chartMarginTop, chartHeight-chartMarginBottom
+
+	xMin, xMax := 0.0, 1.0
+	if len(plot.Points) > 0 {
+		xMin, xMax = plot.Points[0].X, plot.Points[len(plot.Points)-1].X
+	}
+	yMin, yMax := valueRange(plot)
+
+	sx := func(x float64) float64 { return float64(left) + (x-xMin)/(xMax-xMin)*float64(right-left) }
+	sy := func(y float64) float64 { return float64(bottom) - (y-yMin)/(yMax-yMin)*float64(bottom-top) }
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="13">%s</text>`+"\n",
+		chartWidth/2, chartMarginTop/2+4, html.EscapeString(plot.Expression))
+	fmt.Fprintf(&b, `<clipPath id="plot-area"><rect x="%d" y="%d" width="%d" height="%d"/></clipPath>`+"\n",
+		left, top, right-left, bottom-top)
+
+	// Gridlines and tick labels
+	for _, x := range ticks(xMin, xMax) {
+		fmt.Fprintf(&b, `<line x1="%.2f" y1="%d" x2="%.2f" y2="%d" stroke="#e0e0e0"/>`+"\n", sx(x), top, sx(x), bottom)
+		fmt.Fprintf(&b, `<text x="%.2f" y="%d" text-anchor="middle">%s</text>`+"\n", sx(x), bottom+16, tickLabel(x))
+	}
+	for _, y := range ticks(yMin, yMax) {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.2f" x2="%d" y2="%.2f" stroke="#e0e0e0"/>`+"\n", left, sy(y), right, sy(y))
+		fmt.Fprintf(&b, `<text x="%d" y="%.2f" text-anchor="end">%s</text>`+"\n", left-6, sy(y)+4, tickLabel(y))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n",
+		(left+right)/2, chartHeight-6, html.EscapeString(plot.Variable))
+
+	// Axes through the origin when it is in view, otherwise along the edges of the chart
+	xAxis, yAxis := float64(bottom), float64(left)
+	if yMin <= 0 && 0 <= yMax {
+		xAxis = sy(0)
+	}
+	if xMin <= 0 && 0 <= xMax {
+		yAxis = sx(0)
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.2f" x2="%d" y2="%.2f" stroke="black"/>`+"\n", left, xAxis, right, xAxis)
+	fmt.Fprintf(&b, `<line x1="%.2f" y1="%d" x2="%.2f" y2="%d" stroke="black"/>`+"\n", yAxis, top, yAxis, bottom)
+
+	// The curve, starting a new stroke after every gap
+	var path bytes.Buffer
+	command := "M"
+	for _, point := range plot.Points {
+		if point.Y == nil {
+			command = "M"
+			continue
+		}
+		fmt.Fprintf(&path, "%s%.2f %.2f ", command, sx(point.X), clamp(sy(*point.Y)))
+		command = "L"
+	}
+	fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#1f77b4" stroke-width="1.5" stroke-linejoin="round" clip-path="url(#plot-area)"/>`+"\n",
+		bytes.TrimSpace(path.Bytes()))
+
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// valueRange returns the span of the y axis: the defined values without the most extreme 2% at each end,
+// padded by 5% so the curve does not touch the frame
+// Each value counts for the stretch of x around it, so the extra samples adaptive sampling
+// places near an asymptote do not widen the range
+func valueRange(plot models.Plot) (float64, float64) {
+	type sample struct{ y, weight float64 }
+	var samples []sample
+	total := 0.0
+	for i, point := range plot.Points {
+		if point.Y == nil {
+			continue
+		}
+		before, after := point.X, point.X
+		if i > 0 {
+			before = plot.Points[i-1].X
+		}
+		if i < len(plot.Points)-1 {
+			after = plot.Points[i+1].X
+		}
+		samples = append(samples, sample{y: *point.Y, weight: (after - before) / 2})
+		total += (after - before) / 2
+	}
+	if len(samples) == 0 {
+		return -1, 1
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].y < samples[j].y })
+
+	lowest, highest := samples[0].y, samples[len(samples)-1].y
+	cumulative := 0.0
+	for _, s := range samples {
+		cumulative += s.weight
+		if cumulative >= 0.02*total {
+			lowest = s.y
+			break
+		}
+	}
+	cumulative = 0
+	for i := len(samples) - 1; i >= 0; i-- {
+		cumulative += samples[i].weight
+		if cumulative >= 0.02*total {
+			highest = samples[i].y
+			break
+		}
+	}
+	if lowest >= highest {
+		lowest, highest = samples[0].y, samples[len(samples)-1].y
+	}
+	if lowest == highest {
+		return lowest - 1, highest + 1
+	}
+	padding := (highest - lowest) * 0.05
+	return lowest - padding, highest + padding
+}
+
+// ticks returns evenly spaced round values within [lowest, highest], about chartTicks of them
+func ticks(lowest, highest float64) []float64 {
+	step := math.Pow(10, math.Floor(math.Log10((highest-lowest)/chartTicks)))
+	for _, multiple := range []float64{1, 2, 5, 10} {
+		if (highest-lowest)/(step*multiple) <= chartTicks {
+			step *= multiple
+			break
+		}
+	}
+
+	var values []float64
+	for i := math.Ceil(lowest / step); i*step <= highest; i++ {
+		values = append(values, i*step)
+	}
+	return values
+}
+
+// tickLabel renders a tick value compactly, e.g. 0.5, 1e+06 or -2
+func tickLabel(x float64) string {
+	if x == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(x, 'g', 4, 64)
+}
+
+// clamp limits the y coordinate of a far off-scale point to a range every renderer handles;
+// the part of the curve outside the chart is clipped in any case
+func clamp(y float64) float64 {
+	return math.Max(-10*chartHeight, math.Min(11*chartHeight, y))
+}
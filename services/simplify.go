@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"expression-eval-service/evaluator"
+	"expression-eval-service/models"
+
+	"go.uber.org/zap"
+)
+
+// Simplify rewrites an expression into a simpler, equivalent form, folding constants,
+// removing identities, collecting like terms and ordering operands
+func (s *EvaluationService) Simplify(ctx context.Context, expression string) (models.Simplification, error) {
+	s.logger.Info("Starting simplification of expression",
+		zap.String("expression", expression),
+	)
+
+	result := models.Simplification{Expression: expression}
+
+	root, err := evaluator.NewParserWithRegistry(s.registry).Parse(expression)
+	if err != nil {
+		var parseErr *evaluator.ParseError
+		if errors.As(err, &parseErr) {
+			result.ParseError = parseErr
+		}
+		return result, err
+	}
+
+	simplified := evaluator.Simplify(root)
+	result.Simplified = evaluator.Format(simplified)
+	result.AST = evaluator.ASTOf(simplified)
+
+	s.logger.Info("Successfully simplified expression",
+		zap.String("expression", expression),
+		zap.String("simplified", result.Simplified),
+	)
+
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"expression-eval-service/evaluator"
+	"expression-eval-service/models"
+
+	"go.uber.org/zap"
+)
+
+// Solve finds a value of variable that solves an equation such as x^2 = 2,
+// or that makes an expression without = zero, with any other variables bound from variables
+func (s *EvaluationService) Solve(ctx context.Context, equation, variable string, variables map[string]interface{}, options evaluator.SolveOptions) (models.Solution, error) {
+	s.logger.Info("Starting solution of equation",
+		zap.String("equation", equation),
+		zap.String("variable", variable),
+		zap.String("method", options.Method),
+	)
+
+	result := models.Solution{Expression: equation, Variable: variable}
+
+	root, err := evaluator.NewParserWithRegistry(s.registry).ParseEquation(equation)
+	if err != nil {
+		var parseErr *evaluator.ParseError
+		if errors.As(err, &parseErr) {
+			result.ParseError = parseErr
+		}
+		return result, err
+	}
+
+	env, err := evaluator.NewEnvironmentFromJSON(variables)
+	if err != nil {
+		return result, err
+	}
+
+	solution, err := evaluator.Solve(evaluator.NewProgram(equation, root), variable, env.WithNow(nowFrom(ctx)), options)
+	if err != nil {
+		var solveErr *evaluator.SolveError
+		if errors.As(err, &solveErr) {
+			result.SolveError = solveErr
+		}
+		return result, err
+	}
+	result.Solution = solution
+
+	s.logger.Info("Successfully solved equation",
+		zap.String("equation", equation),
+		zap.Float64("root", solution.Root),
+		zap.Int("iterations", solution.Iterations),
+	)
+
+	return result, nil
+}